@@ -1,70 +1,389 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"rag-system/src/api"
 	"rag-system/src/application"
+	"rag-system/src/config"
 	"rag-system/src/domain"
 	"rag-system/src/infrastructure"
 	"rag-system/src/infrastructure/ai"
+	"rag-system/src/infrastructure/archive"
+	"rag-system/src/infrastructure/cache"
+	"rag-system/src/infrastructure/ingest"
+	"rag-system/src/infrastructure/logging"
+	"rag-system/src/infrastructure/metrics"
+	"rag-system/src/infrastructure/migrations"
+	"rag-system/src/infrastructure/tracing"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run выполняет действие и возвращает код выхода. Процесс завершается только в main, чтобы отложенные
+// закрытие репозитория и клиента и отправка оставшихся span трассировки выполнялись и при ошибке
+func run() int {
 	// Определяем флаги командной строки
 	configPath := flag.String("config", "config/config.yaml", "Путь к файлу конфигурации")
-	dbPath := flag.String("db", "./rag_system.db", "Путь к файлу базы данных")
-	action := flag.String("action", "serve", "Действие: serve, index, search")
-	docPath := flag.String("doc", "", "Путь к документу для индексации (для действия index)")
+	flag.String("db", "", "Путь к файлу базы данных (storage.db_path)")
+	flag.Int("limit", 0, "Максимум фрагментов в контексте (retrieval.limit)")
+	flag.Float64("threshold", 0, "Минимальная релевантность фрагмента (retrieval.threshold)")
+	flag.Int("workers", 0, "Воркеров чтения файлов при загрузке каталога (ingest.workers)")
+	flag.String("profile", "", "Профиль конфигурации из секции profiles (profile)")
+	action := flag.String("action", "serve", "Действие: serve, index, ingest, search, list, show, delete, stats, demo, config, validate-config, migrate, fsck, export, import, backup")
+	docPath := flag.String("doc", "", "Путь к документу для индексации (для index) или к каталогу (для ingest)")
+	statePath := flag.String("state", "", "Журнал загруженных файлов для продолжения загрузки (для ingest; по умолчанию <db>.ingest)")
 	query := flag.String("query", "", "Поисковый запрос (для действия search)")
+	templateName := flag.String("template", "", "Шаблон промпта: qa, summarize, compare (для действия search)")
+	language := flag.String("lang", "", "Язык ответа, например ru или en (для действия search)")
+	format := flag.String("format", "text", "Формат вывода: text (table для list, show, delete, stats) или json")
+	docID := flag.String("id", "", "ID документа (для show и delete)")
+	pattern := flag.String("pattern", "", "Шаблон ID документов с *, ? и [...] (для delete вместо -id)")
+	sortField := flag.String("sort", domain.SortByID, "Сортировка списка: id, title, created, size, chunks (для list)")
+	desc := flag.Bool("desc", false, "Сортировка по убыванию (для list)")
+	pageSize := flag.Int("page-size", 20, "Документов на странице (для list)")
+	cursor := flag.String("cursor", "", "Курсор следующей страницы из вывода list")
+	prefix := flag.String("prefix", "", "Только документы с ID, начинающимся с префикса (для list)")
+	titleFilter := flag.String("title", "", "Только документы с названием, содержащим подстроку (для list)")
+	yes := flag.Bool("yes", false, "Подтвердить удаление нескольких документов по шаблону (для delete)")
+	migrateStatus := flag.Bool("status", false, "Только показать состояние миграций (для migrate)")
+	repair := flag.Bool("repair", false, "Перестроить полнотекстовый индекс, если проверка нашла ошибки (для fsck)")
+	dryRun := flag.Bool("dry-run", false, "Показать миграции, которые будут применены, не изменяя базу (для migrate)")
+	filePath := flag.String("file", "", "Файл архива (для export и import; .tar.gz или .jsonl) или резервной копии (для backup)")
+	policy := flag.String("policy", archive.PolicyUpsert, "Политика импорта: upsert - перезаписать совпадающие документы, replace - также удалить отсутствующие в архиве (для import)")
 
 	flag.Parse()
 
-	// Загружаем AI клиент
-	aiClient, err := ai.NewAIClient(*configPath)
+	// Загружаем конфигурацию: файл, затем переменные окружения RAG_*, затем явно заданные флаги
+	cfg, loadErr := loadConfig(*configPath)
+
+	// Журнал с уровнем и форматом из секции logging; секреты из конфигурации в него не попадают.
+	// Стандартный пакет log тоже пишет через него
+	logs := logging.New(os.Stderr, cfg)
+	slog.SetDefault(logs.Logger())
+
+	if *action == "validate-config" {
+		if err := handleValidateConfig(cfg, loadErr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Конфигурация %s корректна\n", *configPath)
+		return 0
+	}
+	if loadErr != nil {
+		return fail("Ошибка загрузки конфигурации", "error", loadErr)
+	}
+
+	if *action == "config" {
+		if err := handleConfig(cfg); err != nil {
+			return fail("Ошибка вывода конфигурации", "error", err)
+		}
+		return 0
+	}
+
+	// Миграции выполняются до открытия репозитория, чтобы status и dry-run не изменяли базу
+	if *action == "migrate" {
+		if err := handleMigrate(cfg.Storage, *migrateStatus, *dryRun, *format); err != nil {
+			return fail("Ошибка миграции схемы", "error", err)
+		}
+		return 0
+	}
+
+	// Метрики поиска, генерации и индексации; в режиме serve отдаются на /metrics
+	ragMetrics := metrics.NewRAG(metrics.NewRegistry())
+
+	// Трассировка запросов; без tracing.enabled tracer равен nil и span не создаются
+	tracer, err := tracing.FromConfig(cfg.Tracing, logs.Logger())
 	if err != nil {
-		log.Fatalf("Ошибка инициализации AI клиента: %v", err)
+		return fail("Ошибка инициализации трассировки", "error", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := tracer.Shutdown(ctx); err != nil {
+			slog.Warn("Не удалось завершить экспорт трассировки", "error", err)
+		}
+	}()
 
 	// Создаем репозиторий
-	repo, err := infrastructure.NewSQLiteDocumentRepository(*dbPath)
+	repo, err := infrastructure.NewSQLiteDocumentRepositoryWithOptions(cfg.Storage.DBPath,
+		infrastructure.RepositoryOptions{ChunkSize: cfg.Chunking.Size, BatchSize: cfg.Storage.BatchSize,
+			SQLite: sqliteOptions(cfg.Storage), Logger: logs.Logger(), Metrics: ragMetrics})
 	if err != nil {
-		log.Fatalf("Ошибка инициализации репозитория: %v", err)
+		return fail("Ошибка инициализации репозитория", "error", err)
 	}
 	defer repo.Close()
+	ragMetrics.ObserveIndex(repo.Stats)
+
+	// AI клиент нужен только действиям, которые обращаются к модели: остальные работают без секции ai
+	var aiClient *ai.AIClient
+	if modelActions[*action] {
+		aiClient, err = ai.NewAIClientWithOptions(cfg, ai.ClientOptions{Logger: logs.Logger(), Metrics: ragMetrics})
+		if err != nil {
+			return fail("Ошибка инициализации AI клиента", "error", err)
+		}
+		defer aiClient.Close()
+	}
 
 	// Создаем сервис
 	service := application.NewRAGService(repo, aiClient)
+	service.SetConfig(cfg)
+	service.SetLogger(logs.Logger())
+	service.SetMetrics(ragMetrics)
+	service.SetTracer(tracer)
+
+	// Действия, изменяющие документы, без AI клиента сбрасывают сохраненные ответы напрямую в хранилище кэша
+	if aiClient == nil && writeActions[*action] {
+		responses, err := cache.New(cfg.Cache)
+		if err != nil {
+			return fail("Ошибка открытия кэша ответов", "error", err)
+		}
+		defer responses.Close()
+		service.SetResponseCache(responses)
+	}
 
 	switch *action {
 	case "index":
 		if *docPath == "" {
-			log.Fatal("Для действия 'index' требуется указать путь к документу (-doc)")
+			return fail("Для действия 'index' требуется указать путь к документу (-doc)")
 		}
 		if err := handleIndex(service, *docPath); err != nil {
-			log.Fatalf("Ошибка индексации документа: %v", err)
+			return fail("Ошибка индексации документа", "error", err)
+		}
+	case "ingest":
+		if *docPath == "" {
+			return fail("Для действия 'ingest' требуется указать каталог с документами (-doc)")
+		}
+		if *statePath == "" {
+			*statePath = cfg.Storage.DBPath + ".ingest"
+		}
+		if err := handleIngest(repo, service, cfg, *docPath, *statePath); err != nil {
+			return fail("Ошибка загрузки документов", "error", err)
 		}
 	case "search":
 		if *query == "" {
-			log.Fatal("Для действия 'search' требуется указать поисковый запрос (-query)")
+			return fail("Для действия 'search' требуется указать поисковый запрос (-query)")
+		}
+		opts := ai.PromptOptions{Template: *templateName, Language: *language}
+		if err := handleSearch(service, *query, opts, *format, service.Retrieval()); err != nil {
+			return fail("Ошибка поиска", "error", err)
 		}
-		if err := handleSearch(service, *query); err != nil {
-			log.Fatalf("Ошибка поиска: %v", err)
+	case "list":
+		filter := domain.DocumentFilter{IDPrefix: *prefix, Title: *titleFilter, Sort: *sortField, Desc: *desc}
+		if err := handleList(service, filter, *cursor, *pageSize, *format); err != nil {
+			return fail("Ошибка получения списка документов", "error", err)
 		}
+	case "show":
+		if *docID == "" {
+			return fail("Для действия 'show' требуется указать ID документа (-id)")
+		}
+		if err := handleShow(service, *docID, *format); err != nil {
+			return fail("Ошибка получения документа", "error", err)
+		}
+	case "delete":
+		if (*docID == "") == (*pattern == "") {
+			return fail("Для действия 'delete' требуется указать ID документа (-id) или шаблон (-pattern)")
+		}
+		if err := handleDelete(service, *docID, *pattern, *yes, *format); err != nil {
+			return fail("Ошибка удаления документов", "error", err)
+		}
+	case "stats":
+		if err := handleStats(repo, *format); err != nil {
+			return fail("Ошибка получения статистики", "error", err)
+		}
+	case "fsck":
+		if err := handleFsck(repo, *repair, *format); err != nil {
+			return fail("Ошибка проверки индекса", "error", err)
+		}
+	case "export":
+		if *filePath == "" {
+			return fail("Для действия 'export' требуется указать файл архива (-file)")
+		}
+		if err := handleExport(repo, *filePath); err != nil {
+			return fail("Ошибка экспорта", "error", err)
+		}
+	case "import":
+		if *filePath == "" {
+			return fail("Для действия 'import' требуется указать файл архива (-file)")
+		}
+		if err := handleImport(repo, service, cfg, *filePath, *policy); err != nil {
+			return fail("Ошибка импорта", "error", err)
+		}
+	case "backup":
+		if *filePath == "" {
+			return fail("Для действия 'backup' требуется указать файл резервной копии (-file)")
+		}
+		if err := repo.Backup(*filePath); err != nil {
+			return fail("Ошибка резервного копирования", "error", err)
+		}
+		fmt.Printf("Резервная копия сохранена в %s\n", *filePath)
 	case "demo":
 		if err := runDemo(service); err != nil {
-			log.Fatalf("Ошибка демонстрации: %v", err)
+			return fail("Ошибка демонстрации", "error", err)
 		}
 	case "serve":
-		fallthrough
+		if err := runServer(service, cfg, *configPath, logs, ragMetrics, tracer); err != nil {
+			return fail("Ошибка сервера", "error", err)
+		}
 	default:
-		fmt.Println("RAG система запущена. Используйте флаги для выполнения действий:")
+		fmt.Println("RAG система. Используйте флаги для выполнения действий:")
+		fmt.Println("  -action=serve                         # Запустить HTTP API, действие по умолчанию (конфигурация перезагружается без перезапуска)")
 		fmt.Println("  -action=index -doc=path/to/doc.txt     # Индексировать документ")
+		fmt.Println("  -action=ingest -doc=docs/ -workers=8  # Загрузить каталог (Ctrl-C - остановка, повторный запуск продолжит)")
 		fmt.Println("  -action=search -query='your query'    # Поиск по индексу")
+		fmt.Println("  -template=summarize -lang=en          # Шаблон промпта и язык ответа для search")
+		fmt.Println("  -format=json                          # Структурированный JSON ответ для search")
+		fmt.Println("  -limit=5 -threshold=0.1               # Параметры поиска (переопределяют config.yaml и RAG_*)")
+		fmt.Println("  -profile=draft                        # Профиль конфигурации (модель и параметры поиска)")
+		fmt.Println("  -action=list -sort=created -desc      # Список документов (-prefix, -title, -page-size, -cursor)")
+		fmt.Println("  -action=show -id=doc1                 # Документ и его фрагменты")
+		fmt.Println("  -action=delete -pattern='docs/*' -yes # Удалить документ по ID (-id) или все документы по шаблону")
+		fmt.Println("  -action=stats                         # Размер индекса и состояние FTS5")
+		fmt.Println("  -format=json                          # JSON вывод для list, show, delete, stats")
+		fmt.Println("  -action=fsck -repair                  # Проверить полнотекстовый индекс и перестроить при ошибках")
+		fmt.Println("  -action=migrate -dry-run              # Применить миграции схемы (-status - только состояние)")
+		fmt.Println("  -action=export -file=kb.tar.gz        # Экспортировать документы в архив (.tar.gz или .jsonl)")
+		fmt.Println("  -action=import -file=kb.tar.gz        # Импортировать архив (-policy=replace - удалить документы не из архива)")
+		fmt.Println("  -action=backup -file=backup.db        # Согласованная копия базы без остановки записи")
 		fmt.Println("  -action=demo                          # Запустить демо-сессию")
+		fmt.Println("  -action=config                        # Показать итоговую конфигурацию (секреты скрыты)")
+		fmt.Println("  -action=validate-config               # Проверить конфигурацию (код выхода 1 при ошибках)")
+	}
+	return 0
+}
+
+// modelActions действия, которые обращаются к модели и требуют настроенной секции ai
+var modelActions = map[string]bool{"serve": true, "search": true, "demo": true}
+
+// writeActions действия, изменяющие документы: кэшированные ответы по ним устаревают
+var writeActions = map[string]bool{"index": true, "ingest": true, "delete": true, "import": true}
+
+// fail пишет ошибку в журнал и возвращает код выхода 1
+func fail(msg string, args ...interface{}) int {
+	slog.Error(msg, args...)
+	return 1
+}
+
+// flagConfigPaths флаги, переопределяющие поля конфигурации; имеют наивысший приоритет
+var flagConfigPaths = map[string]string{
+	"db":        "storage.db_path",
+	"limit":     "retrieval.limit",
+	"threshold": "retrieval.threshold",
+	"workers":   "ingest.workers",
+	"profile":   "profile",
+}
+
+// loadConfig загружает конфигурацию и применяет явно заданные флаги, которые имеют наивысший приоритет.
+// Ошибка загрузки возвращается вместе с конфигурацией, чтобы validate-config мог сообщить обо всех проблемах.
+func loadConfig(path string) (config.Config, error) {
+	cfg, err := config.Load(path)
+	var flagErr error
+	flag.Visit(func(f *flag.Flag) {
+		if fieldPath, ok := flagConfigPaths[f.Name]; ok && flagErr == nil {
+			if setErr := cfg.Set(fieldPath, f.Value.String()); setErr != nil {
+				flagErr = fmt.Errorf("некорректное значение флага -%s: %w", f.Name, setErr)
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
 	}
+	return cfg, err
+}
+
+// runServer запускает HTTP API до SIGINT/SIGTERM. Файл конфигурации отслеживается (а также перечитывается
+// по SIGHUP): новая конфигурация проверяется и атомарно заменяет действующую без разрыва соединений.
+func runServer(service *application.RAGService, cfg config.Config, configPath string, logs *logging.Output, m *metrics.RAG, tracer *tracing.Tracer) error {
+	logger := logs.Logger()
+	server := api.NewServer(service, cfg.Server, logger, m)
+	server.SetTracer(tracer)
+
+	reloader := config.NewReloader(configPath, cfg,
+		func() (config.Config, error) {
+			next, err := loadConfig(configPath)
+			if err != nil {
+				return next, err
+			}
+			return next, next.Validate()
+		},
+		func(next config.Config) error {
+			if err := service.Reload(next); err != nil {
+				return err
+			}
+			server.Reload(next.Server)
+			logs.Apply(next)
+			return nil
+		},
+		logger.With("component", "config"))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go reloader.Run(ctx, hup)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
+	logger.Info("HTTP API запущен", "addr", cfg.Server.Addr)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Остановка сервера...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// handleConfig выводит итоговую конфигурацию после применения файла, окружения и флагов
+func handleConfig(cfg config.Config) error {
+	out, err := cfg.Masked().YAML()
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// handleValidateConfig проверяет конфигурацию целиком, включая шаблоны промптов, и возвращает все проблемы разом
+func handleValidateConfig(cfg config.Config, loadErr error) error {
+	var problems []config.Problem
+	var validationErr *config.ValidationError
+	if loadErr != nil {
+		if !errors.As(loadErr, &validationErr) {
+			return loadErr
+		}
+		problems = append(problems, validationErr.Problems...)
+	}
+	if errors.As(cfg.Validate(), &validationErr) {
+		problems = append(problems, validationErr.Problems...)
+	}
+	if cfg.Prompts.Dir != "" {
+		if _, err := ai.LoadPromptSet(cfg.Prompts.Dir, cfg.Prompts.Default, cfg.Prompts.Language); err != nil {
+			problems = append(problems, config.Problem{Path: "prompts.dir", Message: err.Error()})
+		}
+	}
+
+	if len(problems) > 0 {
+		return &config.ValidationError{Problems: problems}
+	}
+	return nil
 }
 
 // handleIndex индексирует документ
@@ -90,19 +409,412 @@ func handleIndex(service *application.RAGService, docPath string) error {
 	return nil
 }
 
+// handleIngest загружает каталог конвейером с выводом прогресса; Ctrl-C останавливает загрузку,
+// а повторный запуск продолжает ее по журналу состояния
+func handleIngest(repo *infrastructure.SQLiteDocumentRepository, service *application.RAGService, cfg config.Config, root, statePath string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pipeline := ingest.New(repo, ingest.Options{
+		Workers:    cfg.Ingest.Workers,
+		QueueSize:  cfg.Ingest.QueueSize,
+		BatchSize:  cfg.Storage.BatchSize,
+		Extensions: cfg.Ingest.Extensions,
+		StatePath:  statePath,
+		Progress: func(p ingest.Progress) {
+			eta := "-"
+			if d := p.ETA(); d > 0 {
+				eta = d.Round(time.Second).String()
+			}
+			fmt.Fprintf(os.Stderr, "\rЗагружено %d/%d, ошибок %d, %.1f файл/с, осталось %s   ",
+				p.Done, p.Total, p.Failed, p.Rate(), eta)
+		},
+	})
+	result, err := pipeline.Run(ctx, root)
+	fmt.Fprintln(os.Stderr)
+
+	// Перезаписанные документы могли устареть в кэше ответов
+	service.InvalidateDocuments(result.Indexed...)
+
+	fmt.Printf("Проиндексировано: %d, пропущено без изменений: %d, ошибок: %d, время: %s\n",
+		result.Done, result.Skipped, result.Failed, result.Elapsed.Round(time.Millisecond))
+	for _, docErr := range result.Errors {
+		fmt.Fprintf(os.Stderr, "  %s\n", docErr.Error())
+	}
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("загрузка прервана, повторный запуск продолжит с места остановки (журнал %s)", statePath)
+	}
+	if err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("не удалось загрузить файлов: %d", len(result.Errors))
+	}
+	return nil
+}
+
+// handleExport экспортирует документы базы в архив
+func handleExport(repo *infrastructure.SQLiteDocumentRepository, path string) error {
+	manifest, err := archive.ExportFile(repo, path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Экспортировано документов: %d, фрагментов: %d, архив: %s\n", manifest.Documents, manifest.Chunks, path)
+	return nil
+}
+
+// handleImport загружает архив и сбрасывает кэш ответов по перезаписанным и удаленным документам
+func handleImport(repo *infrastructure.SQLiteDocumentRepository, service *application.RAGService, cfg config.Config, path, policy string) error {
+	result, err := archive.ImportFile(repo, path, archive.ImportOptions{Policy: policy, BatchSize: cfg.Storage.BatchSize})
+	service.InvalidateDocuments(append(result.Imported, result.Deleted...)...)
+
+	fmt.Printf("Импортировано: %d из %d, удалено: %d, ошибок: %d\n",
+		len(result.Imported), result.Manifest.Documents, len(result.Deleted), len(result.Errors))
+	for _, docErr := range result.Errors {
+		fmt.Fprintf(os.Stderr, "  %s\n", docErr.Error())
+	}
+	if err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		if policy == archive.PolicyReplace {
+			return fmt.Errorf("не удалось импортировать документов: %d, документы не из архива не удалены", len(result.Errors))
+		}
+		return fmt.Errorf("не удалось импортировать документов: %d", len(result.Errors))
+	}
+	return nil
+}
+
 // handleSearch выполняет поиск и генерацию ответа
-func handleSearch(service *application.RAGService, query string) error {
+func handleSearch(service *application.RAGService, query string, opts ai.PromptOptions, format string, retrieval config.RetrievalConfig) error {
+	if format == "json" {
+		answer, err := service.SearchAndGenerateStructured(context.Background(), query, retrieval.Limit, retrieval.Threshold, opts)
+		if err != nil {
+			return fmt.Errorf("ошибка поиска и генерации: %w", err)
+		}
+
+		return printJSON(answer)
+	}
+
 	fmt.Printf("Выполняем поиск по запросу: '%s'\n", query)
 
-	response, err := service.SearchAndGenerate(query, 5, 0.1)
+	result, err := service.SearchAndGenerateWithOptions(context.Background(), query, retrieval.Limit, retrieval.Threshold, opts)
 	if err != nil {
 		return fmt.Errorf("ошибка поиска и генерации: %w", err)
 	}
 
-	fmt.Printf("Ответ: %s\n", response)
+	fmt.Printf("Ответ: %s\n", result.Text)
+	return nil
+}
+
+// printJSON выводит значение в формате JSON с отступами
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
+
+// tableFormat сообщает, выводить ли результат таблицей (text, table) или JSON (json)
+func tableFormat(format string) (bool, error) {
+	switch format {
+	case "", "text", "table":
+		return true, nil
+	case "json":
+		return false, nil
+	default:
+		return false, fmt.Errorf("неизвестный формат %q (допустимо: table, json)", format)
+	}
+}
+
+// handleList выводит страницу списка документов
+func handleList(service *application.RAGService, filter domain.DocumentFilter, cursor string, limit int, format string) error {
+	table, err := tableFormat(format)
+	if err != nil {
+		return err
+	}
+	page, err := service.ListDocuments(filter, cursor, limit)
+	if err != nil {
+		return err
+	}
+	if !table {
+		return printJSON(page)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tНАЗВАНИЕ\tСОЗДАН\tРАЗМЕР\tФРАГМЕНТОВ")
+	for _, doc := range page.Documents {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", doc.ID, trimString(doc.Title, 40),
+			formatTime(doc.CreatedAt), formatBytes(int64(doc.Size)), doc.Chunks)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nПоказано %d из %d документов\n", len(page.Documents), page.Total)
+	if page.NextCursor != "" {
+		fmt.Printf("Следующая страница: -cursor=%s\n", page.NextCursor)
+	}
+	return nil
+}
+
+// handleShow выводит документ и его фрагменты
+func handleShow(service *application.RAGService, id, format string) error {
+	table, err := tableFormat(format)
+	if err != nil {
+		return err
+	}
+	doc, err := service.GetDocument(id)
+	if err != nil {
+		return err
+	}
+	chunks, err := service.GetChunks(id)
+	if err != nil {
+		return err
+	}
+	if !table {
+		return printJSON(struct {
+			*domain.Document
+			Chunks []domain.Chunk `json:"chunks"`
+		}{doc, chunks})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", doc.ID)
+	fmt.Fprintf(w, "Название:\t%s\n", doc.Title)
+	fmt.Fprintf(w, "Создан:\t%s\n", formatTime(doc.CreatedAt))
+	fmt.Fprintf(w, "Размер:\t%s\n", formatBytes(int64(len(doc.Content))))
+	fmt.Fprintf(w, "Фрагментов:\t%d\n", len(chunks))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for i, chunk := range chunks {
+		fmt.Printf("\n[%d] %s (%s)\n%s\n", i+1, chunk.ID, formatBytes(int64(len(chunk.Content))), chunk.Content)
+	}
 	return nil
 }
 
+// handleDelete удаляет документ по ID или документы по шаблону. Удаление нескольких документов по шаблону
+// требует подтверждения флагом -yes; без него выводится список документов, которые были бы удалены.
+func handleDelete(service *application.RAGService, id, pattern string, yes bool, format string) error {
+	table, err := tableFormat(format)
+	if err != nil {
+		return err
+	}
+
+	// ID удаляется только при точном совпадении, даже если содержит *, ? или [
+	ids := []string{id}
+	if pattern != "" {
+		if ids, err = service.MatchDocumentIDs(pattern); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return fmt.Errorf("нет документов по шаблону %q", pattern)
+		}
+		if !yes {
+			for _, id := range ids {
+				fmt.Println(id)
+			}
+			return fmt.Errorf("по шаблону %q найдено документов: %d; для удаления добавьте -yes", pattern, len(ids))
+		}
+	} else if _, err := service.GetDocument(id); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := service.DeleteDocument(id); err != nil {
+			return fmt.Errorf("документ %s: %w", id, err)
+		}
+		if table {
+			fmt.Printf("Удален документ: %s\n", id)
+		}
+	}
+	if !table {
+		return printJSON(map[string][]string{"deleted": ids})
+	}
+	return nil
+}
+
+// handleStats выводит размер индекса и состояние полнотекстового поиска
+func handleStats(repo *infrastructure.SQLiteDocumentRepository, format string) error {
+	table, err := tableFormat(format)
+	if err != nil {
+		return err
+	}
+	stats, err := repo.Stats()
+	if err != nil {
+		return err
+	}
+	if !table {
+		return printJSON(stats)
+	}
+
+	search := "FTS5"
+	if !stats.FTS5 {
+		search = "LIKE (FTS5 не поддерживается)"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Документов:\t%d\n", stats.Documents)
+	fmt.Fprintf(w, "Фрагментов:\t%d\n", stats.Chunks)
+	fmt.Fprintf(w, "Средний размер фрагмента:\t%s\n", formatBytes(int64(stats.AvgChunkSize)))
+	fmt.Fprintf(w, "Размер базы данных:\t%s\n", formatBytes(stats.Bytes))
+	fmt.Fprintf(w, "Полнотекстовый поиск:\t%s\n", search)
+	return w.Flush()
+}
+
+// handleFsck проверяет полнотекстовый индекс; с repair перестраивает его при найденных ошибках
+func handleFsck(repo *infrastructure.SQLiteDocumentRepository, repair bool, format string) error {
+	table, err := tableFormat(format)
+	if err != nil {
+		return err
+	}
+	check, err := repo.CheckIndex()
+	if err != nil {
+		return err
+	}
+	repaired := false
+	if !check.OK && repair {
+		if err := repo.RebuildIndex(); err != nil {
+			return err
+		}
+		repaired = true
+		if check, err = repo.CheckIndex(); err != nil {
+			return err
+		}
+	}
+
+	if !table {
+		if err := printJSON(check); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if !check.FTS5 {
+			fmt.Fprintln(w, "Полнотекстовый поиск:\tLIKE (FTS5 не поддерживается), проверять нечего")
+		}
+		fmt.Fprintf(w, "Фрагментов:\t%d\n", check.Chunks)
+		if check.FTS5 {
+			fmt.Fprintf(w, "В индексе:\t%d\n", check.Indexed)
+			fmt.Fprintf(w, "Нет в индексе:\t%d %v\n", len(check.Missing), check.Missing)
+			fmt.Fprintf(w, "Устаревших записей:\t%d %v\n", len(check.Stale), check.Stale)
+			if check.Integrity != "" {
+				fmt.Fprintf(w, "integrity-check:\t%s\n", check.Integrity)
+			}
+		}
+		if repaired {
+			fmt.Fprintln(w, "Индекс перестроен:\tда")
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if !check.OK {
+		if repaired {
+			return errors.New("индекс несогласован и после перестроения")
+		}
+		return errors.New("индекс несогласован, для исправления запустите с -repair")
+	}
+	return nil
+}
+
+// sqliteOptions параметры соединений SQLite из секции storage
+func sqliteOptions(c config.StorageConfig) infrastructure.SQLiteOptions {
+	return infrastructure.SQLiteOptions{
+		JournalMode: c.JournalMode,
+		Synchronous: c.Synchronous,
+		BusyTimeout: c.BusyTimeout,
+		CacheSizeKB: c.CacheSizeKB,
+		MmapSize:    c.MmapSize,
+		ReadConns:   c.ReadConnections,
+		BusyRetries: c.BusyRetries,
+	}
+}
+
+// handleMigrate показывает состояние миграций схемы или применяет непримененные
+// Для status и dry-run база открывается только для чтения и должна существовать
+func handleMigrate(storage config.StorageConfig, statusOnly, dryRun bool, format string) error {
+	table, err := tableFormat(format)
+	if err != nil {
+		return err
+	}
+	db, err := infrastructure.OpenSQLite(storage.DBPath, sqliteOptions(storage), statusOnly || dryRun)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(migrations.Embedded())
+	if err != nil {
+		return err
+	}
+
+	if statusOnly {
+		states, err := migrator.Status(db)
+		if err != nil {
+			return err
+		}
+		if !table {
+			return printJSON(states)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ВЕРСИЯ\tНАЗВАНИЕ\tПРИМЕНЕНА")
+		for _, state := range states {
+			applied := "нет"
+			if state.Applied {
+				applied = formatTime(state.AppliedAt)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", state.Version, state.Name, applied)
+		}
+		return w.Flush()
+	}
+
+	var list []migrations.Migration
+	if dryRun {
+		list, err = migrator.Pending(db)
+	} else {
+		list, err = migrator.Up(db)
+	}
+	if err != nil {
+		return err
+	}
+	if !table {
+		return printJSON(map[string]interface{}{"dry_run": dryRun, "migrations": append([]migrations.Migration{}, list...)})
+	}
+	if len(list) == 0 {
+		fmt.Println("Схема актуальна, миграций для применения нет")
+		return nil
+	}
+	for _, m := range list {
+		if dryRun {
+			fmt.Printf("-- Будет применена %04d_%s\n%s\n", m.Version, m.Name, strings.TrimSpace(m.SQL))
+		} else {
+			fmt.Printf("Применена миграция %04d_%s\n", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// formatTime форматирует время создания документа для таблицы
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatBytes форматирует размер в байтах в единицах КиБ и МиБ
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f МиБ", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f КиБ", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d Б", n)
+	}
+}
+
 // runDemo запускает демо-сессию
 func runDemo(service *application.RAGService) error {
 	fmt.Println("=== Демонстрация RAG системы ===")
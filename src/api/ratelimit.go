@@ -0,0 +1,75 @@
+package api
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket корзина токенов одного клиента
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter ограничивает частоту запросов каждого клиента алгоритмом корзины токенов.
+// Лимиты можно менять на лету, накопленные корзины при этом сохраняются.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // Токенов в секунду (0 - без ограничения)
+	burst   float64
+	buckets map[string]*bucket
+}
+
+// NewRateLimiter создает ограничитель: rate запросов в секунду с всплеском до burst (0 - равен rate)
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	l := &RateLimiter{buckets: make(map[string]*bucket)}
+	l.SetLimit(rate, burst)
+	return l
+}
+
+// SetLimit меняет лимиты; используется при перезагрузке конфигурации
+func (l *RateLimiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = float64(burst)
+	if l.burst <= 0 {
+		l.burst = math.Max(1, math.Ceil(rate))
+	}
+}
+
+// Allow расходует токен клиента key. Если токенов нет, возвращает false и время до появления следующего.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+
+	// Корзины давно не обращавшихся клиентов полны и не нужны
+	if len(l.buckets) > 10000 {
+		for k, other := range l.buckets {
+			if now.Sub(other.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	return true, 0
+}
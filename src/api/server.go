@@ -0,0 +1,330 @@
+// Package api HTTP интерфейс RAG системы для режима serve
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"rag-system/src/application"
+	"rag-system/src/config"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ai"
+	"rag-system/src/infrastructure/logging"
+	"rag-system/src/infrastructure/metrics"
+	"rag-system/src/infrastructure/tracing"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxBodyBytes максимальный размер тела запроса
+const maxBodyBytes = 10 << 20
+
+// Server HTTP сервер RAG системы
+type Server struct {
+	service  *application.RAGService
+	limiter  *RateLimiter
+	settings atomic.Pointer[config.ServerConfig] // Действующие настройки, заменяются при перезагрузке
+	http     *http.Server
+	logger   *slog.Logger
+	metrics  *metrics.RAG
+	tracer   *tracing.Tracer
+}
+
+// SearchRequest тело запроса POST /search
+type SearchRequest struct {
+	Query     string   `json:"query"`
+	Limit     int      `json:"limit,omitempty"`     // 0 - retrieval.limit из конфигурации
+	Threshold *float64 `json:"threshold,omitempty"` // Не задан - retrieval.threshold из конфигурации
+	Template  string   `json:"template,omitempty"`
+	Language  string   `json:"language,omitempty"`
+	Format    string   `json:"format,omitempty"`  // text (по умолчанию) или json
+	Profile   string   `json:"profile,omitempty"` // Профиль из server.allowed_profiles; пусто - профиль по умолчанию
+}
+
+// SearchResponse ответ POST /search в формате text
+type SearchResponse struct {
+	Answer      string   `json:"answer"`
+	FromCache   bool     `json:"from_cache"`
+	Grounding   *float64 `json:"grounding_score,omitempty"`
+	Unsupported []string `json:"unsupported_claims,omitempty"`
+}
+
+// NewServer создает HTTP сервер с параметрами из секции server. Если m не nil, метрики отдаются на /metrics.
+func NewServer(service *application.RAGService, cfg config.ServerConfig, logger *slog.Logger, m *metrics.RAG) *Server {
+	s := &Server{
+		service: service,
+		limiter: NewRateLimiter(cfg.RateLimit, cfg.RateBurst),
+		logger:  logger.With("component", "api"),
+		metrics: m,
+	}
+	s.settings.Store(&cfg)
+	s.http = &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      s.Handler(),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+	return s
+}
+
+// SetTracer включает трассировку запросов к API. Вызывается до ListenAndServe.
+func (s *Server) SetTracer(t *tracing.Tracer) {
+	s.tracer = t
+}
+
+// Reload применяет новые лимиты частоты запросов и список разрешенных профилей;
+// адрес и таймауты меняются только при перезапуске
+func (s *Server) Reload(cfg config.ServerConfig) {
+	s.limiter.SetLimit(cfg.RateLimit, cfg.RateBurst)
+	s.settings.Store(&cfg)
+}
+
+// profileAllowed сообщает, можно ли выбрать профиль в запросе к API
+func (s *Server) profileAllowed(name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, allowed := range s.settings.Load().AllowedProfiles {
+		if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler возвращает обработчик всех маршрутов API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.Handle("/search", s.rateLimited(http.HandlerFunc(s.handleSearch)))
+	mux.Handle("/documents", s.rateLimited(http.HandlerFunc(s.handleDocuments)))
+	if s.metrics != nil {
+		mux.Handle("/metrics", s.metrics.Registry().Handler())
+	}
+	return s.logged(mux)
+}
+
+// routes маршруты API для метки path метрик; остальные пути учитываются как other
+var routes = map[string]bool{"/health": true, "/search": true, "/documents": true, "/metrics": true}
+
+// ListenAndServe принимает соединения до вызова Shutdown
+func (s *Server) ListenAndServe() error {
+	if err := s.http.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("ошибка HTTP сервера: %w", err)
+	}
+	return nil
+}
+
+// Shutdown останавливает сервер, дожидаясь завершения текущих запросов
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.http.Shutdown(ctx)
+}
+
+// logged присваивает запросу идентификатор (из заголовка X-Request-ID или новый), возвращает его в ответе,
+// учитывает запрос в метриках и пишет в журнал итог обработки. Идентификатор передается через контекст во все записи журнала о запросе.
+// Если трассировка включена, запрос обрабатывается в span, продолжающем трассу из заголовка traceparent.
+func (s *Server) logged(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		ctx := logging.WithRequestID(r.Context(), id)
+		w.Header().Set("X-Request-ID", id)
+
+		path := r.URL.Path
+		if !routes[path] {
+			path = "other"
+		}
+		var span *tracing.Span
+		if s.tracer != nil {
+			ctx, span = s.tracer.Start(tracing.Extract(ctx, r.Header), r.Method+" "+path,
+				tracing.String("http.method", r.Method), tracing.String("http.route", path), tracing.String("request.id", id))
+			span.SetKind(tracing.KindServer)
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(tracing.Int("http.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("HTTP %d", recorder.status))
+		}
+		span.End()
+
+		s.metrics.HTTPRequest(r.Method, path, recorder.status)
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		s.logger.LogAttrs(ctx, level, "HTTP запрос", slog.String("method", r.Method), slog.String("path", r.URL.Path),
+			slog.Int("status", recorder.status), slog.Duration("duration", time.Since(start)))
+	})
+}
+
+// validRequestID проверяет идентификатор запроса клиента: не длиннее 64 символов из букв, цифр, '-', '_' и '.'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder запоминает код ответа для журнала
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// rateLimited отклоняет запросы сверх server.rate_limit с кодом 429
+func (s *Server) rateLimited(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if ok, wait := s.limiter.Allow(host); !ok {
+			w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+			s.writeError(w, http.StatusTooManyRequests, errors.New("превышен лимит запросов"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleHealth GET /health
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleSearch POST /search - поиск с генерацией ответа
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("метод %s не поддерживается", r.Method))
+		return
+	}
+
+	var req SearchRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("поле query обязательно"))
+		return
+	}
+
+	if !s.profileAllowed(req.Profile) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("профиль %q не разрешен для запросов API", req.Profile))
+		return
+	}
+
+	// Параметры поиска по умолчанию берутся из действующей конфигурации выбранного профиля
+	// и меняются при перезагрузке
+	retrieval, err := s.service.RetrievalFor(req.Profile)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = retrieval.Limit
+	}
+	threshold := retrieval.Threshold
+	if req.Threshold != nil {
+		threshold = *req.Threshold
+	}
+	opts := ai.PromptOptions{Template: req.Template, Language: req.Language, Profile: req.Profile}
+
+	switch req.Format {
+	case "json":
+		answer, err := s.service.SearchAndGenerateStructured(r.Context(), req.Query, req.Limit, threshold, opts)
+		if err != nil {
+			s.writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, answer)
+	case "", "text":
+		result, err := s.service.SearchAndGenerateWithOptions(r.Context(), req.Query, req.Limit, threshold, opts)
+		if err != nil {
+			s.writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		resp := SearchResponse{Answer: result.Text, FromCache: result.Metrics.FromCache}
+		if result.Grounding != nil {
+			resp.Grounding = &result.Grounding.Score
+			resp.Unsupported = result.Grounding.Unsupported()
+		}
+		s.writeJSON(w, http.StatusOK, resp)
+	default:
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("неизвестный формат %q (допустимо: text, json)", req.Format))
+	}
+}
+
+// handleDocuments POST /documents - индексация документа
+func (s *Server) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("метод %s не поддерживается", r.Method))
+		return
+	}
+
+	var doc domain.Document
+	if err := decodeBody(w, r, &doc); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if doc.ID == "" || strings.TrimSpace(doc.Content) == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("поля id и content обязательны"))
+		return
+	}
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now()
+	}
+
+	if err := s.service.IndexDocument(doc); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, map[string]string{"id": doc.ID})
+}
+
+// decodeBody декодирует JSON тело запроса, отклоняя неизвестные поля
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("некорректное тело запроса: %w", err)
+	}
+	return nil
+}
+
+// writeJSON записывает ответ в формате JSON
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Warn("Ошибка записи ответа", "error", err)
+	}
+}
+
+// writeError записывает ошибку в формате {"error": "..."}
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, map[string]string{"error": err.Error()})
+}
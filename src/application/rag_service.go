@@ -1,36 +1,197 @@
 package application
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"rag-system/src/config"
 	"rag-system/src/domain"
 	"rag-system/src/infrastructure/ai"
+	"rag-system/src/infrastructure/cache"
+	"rag-system/src/infrastructure/metrics"
+	"rag-system/src/infrastructure/tracing"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // RAGService реализация сервиса RAG
 type RAGService struct {
-	repo domain.DocumentRepository
-	ai   *ai.AIClient
+	repo      domain.DocumentRepository
+	ai        *ai.AIClient                  // nil для действий, которые не обращаются к модели
+	responses cache.Cache                   // Кэш ответов для сброса без AI клиента; nil - сбрасывает AI клиент
+	cfg       atomic.Pointer[config.Config] // Действующая конфигурация (параметры поиска, профили), заменяется при перезагрузке
+	logger    *slog.Logger
+	metrics   *metrics.RAG
+	tracer    *tracing.Tracer
 }
 
 // NewRAGService создает новый экземпляр RAG сервиса
 func NewRAGService(repo domain.DocumentRepository, ai *ai.AIClient) *RAGService {
-	return &RAGService{
-		repo: repo,
-		ai:   ai,
+	s := &RAGService{
+		repo:   repo,
+		ai:     ai,
+		logger: slog.Default(),
 	}
+	s.SetConfig(config.Default())
+	return s
 }
 
-// IndexDocument индексирует документ для поиска
+// SetLogger задает журнал сервиса
+func (s *RAGService) SetLogger(logger *slog.Logger) {
+	s.logger = logger.With("component", "rag")
+}
+
+// SetMetrics задает метрики сервиса (длительность поиска)
+func (s *RAGService) SetMetrics(m *metrics.RAG) {
+	s.metrics = m
+}
+
+// SetTracer задает трассировку: запросы вне трассы HTTP API начинают новую трассу
+func (s *RAGService) SetTracer(t *tracing.Tracer) {
+	s.tracer = t
+}
+
+// SetResponseCache задает хранилище кэша ответов, которое сбрасывается при изменении документов, когда AI клиента нет:
+// действиям, которые только изменяют документы, модель не нужна, а сохраненные по документам ответы устаревают
+func (s *RAGService) SetResponseCache(c cache.Cache) {
+	s.responses = c
+}
+
+// SetConfig задает конфигурацию сервиса без перенастройки AI клиента
+func (s *RAGService) SetConfig(cfg config.Config) {
+	s.cfg.Store(&cfg)
+}
+
+// Retrieval возвращает действующие параметры поиска по умолчанию (с учетом профиля по умолчанию)
+func (s *RAGService) Retrieval() config.RetrievalConfig {
+	retrieval, err := s.RetrievalFor("")
+	if err != nil {
+		return s.cfg.Load().Retrieval
+	}
+	return retrieval
+}
+
+// RetrievalFor возвращает параметры поиска по умолчанию для профиля; пустое имя - профиль по умолчанию
+func (s *RAGService) RetrievalFor(profile string) (config.RetrievalConfig, error) {
+	cfg := s.cfg.Load()
+	if profile == "" {
+		profile = cfg.Profile
+	}
+	profiled, err := cfg.WithProfile(profile)
+	if err != nil {
+		return config.RetrievalConfig{}, err
+	}
+	return profiled.Retrieval, nil
+}
+
+// Reload применяет новую конфигурацию к сервису и AI клиенту без перезапуска.
+// Если конфигурация невалидна, ничего не меняется.
+func (s *RAGService) Reload(cfg config.Config) error {
+	if err := cfg.Validate("retrieval", "profiles"); err != nil {
+		return err
+	}
+	if s.ai != nil {
+		if err := s.ai.Reload(cfg); err != nil {
+			return err
+		}
+	}
+	s.SetConfig(cfg)
+	return nil
+}
+
+// IndexDocument индексирует документ для поиска.
+// Документ с уже существующим ID обновляется, поэтому кэшированные ответы по нему сбрасываются.
 func (s *RAGService) IndexDocument(doc domain.Document) error {
-	return s.repo.SaveDocument(doc)
+	_, err := s.repo.GetDocument(doc.ID)
+	switch {
+	case err == nil:
+		return s.UpdateDocument(doc)
+	case !errors.Is(err, domain.ErrDocumentNotFound):
+		return fmt.Errorf("ошибка проверки документа: %w", err)
+	}
+	if err := s.repo.SaveDocument(doc); err != nil {
+		return err
+	}
+	s.InvalidateDocuments(doc.ID)
+	return nil
 }
 
-// Search ищет релевантную информацию по запросу
+// IndexDocuments индексирует документы пакетами (storage.batch_size документов в транзакции).
+// Ошибки отдельных документов возвращаются как *domain.BulkError, остальные документы индексируются
+func (s *RAGService) IndexDocuments(docs []domain.Document) error {
+	err := s.repo.SaveDocuments(docs)
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	s.InvalidateDocuments(ids...)
+	return err
+}
+
+// UpdateDocument заменяет содержимое документа и сбрасывает кэшированные ответы, основанные на нем
+func (s *RAGService) UpdateDocument(doc domain.Document) error {
+	if err := s.repo.UpdateDocument(doc); err != nil {
+		return fmt.Errorf("ошибка обновления документа: %w", err)
+	}
+	s.InvalidateDocuments(doc.ID)
+	return nil
+}
+
+// DeleteDocument удаляет документ и кэшированные ответы, основанные на нем
+func (s *RAGService) DeleteDocument(id string) error {
+	if err := s.repo.DeleteDocument(id); err != nil {
+		return fmt.Errorf("ошибка удаления документа: %w", err)
+	}
+	s.InvalidateDocuments(id)
+	return nil
+}
+
+// InvalidateDocuments сбрасывает кэшированные ответы по документам; ошибка кэша не влияет на изменение данных
+func (s *RAGService) InvalidateDocuments(ids ...string) {
+	if len(ids) == 0 {
+		return
+	}
+	var err error
+	switch {
+	case s.ai != nil:
+		_, err = s.ai.InvalidateDocuments(ids...)
+	case s.responses != nil:
+		_, err = s.responses.InvalidateTags(ids...)
+	}
+	if err != nil {
+		s.logger.Warn("Не удалось сбросить кэш для документов", "documents", strings.Join(ids, ", "), "error", err)
+	}
+}
+
+// Search ищет релевантную информацию по запросу; при limit <= 0 используется retrieval.limit из конфигурации
 func (s *RAGService) Search(query string, limit int, threshold float64) (*domain.SearchResult, error) {
+	return s.search(context.Background(), query, limit, threshold, "")
+}
+
+// search ищет фрагменты в span repository.FindRelevantChunks активной трассы.
+// При limit <= 0 используется retrieval.limit профиля profile.
+func (s *RAGService) search(ctx context.Context, query string, limit int, threshold float64, profile string) (*domain.SearchResult, error) {
+	if limit <= 0 {
+		retrieval, err := s.RetrievalFor(profile)
+		if err != nil {
+			return nil, err
+		}
+		limit = retrieval.Limit
+	}
+	_, span := tracing.Start(ctx, "repository.FindRelevantChunks",
+		tracing.Int("search.limit", limit), tracing.Float64("search.threshold", threshold))
+	defer span.End()
+
+	start := time.Now()
 	chunks, err := s.repo.FindRelevantChunks(query, limit, threshold)
+	s.metrics.ObserveSearch(time.Since(start))
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("ошибка поиска: %w", err)
 	}
+	span.SetAttributes(tracing.Int("chunks.count", len(chunks)))
 
 	result := &domain.SearchResult{
 		Chunks: chunks,
@@ -52,21 +213,106 @@ func (s *RAGService) GenerateResponse(query string, chunks []domain.Chunk) (stri
 
 // SearchAndGenerate объединяет поиск и генерацию ответа
 func (s *RAGService) SearchAndGenerate(query string, limit int, threshold float64) (string, error) {
-	searchResult, err := s.Search(query, limit, threshold)
+	result, err := s.SearchAndGenerateWithOptions(context.Background(), query, limit, threshold, ai.PromptOptions{})
 	if err != nil {
-		return "", fmt.Errorf("ошибка поиска: %w", err)
+		return "", err
 	}
 
+	return result.Text, nil
+}
+
+// SearchAndGenerateWithOptions объединяет поиск и генерацию с выбором шаблона промпта, языка ответа и истории диалога
+func (s *RAGService) SearchAndGenerateWithOptions(ctx context.Context, query string, limit int, threshold float64, opts ai.PromptOptions) (result *ai.GenerateResult, err error) {
+	ctx, span := s.startSpan(ctx, "rag.SearchAndGenerate", query, limit, threshold, opts)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	searchResult, err := s.search(ctx, query, limit, threshold, opts.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска: %w", err)
+	}
+	span.SetAttributes(tracing.Int("chunks.count", len(searchResult.Chunks)))
+
 	if len(searchResult.Chunks) == 0 {
-		return "Не найдено релевантной информации для запроса.", nil
+		return &ai.GenerateResult{Text: "Не найдено релевантной информации для запроса."}, nil
 	}
 
-	response, err := s.GenerateResponse(query, searchResult.Chunks)
+	result, err = s.ai.Generate(ctx, ai.GenerateRequest{
+		Query:         query,
+		Chunks:        searchResult.Chunks,
+		PromptOptions: opts,
+	})
 	if err != nil {
-		return "", fmt.Errorf("ошибка генерации ответа: %w", err)
+		return nil, fmt.Errorf("ошибка генерации ответа: %w", err)
 	}
+	span.SetAttributes(tracing.Bool("cache.hit", result.Metrics.FromCache))
 
-	return response, nil
+	return result, nil
+}
+
+// SearchAndGenerateStructured объединяет поиск и генерацию ответа в формате JSON, проверенного по схеме
+func (s *RAGService) SearchAndGenerateStructured(ctx context.Context, query string, limit int, threshold float64, opts ai.PromptOptions) (_ *domain.StructuredAnswer, err error) {
+	ctx, span := s.startSpan(ctx, "rag.SearchAndGenerateStructured", query, limit, threshold, opts)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	searchResult, err := s.search(ctx, query, limit, threshold, opts.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска: %w", err)
+	}
+	span.SetAttributes(tracing.Int("chunks.count", len(searchResult.Chunks)))
+
+	if len(searchResult.Chunks) == 0 {
+		return &domain.StructuredAnswer{
+			Answer:  "Не найдено релевантной информации для запроса.",
+			Sources: []string{},
+		}, nil
+	}
+
+	var answer domain.StructuredAnswer
+	_, err = s.ai.GenerateStructured(ctx, ai.GenerateRequest{
+		Query:         query,
+		Chunks:        searchResult.Chunks,
+		PromptOptions: opts,
+	}, ai.AnswerSchema, &answer)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации структурированного ответа: %w", err)
+	}
+
+	return &answer, nil
+}
+
+// startSpan начинает span запроса к RAG: дочерний для span HTTP API или корневой новой трассы
+func (s *RAGService) startSpan(ctx context.Context, name, query string, limit int, threshold float64, opts ai.PromptOptions) (context.Context, *tracing.Span) {
+	return s.tracer.Start(ctx, name,
+		tracing.Int("query.length", len([]rune(query))),
+		tracing.Int("search.limit", limit),
+		tracing.Float64("search.threshold", threshold),
+		tracing.String("profile", opts.Profile))
+}
+
+// GetDocument возвращает документ по ID
+func (s *RAGService) GetDocument(id string) (*domain.Document, error) {
+	return s.repo.GetDocument(id)
+}
+
+// GetChunks возвращает фрагменты документа
+func (s *RAGService) GetChunks(docID string) ([]domain.Chunk, error) {
+	return s.repo.GetChunks(docID)
+}
+
+// ListDocuments возвращает страницу списка документов без содержимого
+func (s *RAGService) ListDocuments(filter domain.DocumentFilter, cursor string, limit int) (domain.DocumentPage, error) {
+	return s.repo.ListDocuments(filter, cursor, limit)
+}
+
+// MatchDocumentIDs возвращает ID документов, подходящих под шаблон GLOB
+func (s *RAGService) MatchDocumentIDs(pattern string) ([]string, error) {
+	return s.repo.MatchDocumentIDs(pattern)
 }
 
 // GetAllDocuments возвращает все документы
@@ -7,12 +7,27 @@ type DocumentService interface {
 	// IndexDocument индексирует документ для поиска
 	IndexDocument(doc domain.Document) error
 
+	// IndexDocuments индексирует документы пакетами
+	IndexDocuments(docs []domain.Document) error
+
 	// Search ищет релевантную информацию по запросу
 	Search(query string, limit int, threshold float64) (*domain.SearchResult, error)
 
 	// GenerateResponse генерирует ответ на основе найденных фрагментов
 	GenerateResponse(query string, chunks []domain.Chunk) (string, error)
 
+	// UpdateDocument заменяет содержимое документа
+	UpdateDocument(doc domain.Document) error
+
+	// GetDocument возвращает документ по ID
+	GetDocument(id string) (*domain.Document, error)
+
+	// ListDocuments возвращает страницу списка документов без содержимого
+	ListDocuments(filter domain.DocumentFilter, cursor string, limit int) (domain.DocumentPage, error)
+
+	// DeleteDocument удаляет документ по ID
+	DeleteDocument(id string) error
+
 	// GetAllDocuments возвращает все документы
 	GetAllDocuments() ([]domain.Document, error)
 }
@@ -0,0 +1,264 @@
+// Package config единая типизированная конфигурация всех подсистем RAG.
+// Источники применяются по возрастанию приоритета: значения по умолчанию, файл YAML,
+// переменные окружения RAG_*, флаги командной строки.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config конфигурация приложения
+type Config struct {
+	Storage       StorageConfig       `yaml:"storage"`
+	Chunking      ChunkingConfig      `yaml:"chunking"`
+	Ingest        IngestConfig        `yaml:"ingest"`
+	Retrieval     RetrievalConfig     `yaml:"retrieval"`
+	AI            GenerationConfig    `yaml:"ai"`
+	Prompts       PromptsConfig       `yaml:"prompts"`
+	Security      SecurityConfig      `yaml:"security"`
+	Grounding     GroundingConfig     `yaml:"grounding"`
+	Privacy       PrivacyConfig       `yaml:"privacy"`
+	Cache         CacheConfig         `yaml:"cache"`
+	SemanticCache SemanticCacheConfig `yaml:"semantic_cache"`
+	Server        ServerConfig        `yaml:"server"`
+	Logging       LoggingConfig       `yaml:"logging"`
+	Tracing       TracingConfig       `yaml:"tracing"`
+
+	Profile  string             `yaml:"profile"`  // Профиль по умолчанию (флаг -profile); пусто - без профиля
+	Profiles map[string]Profile `yaml:"profiles"` // Именованные переопределения секций ai и retrieval
+
+	file    string            // Путь к файлу, из которого загружена конфигурация
+	origins map[string]string // Источник значения поля (файл:строка или переменная окружения) для сообщений об ошибках
+}
+
+// StorageConfig хранилище документов
+type StorageConfig struct {
+	DBPath    string `yaml:"db_path"`    // Путь к файлу базы SQLite
+	BatchSize int    `yaml:"batch_size"` // Документов в транзакции при пакетной индексации
+
+	JournalMode     string        `yaml:"journal_mode"`     // Режим журнала SQLite: wal, delete, truncate, persist, memory, off
+	Synchronous     string        `yaml:"synchronous"`      // PRAGMA synchronous: off, normal, full, extra
+	BusyTimeout     time.Duration `yaml:"busy_timeout"`     // Ожидание блокировки, занятой другим соединением или процессом
+	CacheSizeKB     int           `yaml:"cache_size_kb"`    // Кэш страниц на соединение в КиБ (0 - по умолчанию SQLite)
+	MmapSize        int64         `yaml:"mmap_size"`        // Объем файла, читаемый через mmap, в байтах (0 - не использовать)
+	ReadConnections int           `yaml:"read_connections"` // Соединений для чтения; запись всегда идет через одно соединение
+	BusyRetries     int           `yaml:"busy_retries"`     // Повторов транзакции записи при SQLITE_BUSY (0 - по умолчанию 3)
+}
+
+// ChunkingConfig разбиение документов на фрагменты
+type ChunkingConfig struct {
+	Size int `yaml:"size"` // Максимальный размер фрагмента в байтах
+}
+
+// IngestConfig конвейер загрузки каталогов (-action=ingest)
+type IngestConfig struct {
+	Workers    int      `yaml:"workers"`    // Воркеров чтения и разбиения файлов на фрагменты
+	QueueSize  int      `yaml:"queue_size"` // Емкость очередей между стадиями конвейера
+	Extensions []string `yaml:"extensions"` // Расширения загружаемых файлов
+}
+
+// RetrievalConfig поиск релевантных фрагментов
+type RetrievalConfig struct {
+	Limit     int     `yaml:"limit"`     // Максимум фрагментов в контексте
+	Threshold float64 `yaml:"threshold"` // Минимальная релевантность фрагмента
+}
+
+// GenerationConfig параметры AI API и генерации ответа (секция ai)
+type GenerationConfig struct {
+	BaseURL     string        `yaml:"base_url"`
+	APIKey      string        `yaml:"api_key" secret:"true"` // Ключ или ссылка file:/путь, env:ИМЯ, exec:команда
+	Model       string        `yaml:"model"`
+	TimeoutSecs int           `yaml:"timeout"` // Таймаут одного запроса в секундах
+	MaxTokens   int           `yaml:"max_tokens"`
+	Temperature float64       `yaml:"temperature"`
+	MaxRetries  int           `yaml:"max_retries"` // Повторы при 429/5xx и сетевых ошибках (0 - без повторов)
+	RetryDelay  time.Duration `yaml:"retry_delay"` // Начальная задержка между повторами, удваивается (0 - без паузы)
+
+	JSONMode          string `yaml:"json_mode"`          // instructions, json_object, json_schema
+	StructuredRetries int    `yaml:"structured_retries"` // Повторы при ответе не по схеме (0 - без повторов)
+}
+
+// PromptsConfig шаблоны промптов
+type PromptsConfig struct {
+	Dir      string `yaml:"dir"`      // Директория с шаблонами *.tmpl (относительно файла конфигурации)
+	Default  string `yaml:"default"`  // Имя шаблона по умолчанию
+	Language string `yaml:"language"` // Язык ответа по умолчанию
+}
+
+// SecurityConfig защита от внедрения инструкций через документы
+type SecurityConfig struct {
+	FenceContext bool `yaml:"fence_context"` // Оборачивать найденные фрагменты в маркеры с неподделываемой меткой
+	Injection    struct {
+		Policy    string  `yaml:"policy"`    // off, annotate, drop, quarantine
+		Threshold float64 `yaml:"threshold"` // Порог оценки подозрительности (0..1]
+	} `yaml:"injection"`
+}
+
+// GroundingConfig проверка обоснованности ответов
+type GroundingConfig struct {
+	Mode       string  `yaml:"mode"`        // off, lexical, llm
+	Policy     string  `yaml:"policy"`      // annotate, refuse
+	Threshold  float64 `yaml:"threshold"`   // Минимальная доля подтвержденных утверждений (0 - принимать любой ответ)
+	MinOverlap float64 `yaml:"min_overlap"` // Доля слов утверждения, найденных во фрагменте (режим lexical)
+}
+
+// PrivacyConfig маскирование персональных данных
+type PrivacyConfig struct {
+	Redaction struct {
+		Enabled bool     `yaml:"enabled"` // Маскировать персональные данные перед отправкой во внешний API
+		Types   []string `yaml:"types"`   // email, phone, card, iban, passport (пусто - все)
+	} `yaml:"redaction"`
+}
+
+// CacheConfig кэш ответов AI
+type CacheConfig struct {
+	Backend    string        `yaml:"backend"`     // file, memory, sqlite, off (по умолчанию file)
+	Dir        string        `yaml:"dir"`         // Директория файлового кэша
+	Path       string        `yaml:"path"`        // Файл базы данных для sqlite
+	TTL        time.Duration `yaml:"ttl"`         // Время жизни записи (0 - без ограничения)
+	MaxEntries int           `yaml:"max_entries"` // Максимум записей (0 - без ограничения)
+	MaxBytes   int64         `yaml:"max_bytes"`   // Максимальный суммарный размер значений в байтах (0 - без ограничения)
+}
+
+// SemanticCacheConfig семантический кэш ответов
+type SemanticCacheConfig struct {
+	Enabled         bool    `yaml:"enabled"`           // Переиспользовать ответы на перефразированные вопросы
+	Threshold       float64 `yaml:"threshold"`         // Минимальное косинусное сходство запросов
+	MinChunkOverlap float64 `yaml:"min_chunk_overlap"` // Минимальная доля общих найденных фрагментов
+	MaxEntries      int     `yaml:"max_entries"`       // Максимум запомненных запросов
+}
+
+// ServerConfig HTTP сервер
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	RateLimit       float64       `yaml:"rate_limit"`       // Запросов в секунду с одного клиента (0 - без ограничения)
+	RateBurst       int           `yaml:"rate_burst"`       // Допустимый всплеск запросов (0 - равен rate_limit)
+	AllowedProfiles []string      `yaml:"allowed_profiles"` // Профили, которые можно выбрать в запросе к API (пусто - выбор запрещен)
+	ReloadInterval  time.Duration `yaml:"reload_interval"`  // Период проверки изменения файла конфигурации (0 - только по SIGHUP)
+}
+
+// LoggingConfig журналирование
+type LoggingConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn, error
+	Format string `yaml:"format"` // text или json
+}
+
+// TracingConfig трассировка этапов обработки запроса
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Exporter    string  `yaml:"exporter"`     // file или otlp
+	Path        string  `yaml:"path"`         // Файл для exporter: file (JSON, одна строка на span)
+	Endpoint    string  `yaml:"endpoint"`     // Адрес коллектора OTLP/HTTP для exporter: otlp, например http://localhost:4318
+	ServiceName string  `yaml:"service_name"` // Имя сервиса в экспортируемых span
+	SampleRatio float64 `yaml:"sample_ratio"` // Доля трассируемых запросов без входящего контекста трассировки, от 0 до 1
+}
+
+// Default возвращает конфигурацию со значениями по умолчанию
+func Default() Config {
+	var c Config
+	c.Storage.DBPath = "./rag_system.db"
+	c.Storage.BatchSize = 100
+	c.Storage.JournalMode = "wal"
+	c.Storage.Synchronous = "normal"
+	c.Storage.BusyTimeout = 5 * time.Second
+	c.Storage.ReadConnections = 4
+	c.Storage.BusyRetries = 3
+	c.Chunking.Size = 500
+	c.Ingest.Workers = 4
+	c.Ingest.QueueSize = 64
+	c.Ingest.Extensions = []string{".txt", ".md"}
+	c.Retrieval.Limit = 5
+	c.Retrieval.Threshold = 0.1
+	c.AI.TimeoutSecs = 30
+	c.AI.MaxTokens = 500
+	c.AI.Temperature = 0.1
+	c.AI.MaxRetries = 3
+	c.AI.RetryDelay = 2 * time.Second
+	c.AI.JSONMode = "instructions"
+	c.AI.StructuredRetries = 2
+	c.Prompts.Default = "qa"
+	c.Prompts.Language = "ru"
+	c.Security.FenceContext = true
+	c.Security.Injection.Policy = "annotate"
+	c.Security.Injection.Threshold = 0.5
+	c.Grounding.Threshold = 0.5
+	c.Grounding.MinOverlap = 0.6
+	c.Cache.Backend = "file"
+	c.Cache.Dir = "./cache/ai"
+	c.Cache.Path = "./cache/ai_cache.db"
+	c.Server.Addr = ":8080"
+	c.Server.ReadTimeout = 10 * time.Second
+	c.Server.WriteTimeout = 60 * time.Second
+	c.Server.ReloadInterval = 2 * time.Second
+	c.Logging.Level = "info"
+	c.Logging.Format = "text"
+	c.Tracing.Exporter = "file"
+	c.Tracing.Path = "./traces.jsonl"
+	c.Tracing.Endpoint = "http://localhost:4318"
+	c.Tracing.ServiceName = "rag-system"
+	c.Tracing.SampleRatio = 1
+	return c
+}
+
+// Load читает конфигурацию: значения по умолчанию, затем файл, затем переменные окружения RAG_*.
+// Ссылки на секреты (file:, env:, exec:) разрешаются через DefaultSecretProviders.
+// Неизвестные ключи и значения неподходящего типа считаются ошибкой: возвращается *ValidationError
+// со всеми найденными проблемами и конфигурация, заполненная корректными полями.
+// Значения не проверяются, для этого используется Validate.
+func Load(path string) (Config, error) {
+	c := Default()
+	c.file = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("ошибка чтения файла конфигурации: %w", err)
+	}
+	problems := decodeStrict(data, path, &c)
+
+	if err := ApplyEnv(&c, os.Environ()); err != nil {
+		problems = append(problems, Problem{Message: err.Error()})
+	}
+	var secretsErr *ValidationError
+	if errors.As(ResolveSecrets(&c, DefaultSecretProviders()), &secretsErr) {
+		problems = append(problems, secretsErr.Problems...)
+	}
+	problems = append(problems, resolveProfileSecrets(&c, DefaultSecretProviders())...)
+
+	// Относительные пути в конфигурации отсчитываются от директории файла конфигурации
+	if c.Prompts.Dir != "" && !filepath.IsAbs(c.Prompts.Dir) {
+		c.Prompts.Dir = filepath.Join(filepath.Dir(path), c.Prompts.Dir)
+	}
+
+	return c, problemsError(problems)
+}
+
+// origin возвращает карту источников значений, создавая ее при первом обращении
+func (c *Config) origin() map[string]string {
+	if c.origins == nil {
+		c.origins = make(map[string]string)
+	}
+	return c.origins
+}
+
+// Masked возвращает копию конфигурации, в которой значения полей с тегом secret скрыты
+func (c Config) Masked() Config {
+	maskSecrets(&c)
+	return c
+}
+
+// YAML возвращает конфигурацию в формате YAML
+func (c Config) YAML() (string, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации конфигурации: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix префикс переменных окружения, переопределяющих конфигурацию.
+// Имя переменной составляется из пути к полю в YAML: ai.base_url -> RAG_AI_BASE_URL.
+const EnvPrefix = "RAG_"
+
+// legacyEnv переменные окружения, поддерживаемые для совместимости; RAG_* имеют приоритет
+var legacyEnv = map[string]string{
+	"AI_API_KEY":  "RAG_AI_API_KEY",
+	"AI_MODEL":    "RAG_AI_MODEL",
+	"AI_BASE_URL": "RAG_AI_BASE_URL",
+}
+
+// secretMask значение, которым заменяются секреты при выводе конфигурации
+const secretMask = "********"
+
+// durationType тип time.Duration, который задается строкой вида 30s или 24h
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// walkFields вызывает fn для каждого конечного поля конфигурации с путем из YAML имен
+func walkFields(v reflect.Value, path []string, fn func(path []string, field reflect.Value, tag reflect.StructField) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" || !sf.IsExported() {
+			continue
+		}
+
+		field := v.Field(i)
+		fieldPath := append(append([]string(nil), path...), name)
+		if field.Kind() == reflect.Struct {
+			if err := walkFields(field, fieldPath, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(fieldPath, field, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnvName возвращает имя переменной окружения для пути к полю (например, "ai.base_url")
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(path))
+}
+
+// ApplyEnv переопределяет поля конфигурации из переменных окружения RAG_* (environ в формате os.Environ)
+func ApplyEnv(c *Config, environ []string) error {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	for legacy, name := range legacyEnv {
+		if value, ok := env[legacy]; ok && value != "" {
+			if _, set := env[name]; !set {
+				env[name] = value
+			}
+		}
+	}
+
+	return walkFields(reflect.ValueOf(c).Elem(), nil, func(path []string, field reflect.Value, _ reflect.StructField) error {
+		fieldPath := strings.Join(path, ".")
+		name := EnvName(fieldPath)
+		value, ok := env[name]
+		if !ok {
+			return nil
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("переменная окружения %s: %w", name, err)
+		}
+		c.origin()[fieldPath] = name
+		return nil
+	})
+}
+
+// flagOrigin источник значений, заданных через Set; такие значения не переопределяются профилями
+const flagOrigin = "флаг командной строки"
+
+// Set присваивает полю с путем path (например, "retrieval.limit") значение из строки.
+// Используется для переопределения конфигурации флагами командной строки.
+func (c *Config) Set(path, value string) error {
+	found := false
+	err := walkFields(reflect.ValueOf(c).Elem(), nil, func(fieldPath []string, field reflect.Value, _ reflect.StructField) error {
+		if strings.Join(fieldPath, ".") != path {
+			return nil
+		}
+		found = true
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("поле %s: %w", path, err)
+		}
+		c.origin()[path] = flagOrigin
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("неизвестное поле конфигурации %q", path)
+	}
+	return nil
+}
+
+// setField присваивает полю значение из строки
+func setField(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("ожидается длительность вида 30s или 24h: %w", err)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("ожидается true или false: %w", err)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ожидается целое число: %w", err)
+		}
+		field.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("ожидается число: %w", err)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("неподдерживаемый тип %s", field.Type())
+		}
+		// Список задается через запятую
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", field.Type())
+	}
+	return nil
+}
+
+// maskSecrets заменяет непустые значения полей с тегом secret:"true", в том числе в профилях
+func maskSecrets(c *Config) {
+	maskProfileSecrets(c)
+	walkFields(reflect.ValueOf(c).Elem(), nil, func(_ []string, field reflect.Value, sf reflect.StructField) error {
+		if sf.Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "" {
+			field.SetString(secretMask)
+		}
+		return nil
+	})
+}
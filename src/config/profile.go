@@ -0,0 +1,210 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile именованный набор переопределений секций ai и retrieval, например дешевая модель для черновиков
+// и дорогая для ответов клиентам. Переопределяются только ключи, указанные в профиле.
+type Profile struct {
+	node *yaml.Node // Исходный узел YAML; ссылки на секреты в нем уже разрешены
+}
+
+// profileSchema допустимые секции профиля
+type profileSchema struct {
+	AI        GenerationConfig `yaml:"ai"`
+	Retrieval RetrievalConfig  `yaml:"retrieval"`
+}
+
+// profilesType тип поля Config.Profiles, декодируется отдельно от остальных полей
+var profilesType = reflect.TypeOf(map[string]Profile(nil))
+
+// UnmarshalYAML запоминает узел профиля; ключи проверяются при загрузке конфигурации
+func (p *Profile) UnmarshalYAML(node *yaml.Node) error {
+	p.node = node
+	return nil
+}
+
+// MarshalYAML выводит профиль в исходном виде
+func (p Profile) MarshalYAML() (interface{}, error) {
+	if p.node == nil {
+		return map[string]interface{}{}, nil
+	}
+	return p.node, nil
+}
+
+// decodeProfiles проверяет ключи каждого профиля и сохраняет их узлы
+func decodeProfiles(node *yaml.Node, field reflect.Value, path []string, file string, problems *[]Problem) {
+	if node.Kind != yaml.MappingNode {
+		if node.Tag != "!!null" {
+			*problems = append(*problems, Problem{Path: strings.Join(path, "."), Source: fmt.Sprintf("%s:%d", file, node.Line),
+				Message: "ожидается секция с профилями"})
+		}
+		return
+	}
+
+	profiles := make(map[string]Profile, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, value := node.Content[i].Value, node.Content[i+1]
+		var schema profileSchema
+		decodeNode(value, reflect.ValueOf(&schema).Elem(), append(append([]string(nil), path...), name), file,
+			make(map[string]string), problems)
+		profiles[name] = Profile{node: value}
+	}
+	field.Set(reflect.ValueOf(profiles))
+}
+
+// ProfileNames возвращает имена профилей по алфавиту
+func (c Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithProfile возвращает конфигурацию с переопределениями профиля name поверх текущих значений
+// секций ai и retrieval (включая переменные окружения и флаги). Пустое имя возвращает конфигурацию без изменений.
+func (c Config) WithProfile(name string) (Config, error) {
+	if name == "" {
+		return c, nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return c, fmt.Errorf("неизвестный профиль %q (доступны: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	// Источники значений копируются, чтобы ошибки проверки указывали на строки профиля
+	origins := make(map[string]string, len(c.origins))
+	for k, v := range c.origins {
+		origins[k] = v
+	}
+
+	var problems []Problem
+	schema := profileSchema{AI: c.AI, Retrieval: c.Retrieval}
+	if profile.node != nil {
+		decodeNode(profile.node, reflect.ValueOf(&schema).Elem(), nil, c.file, origins, &problems)
+	}
+	if err := problemsError(problems); err != nil {
+		return c, fmt.Errorf("профиль %s: %w", name, err)
+	}
+
+	// Значения, заданные флагами командной строки, имеют наивысший приоритет и профилем не переопределяются
+	base := profileSchema{AI: c.AI, Retrieval: c.Retrieval}
+	baseValues := fieldValues(reflect.ValueOf(&base).Elem())
+	for path, field := range fieldValues(reflect.ValueOf(&schema).Elem()) {
+		if c.origins[path] == flagOrigin {
+			field.Set(baseValues[path])
+			origins[path] = flagOrigin
+		}
+	}
+
+	c.AI, c.Retrieval = schema.AI, schema.Retrieval
+	c.Profile = name
+	c.origins = origins
+	return c, nil
+}
+
+// fieldValues возвращает конечные поля структуры по путям из YAML имен
+func fieldValues(v reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	walkFields(v, nil, func(path []string, field reflect.Value, _ reflect.StructField) error {
+		fields[strings.Join(path, ".")] = field
+		return nil
+	})
+	return fields
+}
+
+// resolveProfileSecrets разрешает ссылки на секреты в профилях, заменяя значения в их узлах
+func resolveProfileSecrets(c *Config, providers map[string]SecretProvider) []Problem {
+	var problems []Problem
+	for _, name := range c.ProfileNames() {
+		eachSecretNode(c.Profiles[name].node, reflect.TypeOf(profileSchema{}), func(path string, value *yaml.Node) {
+			scheme, ref, ok := strings.Cut(value.Value, ":")
+			provider, known := providers[scheme]
+			if !ok || !known {
+				return
+			}
+			secret, err := provider.Resolve(strings.TrimSpace(ref))
+			if err != nil {
+				problems = append(problems, Problem{Path: "profiles." + name + "." + path,
+					Source:  fmt.Sprintf("%s:%d", c.file, value.Line),
+					Message: fmt.Sprintf("не удалось получить секрет через %s: %v", scheme, err)})
+				return
+			}
+			value.Value = secret
+		})
+	}
+	return problems
+}
+
+// eachSecretNode вызывает fn для скалярных значений узла, соответствующих полям с тегом secret:"true"
+func eachSecretNode(node *yaml.Node, t reflect.Type, fn func(path string, value *yaml.Node)) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		for j := 0; j < t.NumField(); j++ {
+			sf := t.Field(j)
+			if strings.Split(sf.Tag.Get("yaml"), ",")[0] != key.Value {
+				continue
+			}
+			if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+				eachSecretNode(value, sf.Type, func(path string, v *yaml.Node) { fn(key.Value+"."+path, v) })
+			} else if sf.Tag.Get("secret") == "true" && value.Kind == yaml.ScalarNode {
+				fn(key.Value, value)
+			}
+		}
+	}
+}
+
+// cloneNode возвращает глубокую копию узла YAML
+func cloneNode(node *yaml.Node) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	clone := *node
+	clone.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		clone.Content[i] = cloneNode(child)
+	}
+	return &clone
+}
+
+// profileSecrets возвращает значения секретов всех профилей
+func (c Config) profileSecrets() []string {
+	var secrets []string
+	for _, name := range c.ProfileNames() {
+		eachSecretNode(c.Profiles[name].node, reflect.TypeOf(profileSchema{}), func(_ string, value *yaml.Node) {
+			if value.Value != "" {
+				secrets = append(secrets, value.Value)
+			}
+		})
+	}
+	return secrets
+}
+
+// maskProfileSecrets заменяет секреты в копиях узлов профилей
+func maskProfileSecrets(c *Config) {
+	if len(c.Profiles) == 0 {
+		return
+	}
+	masked := make(map[string]Profile, len(c.Profiles))
+	for name, profile := range c.Profiles {
+		node := cloneNode(profile.node)
+		eachSecretNode(node, reflect.TypeOf(profileSchema{}), func(_ string, value *yaml.Node) {
+			if value.Value != "" {
+				value.Value = secretMask
+			}
+		})
+		masked[name] = Profile{node: node}
+	}
+	c.Profiles = masked
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// restartOnly поля, изменение которых вступает в силу только после перезапуска процесса
+var restartOnly = []string{"storage.", "chunking.", "cache.", "server.addr", "server.read_timeout",
+	"server.write_timeout", "server.reload_interval", "logging.format", "tracing."}
+
+// RestartRequired возвращает пути полей, которые изменились между old и new, но не применяются без перезапуска
+func RestartRequired(old, new Config) []string {
+	oldValues := make(map[string]interface{})
+	walkFields(reflect.ValueOf(&old).Elem(), nil, func(path []string, field reflect.Value, _ reflect.StructField) error {
+		oldValues[strings.Join(path, ".")] = field.Interface()
+		return nil
+	})
+
+	var changed []string
+	walkFields(reflect.ValueOf(&new).Elem(), nil, func(path []string, field reflect.Value, _ reflect.StructField) error {
+		fieldPath := strings.Join(path, ".")
+		for _, prefix := range restartOnly {
+			if strings.HasPrefix(fieldPath, prefix) && !reflect.DeepEqual(oldValues[fieldPath], field.Interface()) {
+				changed = append(changed, fieldPath)
+				break
+			}
+		}
+		return nil
+	})
+	return changed
+}
+
+// Reloader следит за файлом конфигурации и шаблонами промптов и применяет новую конфигурацию без перезапуска.
+// Перезагрузка запускается при изменении времени модификации файлов или по сигналу (обычно SIGHUP).
+// Невалидная конфигурация не применяется: продолжает действовать прежняя, ошибка пишется в журнал.
+type Reloader struct {
+	Path     string
+	Interval time.Duration          // Период проверки файла (0 - только по сигналу)
+	Load     func() (Config, error) // Загрузка с переопределениями окружения и флагов и проверка
+	Apply    func(Config) error     // Атомарная замена конфигурации в компонентах
+	Logger   *slog.Logger
+
+	mu       sync.Mutex
+	current  Config
+	modTimes map[string]time.Time // Время модификации отслеживаемых файлов при последней загрузке
+}
+
+// NewReloader создает Reloader для уже примененной конфигурации current
+func NewReloader(path string, current Config, load func() (Config, error), apply func(Config) error, logger *slog.Logger) *Reloader {
+	r := &Reloader{
+		Path:     path,
+		Interval: current.Server.ReloadInterval,
+		Load:     load,
+		Apply:    apply,
+		Logger:   logger,
+		current:  current,
+	}
+	r.modTimes = r.watched(current)
+	return r
+}
+
+// watched возвращает время модификации файла конфигурации и шаблонов промптов из cfg.Prompts.Dir.
+// Добавленные и удаленные шаблоны меняют набор ключей, поэтому тоже считаются изменением.
+func (r *Reloader) watched(cfg Config) map[string]time.Time {
+	paths := []string{r.Path}
+	if cfg.Prompts.Dir != "" {
+		templates, _ := filepath.Glob(filepath.Join(cfg.Prompts.Dir, "*.tmpl"))
+		paths = append(paths, templates...)
+	}
+
+	modTimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// Reload загружает и применяет конфигурацию. При ошибке действующая конфигурация не меняется.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Запоминаем состояние файлов до загрузки, чтобы невалидные изменения не перезагружались на каждой проверке
+	r.modTimes = r.watched(r.current)
+
+	next, err := r.Load()
+	if err != nil {
+		return fmt.Errorf("новая конфигурация не применена: %w", err)
+	}
+	if err := r.Apply(next); err != nil {
+		return fmt.Errorf("новая конфигурация не применена: %w", err)
+	}
+
+	if changed := RestartRequired(r.current, next); len(changed) > 0 {
+		r.Logger.Warn("Изменения вступят в силу после перезапуска", "fields", strings.Join(changed, ", "))
+	}
+	r.current = next
+	r.modTimes = r.watched(next)
+	r.Logger.Info("Конфигурация перезагружена", "path", r.Path)
+	return nil
+}
+
+// Current возвращает последнюю примененную конфигурацию
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// changed сообщает, изменились ли файл конфигурации или шаблоны промптов с последней загрузки
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes := r.watched(r.current)
+	if _, ok := modTimes[r.Path]; !ok {
+		// Файл конфигурации временно отсутствует (например, заменяется редактором)
+		return false
+	}
+	if len(modTimes) != len(r.modTimes) {
+		return true
+	}
+	for path, modTime := range modTimes {
+		if previous, ok := r.modTimes[path]; !ok || !modTime.Equal(previous) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run следит за файлом и сигналами до отмены ctx
+func (r *Reloader) Run(ctx context.Context, signals <-chan os.Signal) {
+	var tick <-chan time.Time
+	if r.Interval > 0 {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			r.Logger.Info("Получен сигнал: перезагрузка конфигурации", "signal", sig.String())
+		case <-tick:
+			if !r.changed() {
+				continue
+			}
+			r.Logger.Info("Файлы конфигурации изменены: перезагрузка", "path", r.Path)
+		}
+		if err := r.Reload(); err != nil {
+			r.Logger.Error("Ошибка перезагрузки конфигурации, продолжает действовать прежняя", "error", err)
+		}
+	}
+}
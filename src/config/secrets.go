@@ -0,0 +1,154 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SecretProvider получает значение секрета по ссылке (часть после "схема:")
+type SecretProvider interface {
+	Resolve(ref string) (string, error)
+}
+
+// FileSecretProvider читает секрет из файла (file:/run/secrets/ai_key), например смонтированного Kubernetes Secret
+type FileSecretProvider struct{}
+
+// Resolve читает файл и убирает завершающий перевод строки
+func (FileSecretProvider) Resolve(ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", fmt.Errorf("не удалось прочитать файл секрета: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvSecretProvider берет секрет из переменной окружения с произвольным именем (env:OPENAI_API_KEY)
+type EnvSecretProvider struct{}
+
+// Resolve возвращает значение переменной окружения
+func (EnvSecretProvider) Resolve(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("переменная окружения %s не установлена", ref)
+	}
+	return value, nil
+}
+
+// ExecSecretProvider получает секрет из стандартного вывода команды (exec:vault kv get -field=key secret/rag).
+// Команда запускается без оболочки, аргументы разделяются пробелами.
+type ExecSecretProvider struct {
+	Timeout time.Duration // 0 - по умолчанию 10s
+}
+
+// Resolve выполняет команду и возвращает ее вывод без завершающего перевода строки
+func (p ExecSecretProvider) Resolve(ref string) (string, error) {
+	args := strings.Fields(ref)
+	if len(args) == 0 {
+		return "", fmt.Errorf("не указана команда")
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Вывод команды может содержать секрет, поэтому в ошибку попадает только stderr
+		return "", fmt.Errorf("команда %s завершилась с ошибкой: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// DefaultSecretProviders провайдеры, доступные по умолчанию, по схеме ссылки
+func DefaultSecretProviders() map[string]SecretProvider {
+	return map[string]SecretProvider{
+		"file": FileSecretProvider{},
+		"env":  EnvSecretProvider{},
+		"exec": ExecSecretProvider{},
+	}
+}
+
+// ResolveSecrets заменяет ссылки вида "схема:значение" в полях с тегом secret:"true" значениями секретов.
+// Значения без известной схемы считаются самим секретом. Ошибки не содержат значений секретов.
+func ResolveSecrets(c *Config, providers map[string]SecretProvider) error {
+	var problems []Problem
+	walkFields(reflect.ValueOf(c).Elem(), nil, func(path []string, field reflect.Value, sf reflect.StructField) error {
+		if sf.Tag.Get("secret") != "true" || field.Kind() != reflect.String {
+			return nil
+		}
+		scheme, ref, ok := strings.Cut(field.String(), ":")
+		provider, known := providers[scheme]
+		if !ok || !known {
+			return nil
+		}
+
+		fieldPath := strings.Join(path, ".")
+		value, err := provider.Resolve(strings.TrimSpace(ref))
+		if err != nil {
+			problems = append(problems, Problem{Path: fieldPath, Source: c.origins[fieldPath],
+				Message: fmt.Sprintf("не удалось получить секрет через %s: %v", scheme, err)})
+			return nil
+		}
+		field.SetString(value)
+		return nil
+	})
+	return problemsError(problems)
+}
+
+// Secrets возвращает непустые значения всех секретов конфигурации, например для маскирования в логах
+func (c Config) Secrets() []string {
+	var secrets []string
+	walkFields(reflect.ValueOf(&c).Elem(), nil, func(_ []string, field reflect.Value, sf reflect.StructField) error {
+		if sf.Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "" {
+			secrets = append(secrets, field.String())
+		}
+		return nil
+	})
+	return append(secrets, c.profileSecrets()...)
+}
+
+// LogWriter оборачивает w так, что секреты этой конфигурации не попадают в журнал
+func (c Config) LogWriter(w io.Writer) io.Writer {
+	return NewMaskingWriter(w, c.Secrets()...)
+}
+
+// maskingWriter заменяет значения секретов в записываемых данных
+type maskingWriter struct {
+	w        io.Writer
+	replacer *strings.Replacer
+}
+
+// NewMaskingWriter возвращает io.Writer, который заменяет секреты на "********" перед записью в w.
+// Предназначен для логгеров: пакет log передает каждую строку одним вызовом Write.
+func NewMaskingWriter(w io.Writer, secrets ...string) io.Writer {
+	var pairs []string
+	for _, secret := range secrets {
+		if secret != "" && secret != secretMask {
+			pairs = append(pairs, secret, secretMask)
+		}
+	}
+	if len(pairs) == 0 {
+		return w
+	}
+	return &maskingWriter{w: w, replacer: strings.NewReplacer(pairs...)}
+}
+
+// Write записывает данные со скрытыми секретами; возвращает длину исходных данных, как того ожидает log
+func (m *maskingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(m.w, m.replacer.Replace(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
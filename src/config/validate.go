@@ -0,0 +1,405 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem одна проблема конфигурации
+type Problem struct {
+	Path    string // Путь к полю, например ai.max_tokens
+	Source  string // Откуда взято значение: файл:строка или имя переменной окружения
+	Message string
+}
+
+// String форматирует проблему в виде "config.yaml:12: ai.max_tokens: сообщение"
+func (p Problem) String() string {
+	parts := make([]string, 0, 3)
+	if p.Source != "" {
+		parts = append(parts, p.Source)
+	}
+	if p.Path != "" {
+		parts = append(parts, p.Path)
+	}
+	return strings.Join(append(parts, p.Message), ": ")
+}
+
+// ValidationError все найденные проблемы конфигурации
+type ValidationError struct {
+	Problems []Problem
+}
+
+// Error перечисляет все проблемы, по одной на строку
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  " + p.String()
+	}
+	return "конфигурация невалидна:\n" + strings.Join(lines, "\n")
+}
+
+// problemsError возвращает *ValidationError или nil, если проблем нет
+func problemsError(problems []Problem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+// yamlLinePrefix префикс "line N: " в сообщениях yaml.v3
+var yamlLinePrefix = regexp.MustCompile(`^(?:yaml: )?line \d+: `)
+
+// decodeStrict разбирает YAML в c. В отличие от yaml.Unmarshal неизвестные ключи считаются ошибкой,
+// а каждое поле декодируется отдельно, чтобы сообщить обо всех проблемах сразу с номерами строк.
+func decodeStrict(data []byte, file string, c *Config) []Problem {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []Problem{{Source: file, Message: fmt.Sprintf("ошибка парсинга YAML: %v", err)}}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	var problems []Problem
+	decodeNode(doc.Content[0], reflect.ValueOf(c).Elem(), nil, file, c.origin(), &problems)
+	return problems
+}
+
+// decodeNode сопоставляет ключи узла-отображения полям структуры v
+func decodeNode(node *yaml.Node, v reflect.Value, path []string, file string, origins map[string]string, problems *[]Problem) {
+	source := func(n *yaml.Node) string { return fmt.Sprintf("%s:%d", file, n.Line) }
+
+	if node.Kind != yaml.MappingNode {
+		if node.Tag == "!!null" {
+			return
+		}
+		*problems = append(*problems, Problem{Path: strings.Join(path, "."), Source: source(node),
+			Message: "ожидается секция с ключами"})
+		return
+	}
+
+	fields := make(map[string]int, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if name := strings.Split(sf.Tag.Get("yaml"), ",")[0]; name != "" && name != "-" && sf.IsExported() {
+			fields[name] = i
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		fieldPath := append(append([]string(nil), path...), key.Value)
+		joined := strings.Join(fieldPath, ".")
+
+		index, ok := fields[key.Value]
+		if !ok {
+			message := fmt.Sprintf("неизвестный ключ %q", key.Value)
+			if suggestion := closestKey(key.Value, fields); suggestion != "" {
+				message += fmt.Sprintf(" (возможно, %s)", suggestion)
+			}
+			*problems = append(*problems, Problem{Path: joined, Source: source(key), Message: message})
+			continue
+		}
+
+		field := v.Field(index)
+		if field.Type() == profilesType {
+			decodeProfiles(value, field, fieldPath, file, problems)
+			continue
+		}
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			decodeNode(value, field, fieldPath, file, origins, problems)
+			continue
+		}
+
+		origins[joined] = source(value)
+		if err := value.Decode(field.Addr().Interface()); err != nil {
+			*problems = append(*problems, Problem{Path: joined, Source: source(value),
+				Message: "некорректное значение: " + yamlErrorMessage(err)})
+		}
+	}
+}
+
+// yamlErrorMessage убирает из сообщения yaml.v3 номер строки, который уже есть в Problem.Source
+func yamlErrorMessage(err error) string {
+	var messages []string
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+	for i, m := range messages {
+		messages[i] = yamlLinePrefix.ReplaceAllString(m, "")
+	}
+	return strings.Join(messages, "; ")
+}
+
+// closestKey возвращает известный ключ, отличающийся от key не более чем на две правки
+func closestKey(key string, fields map[string]int) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance расстояние Левенштейна
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// validator накапливает проблемы проверки значений
+type validator struct {
+	c        *Config
+	problems []Problem
+}
+
+// fail добавляет проблему для поля path
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Path: path, Source: v.c.origins[path], Message: fmt.Sprintf(format, args...)})
+}
+
+// require проверяет, что строковое поле задано
+func (v *validator) require(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(path, "обязательное поле не задано (переменная окружения %s)", EnvName(path))
+	}
+}
+
+// positive проверяет, что число больше нуля
+func (v *validator) positive(path string, value int64) {
+	if value <= 0 {
+		v.fail(path, "должно быть положительным, текущее значение: %d", value)
+	}
+}
+
+// nonNegative проверяет, что число не отрицательное
+func (v *validator) nonNegative(path string, value int64) {
+	if value < 0 {
+		v.fail(path, "не может быть отрицательным, текущее значение: %d", value)
+	}
+}
+
+// between проверяет, что значение в диапазоне [lo, hi]
+func (v *validator) between(path string, value, lo, hi float64) {
+	if value < lo || value > hi {
+		v.fail(path, "должно быть в диапазоне [%g, %g], текущее значение: %g", lo, hi, value)
+	}
+}
+
+// oneOf проверяет, что значение входит в список допустимых (пустое значение означает значение по умолчанию)
+func (v *validator) oneOf(path, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(path, "недопустимое значение %q (допустимо: %s)", value, strings.Join(allowed, ", "))
+}
+
+// Validate проверяет значения указанных секций (без аргументов - всех) и возвращает *ValidationError
+// со всеми найденными проблемами
+func (c Config) Validate(sections ...string) error {
+	v := &validator{c: &c}
+	if len(sections) == 0 {
+		sections = sectionOrder
+	}
+	for _, name := range sections {
+		check, ok := sectionValidators[name]
+		if !ok {
+			v.fail(name, "неизвестная секция конфигурации")
+			continue
+		}
+		check(v, &c)
+	}
+	return problemsError(v.problems)
+}
+
+// sectionOrder порядок проверки секций, совпадает с порядком в config.yaml
+var sectionOrder = []string{"storage", "chunking", "ingest", "retrieval", "ai", "security", "grounding", "privacy",
+	"cache", "semantic_cache", "server", "logging", "tracing", "profiles"}
+
+// sectionValidators правила проверки каждой секции
+var sectionValidators = map[string]func(v *validator, c *Config){
+	"storage": func(v *validator, c *Config) {
+		v.require("storage.db_path", c.Storage.DBPath)
+		v.positive("storage.batch_size", int64(c.Storage.BatchSize))
+		v.oneOf("storage.journal_mode", c.Storage.JournalMode, "wal", "delete", "truncate", "persist", "memory", "off")
+		v.oneOf("storage.synchronous", c.Storage.Synchronous, "off", "normal", "full", "extra")
+		v.nonNegative("storage.busy_timeout", int64(c.Storage.BusyTimeout))
+		v.nonNegative("storage.cache_size_kb", int64(c.Storage.CacheSizeKB))
+		v.nonNegative("storage.mmap_size", c.Storage.MmapSize)
+		v.positive("storage.read_connections", int64(c.Storage.ReadConnections))
+		v.nonNegative("storage.busy_retries", int64(c.Storage.BusyRetries))
+	},
+	"chunking": func(v *validator, c *Config) {
+		v.positive("chunking.size", int64(c.Chunking.Size))
+	},
+	"ingest": func(v *validator, c *Config) {
+		v.positive("ingest.workers", int64(c.Ingest.Workers))
+		v.positive("ingest.queue_size", int64(c.Ingest.QueueSize))
+		if len(c.Ingest.Extensions) == 0 {
+			v.fail("ingest.extensions", "должен содержать хотя бы одно расширение")
+		}
+		for _, ext := range c.Ingest.Extensions {
+			if !strings.HasPrefix(ext, ".") {
+				v.fail("ingest.extensions", "расширение %q должно начинаться с точки", ext)
+			}
+		}
+	},
+	"retrieval": func(v *validator, c *Config) {
+		v.positive("retrieval.limit", int64(c.Retrieval.Limit))
+		if c.Retrieval.Threshold < 0 {
+			v.fail("retrieval.threshold", "не может быть отрицательным, текущее значение: %g", c.Retrieval.Threshold)
+		}
+	},
+	"ai": func(v *validator, c *Config) {
+		v.require("ai.base_url", c.AI.BaseURL)
+		if c.AI.BaseURL != "" {
+			if u, err := url.Parse(c.AI.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				v.fail("ai.base_url", "ожидается адрес вида https://host/v1, текущее значение: %q", c.AI.BaseURL)
+			}
+		}
+		if c.AI.APIKey == "YOUR_API_KEY_HERE" {
+			v.fail("ai.api_key", "указан плейсхолдер вместо API ключа (переменная окружения %s)", EnvName("ai.api_key"))
+		} else if strings.TrimSpace(c.AI.APIKey) == "" {
+			v.fail("ai.api_key", "API ключ не установлен (переменная окружения %s)", EnvName("ai.api_key"))
+		}
+		v.require("ai.model", c.AI.Model)
+		v.positive("ai.timeout", int64(c.AI.TimeoutSecs))
+		v.positive("ai.max_tokens", int64(c.AI.MaxTokens))
+		v.between("ai.temperature", c.AI.Temperature, 0, 2)
+		v.nonNegative("ai.max_retries", int64(c.AI.MaxRetries))
+		v.nonNegative("ai.retry_delay", int64(c.AI.RetryDelay))
+		v.oneOf("ai.json_mode", c.AI.JSONMode, "instructions", "json_object", "json_schema")
+		v.nonNegative("ai.structured_retries", int64(c.AI.StructuredRetries))
+	},
+	"security": func(v *validator, c *Config) {
+		v.oneOf("security.injection.policy", c.Security.Injection.Policy, "off", "annotate", "drop", "quarantine")
+		v.between("security.injection.threshold", c.Security.Injection.Threshold, 0, 1)
+	},
+	"grounding": func(v *validator, c *Config) {
+		v.oneOf("grounding.mode", c.Grounding.Mode, "off", "lexical", "llm")
+		v.oneOf("grounding.policy", c.Grounding.Policy, "annotate", "refuse")
+		v.between("grounding.threshold", c.Grounding.Threshold, 0, 1)
+		v.between("grounding.min_overlap", c.Grounding.MinOverlap, 0, 1)
+	},
+	"privacy": func(v *validator, c *Config) {
+		for _, kind := range c.Privacy.Redaction.Types {
+			v.oneOf("privacy.redaction.types", kind, "email", "phone", "card", "iban", "passport")
+		}
+	},
+	"cache": func(v *validator, c *Config) {
+		v.oneOf("cache.backend", c.Cache.Backend, "file", "memory", "sqlite", "off")
+		v.nonNegative("cache.ttl", int64(c.Cache.TTL))
+		v.nonNegative("cache.max_entries", int64(c.Cache.MaxEntries))
+		v.nonNegative("cache.max_bytes", c.Cache.MaxBytes)
+	},
+	"semantic_cache": func(v *validator, c *Config) {
+		v.between("semantic_cache.threshold", c.SemanticCache.Threshold, 0, 1)
+		v.between("semantic_cache.min_chunk_overlap", c.SemanticCache.MinChunkOverlap, 0, 1)
+		v.nonNegative("semantic_cache.max_entries", int64(c.SemanticCache.MaxEntries))
+	},
+	"server": func(v *validator, c *Config) {
+		v.require("server.addr", c.Server.Addr)
+		v.nonNegative("server.read_timeout", int64(c.Server.ReadTimeout))
+		v.nonNegative("server.write_timeout", int64(c.Server.WriteTimeout))
+		if c.Server.RateLimit < 0 {
+			v.fail("server.rate_limit", "не может быть отрицательным, текущее значение: %g", c.Server.RateLimit)
+		}
+		v.nonNegative("server.rate_burst", int64(c.Server.RateBurst))
+		v.nonNegative("server.reload_interval", int64(c.Server.ReloadInterval))
+	},
+	"logging": func(v *validator, c *Config) {
+		v.oneOf("logging.level", c.Logging.Level, "debug", "info", "warn", "error")
+		v.oneOf("logging.format", c.Logging.Format, "text", "json")
+	},
+	"tracing": func(v *validator, c *Config) {
+		v.between("tracing.sample_ratio", c.Tracing.SampleRatio, 0, 1)
+		if !c.Tracing.Enabled {
+			return
+		}
+		v.oneOf("tracing.exporter", c.Tracing.Exporter, "file", "otlp")
+		switch c.Tracing.Exporter {
+		case "", "file":
+			v.require("tracing.path", c.Tracing.Path)
+		case "otlp":
+			v.require("tracing.endpoint", c.Tracing.Endpoint)
+			if c.Tracing.Endpoint != "" {
+				if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					v.fail("tracing.endpoint", "ожидается адрес вида http://host:4318, текущее значение: %q", c.Tracing.Endpoint)
+				}
+			}
+		}
+	},
+}
+
+// Проверка профилей вызывает Validate для секций ai и retrieval, поэтому регистрируется при инициализации
+func init() {
+	sectionValidators["profiles"] = validateProfiles
+}
+
+// validateProfiles проверяет выбранный профиль, список разрешенных в API и каждый профиль поверх основной конфигурации
+func validateProfiles(v *validator, c *Config) {
+	if c.Profile != "" {
+		v.oneOf("profile", c.Profile, c.ProfileNames()...)
+	}
+	for _, name := range c.Server.AllowedProfiles {
+		v.oneOf("server.allowed_profiles", name, c.ProfileNames()...)
+	}
+	// Каждый профиль вместе с основной конфигурацией должен давать валидные секции ai и retrieval.
+	// Проблемы основной конфигурации уже сообщены ее секциями и для профилей не повторяются.
+	inherited := make(map[string]bool)
+	var baseErr *ValidationError
+	if errors.As(c.Validate("ai", "retrieval"), &baseErr) {
+		for _, p := range baseErr.Problems {
+			inherited[p.Path+"\x00"+p.Message] = true
+		}
+	}
+	for _, name := range c.ProfileNames() {
+		profiled, err := c.WithProfile(name)
+		var validationErr *ValidationError
+		if err == nil {
+			err = profiled.Validate("ai", "retrieval")
+		}
+		if !errors.As(err, &validationErr) {
+			if err != nil {
+				v.fail("profiles."+name, "%v", err)
+			}
+			continue
+		}
+		for _, p := range validationErr.Problems {
+			if inherited[p.Path+"\x00"+p.Message] {
+				continue
+			}
+			p.Path = "profiles." + name + "." + p.Path
+			v.problems = append(v.problems, p)
+		}
+	}
+}
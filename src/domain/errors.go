@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDocumentNotFound документ с указанным ID отсутствует
+var ErrDocumentNotFound = errors.New("документ не найден")
+
+// DocumentError ошибка индексации одного документа
+type DocumentError struct {
+	ID  string
+	Err error
+}
+
+func (e DocumentError) Error() string {
+	return fmt.Sprintf("%s: %v", e.ID, e.Err)
+}
+
+func (e DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// BulkError ошибки отдельных документов пакетной индексации; остальные документы сохранены
+type BulkError struct {
+	Errors []DocumentError
+}
+
+func (e *BulkError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, docErr := range e.Errors {
+		messages = append(messages, docErr.Error())
+	}
+	return fmt.Sprintf("не удалось проиндексировать документов: %d (%s)", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Unwrap позволяет проверять причины через errors.Is и errors.As
+func (e *BulkError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, docErr := range e.Errors {
+		errs = append(errs, docErr)
+	}
+	return errs
+}
@@ -18,6 +18,61 @@ type Chunk struct {
 	Similarity float64 `json:"similarity"` // Для релевантности
 }
 
+// IndexStats размер индекса
+type IndexStats struct {
+	Documents    int     `json:"documents"`
+	Chunks       int     `json:"chunks"`
+	Bytes        int64   `json:"bytes"`          // Размер базы данных
+	AvgChunkSize float64 `json:"avg_chunk_size"` // Средний размер фрагмента в байтах
+	FTS5         bool    `json:"fts5"`           // Полнотекстовый индекс FTS5 (иначе поиск через LIKE)
+}
+
+// IndexCheck результат проверки полнотекстового индекса
+type IndexCheck struct {
+	FTS5      bool    `json:"fts5"`                // Без FTS5 индекс не проверяется
+	Chunks    int     `json:"chunks"`              // Фрагментов в таблице chunks
+	Indexed   int     `json:"indexed"`             // Фрагментов, присутствующих в индексе
+	Missing   []int64 `json:"missing"`             // rowid фрагментов, отсутствующих в индексе
+	Stale     []int64 `json:"stale"`               // rowid записей индекса без фрагмента
+	Integrity string  `json:"integrity,omitempty"` // Ошибка FTS5 integrity-check
+	OK        bool    `json:"ok"`
+}
+
+// DocumentSummary сведения о документе без содержимого
+type DocumentSummary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int       `json:"size"` // Размер содержимого в байтах
+	Chunks    int       `json:"chunks"`
+}
+
+// Поля сортировки списка документов
+const (
+	SortByID      = "id"
+	SortByTitle   = "title"
+	SortByCreated = "created"
+	SortBySize    = "size"
+	SortByChunks  = "chunks"
+)
+
+// DocumentFilter условия отбора и порядок документов в списке; пустые условия не ограничивают список
+type DocumentFilter struct {
+	IDPrefix      string    // ID начинается с префикса
+	Title         string    // Название содержит подстроку (с учетом регистра)
+	CreatedAfter  time.Time // Созданы не раньше этого времени
+	CreatedBefore time.Time // Созданы раньше этого времени
+	Sort          string    // Поле сортировки (SortBy*); пусто - по ID
+	Desc          bool      // Сортировка по убыванию
+}
+
+// DocumentPage страница списка документов
+type DocumentPage struct {
+	Documents  []DocumentSummary `json:"documents"`
+	NextCursor string            `json:"next_cursor,omitempty"` // Пусто на последней странице
+	Total      int               `json:"total"`                 // Документов, подходящих под фильтр
+}
+
 // SearchRequest структура запроса на поиск
 type SearchRequest struct {
 	Query     string  `json:"query"`
@@ -30,3 +85,11 @@ type SearchResult struct {
 	Chunks []Chunk `json:"chunks"`
 	Query  string  `json:"query"`
 }
+
+// StructuredAnswer машиночитаемый ответ RAG системы
+type StructuredAnswer struct {
+	Answer     string   `json:"answer"`
+	Confidence float64  `json:"confidence"`
+	Sources    []string `json:"sources"`
+	Followups  []string `json:"followups,omitempty"`
+}
@@ -5,12 +5,33 @@ type DocumentRepository interface {
 	// SaveDocument сохраняет документ в базе данных
 	SaveDocument(doc Document) error
 
+	// SaveDocuments сохраняет документы пакетами; ошибки отдельных документов возвращаются
+	// как *BulkError, остальные документы при этом сохраняются
+	SaveDocuments(docs []Document) error
+
 	// FindRelevantChunks находит релевантные фрагменты по запросу
 	FindRelevantChunks(query string, limit int, threshold float64) ([]Chunk, error)
 
-	// GetAllDocuments возвращает все документы
+	// UpdateDocument заменяет название и содержимое документа и заново разбивает его на фрагменты;
+	// для отсутствующего документа возвращает ошибку, оборачивающую ErrDocumentNotFound
+	UpdateDocument(doc Document) error
+
+	// GetDocument возвращает документ по ID; для отсутствующего документа - ошибку, оборачивающую ErrDocumentNotFound
+	GetDocument(id string) (*Document, error)
+
+	// GetChunks возвращает фрагменты документа в порядке следования в тексте
+	GetChunks(docID string) ([]Chunk, error)
+
+	// ListDocuments возвращает страницу сведений о документах без содержимого.
+	// cursor - NextCursor предыдущей страницы (пусто - первая страница), limit <= 0 - размер страницы по умолчанию.
+	ListDocuments(filter DocumentFilter, cursor string, limit int) (DocumentPage, error)
+
+	// GetAllDocuments возвращает все документы вместе с содержимым
 	GetAllDocuments() ([]Document, error)
 
+	// MatchDocumentIDs возвращает ID документов, подходящих под шаблон GLOB (*, ? и [...]), по возрастанию
+	MatchDocumentIDs(pattern string) ([]string, error)
+
 	// DeleteDocument удаляет документ по ID
 	DeleteDocument(id string) error
 }
@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"context"
+	"sync"
+)
+
+// flight запрос к AI API, выполняющийся для нескольких одинаковых запросов
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int // Запросы, ожидающие результата; при нуле вызов отменяется
+
+	response string
+	err      error
+	metrics  RequestMetrics
+}
+
+// flightGroup объединяет одновременные запросы с одинаковым ключом кэша в один вызов API
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// do выполняет fn один раз для всех одновременных вызовов с ключом key.
+// Вызов выполняется с контекстом, не зависящим от отмены отдельного запроса: каждый ожидающий
+// прекращает ожидание по своему ctx, а сам вызов отменяется, только когда ждать некому.
+// Возвращает shared=true, если результат получен вызовом, запущенным другим запросом.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context, metrics *RequestMetrics) (string, error)) (response string, metrics RequestMetrics, shared bool, err error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+
+	f, shared := g.flights[key]
+	if shared {
+		f.waiters++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		g.flights[key] = f
+
+		go func() {
+			defer cancel()
+			f.response, f.err = fn(callCtx, &f.metrics)
+
+			g.mu.Lock()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+			g.mu.Unlock()
+			close(f.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.response, f.metrics, shared, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Ждать больше некому: отменяем вызов, а новые запросы запустят свой
+			f.cancel()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+		}
+		g.mu.Unlock()
+		return "", RequestMetrics{}, shared, ctx.Err()
+	}
+}
@@ -0,0 +1,211 @@
+package ai
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Типы персональных данных, поддерживаемые редактором
+const (
+	PIIEmail    = "email"
+	PIIPhone    = "phone"
+	PIICard     = "card"
+	PIIIBAN     = "iban"
+	PIIPassport = "passport"
+)
+
+// piiDetector детектор одного типа персональных данных
+type piiDetector struct {
+	kind     string
+	re       *regexp.Regexp
+	validate func(match string) bool // Дополнительная проверка совпадения (контрольная сумма и т.п.)
+}
+
+// piiDetectors детекторы в порядке применения: более специфичные форматы идут раньше телефонов,
+// чтобы номер карты или паспорта не был распознан как телефон
+var piiDetectors = []piiDetector{
+	{PIIEmail, regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), nil},
+	{PIIIBAN, regexp.MustCompile(`\b[A-Z]{2}\d{2}(?:[ ]?[A-Z0-9]){11,30}\b`), validIBAN},
+	{PIICard, regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`), validLuhn},
+	{PIIPassport, regexp.MustCompile(`\b\d{2}[ ]?\d{2}[ ]№?[ ]?\d{6}\b|\b[A-Z]{2}\d{7}\b`), nil},
+	{PIIPhone, regexp.MustCompile(`\+?\d[\d \-()]{7,}\d`), validPhone},
+}
+
+// digitsOnly оставляет в строке только цифры
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// validLuhn проверяет номер карты по алгоритму Луна
+func validLuhn(match string) bool {
+	digits := digitsOnly(match)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validIBAN проверяет контрольную сумму IBAN (ISO 13616, mod 97)
+func validIBAN(match string) bool {
+	iban := strings.ReplaceAll(match, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	rearranged := iban[4:] + iban[:4]
+	var numeric strings.Builder
+	for _, r := range rearranged {
+		switch {
+		case r >= '0' && r <= '9':
+			numeric.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			numeric.WriteString(fmt.Sprint(int(r-'A') + 10))
+		default:
+			return false
+		}
+	}
+
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	if !ok {
+		return false
+	}
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validPhone отсекает совпадения с неправдоподобным количеством цифр (даты, суммы и т.п.)
+func validPhone(match string) bool {
+	n := len(digitsOnly(match))
+	return n >= 10 && n <= 15
+}
+
+// Redactor заменяет персональные данные обратимыми плейсхолдерами
+type Redactor struct {
+	detectors []piiDetector
+}
+
+// NewRedactor создает редактор для указанных типов данных; пустой список включает все типы
+func NewRedactor(kinds []string) (*Redactor, error) {
+	if len(kinds) == 0 {
+		return &Redactor{detectors: piiDetectors}, nil
+	}
+
+	enabled := make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		enabled[strings.ToLower(kind)] = true
+	}
+
+	r := &Redactor{}
+	for _, d := range piiDetectors {
+		if enabled[d.kind] {
+			r.detectors = append(r.detectors, d)
+			delete(enabled, d.kind)
+		}
+	}
+	for kind := range enabled {
+		return nil, fmt.Errorf("неизвестный тип персональных данных %q (допустимо: email, phone, card, iban, passport)", kind)
+	}
+
+	return r, nil
+}
+
+// RedactionSession хранит соответствие плейсхолдеров и исходных значений в рамках одного запроса
+type RedactionSession struct {
+	redactor     *Redactor
+	placeholders map[string]string // плейсхолдер -> исходное значение
+	values       map[string]string // исходное значение -> плейсхолдер
+	counts       map[string]int    // тип -> количество замен
+}
+
+// NewSession создает сессию редактирования для одного запроса
+func (r *Redactor) NewSession() *RedactionSession {
+	return &RedactionSession{
+		redactor:     r,
+		placeholders: make(map[string]string),
+		values:       make(map[string]string),
+		counts:       make(map[string]int),
+	}
+}
+
+// placeholderFor возвращает плейсхолдер для значения; одинаковые значения получают один плейсхолдер
+func (s *RedactionSession) placeholderFor(kind, value string) string {
+	if placeholder, ok := s.values[value]; ok {
+		return placeholder
+	}
+
+	n := 1
+	prefix := "[" + strings.ToUpper(kind) + "_"
+	for p := range s.placeholders {
+		if strings.HasPrefix(p, prefix) {
+			n++
+		}
+	}
+
+	placeholder := fmt.Sprintf("%s%d]", prefix, n)
+	s.placeholders[placeholder] = value
+	s.values[value] = placeholder
+	return placeholder
+}
+
+// Redact заменяет найденные персональные данные плейсхолдерами вида [EMAIL_1]
+func (s *RedactionSession) Redact(text string) string {
+	for _, d := range s.redactor.detectors {
+		text = d.re.ReplaceAllStringFunc(text, func(match string) string {
+			if d.validate != nil && !d.validate(match) {
+				return match
+			}
+			s.counts[d.kind]++
+			return s.placeholderFor(d.kind, match)
+		})
+	}
+	return text
+}
+
+// Restore возвращает исходные значения на место плейсхолдеров в ответе модели
+func (s *RedactionSession) Restore(text string) string {
+	for placeholder, value := range s.placeholders {
+		text = strings.ReplaceAll(text, placeholder, value)
+	}
+	return text
+}
+
+// Summary возвращает сводку замен для аудита, например "email=1, phone=2"
+func (s *RedactionSession) Summary() string {
+	kinds := make([]string, 0, len(s.counts))
+	for kind, n := range s.counts {
+		kinds = append(kinds, fmt.Sprintf("%s=%d", kind, n))
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, ", ")
+}
+
+// Counts возвращает количество замен по типам данных
+func (s *RedactionSession) Counts() map[string]int {
+	counts := make(map[string]int, len(s.counts))
+	for kind, n := range s.counts {
+		counts[kind] = n
+	}
+	return counts
+}
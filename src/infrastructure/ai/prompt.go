@@ -0,0 +1,266 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"rag-system/src/domain"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Message сообщение диалога в формате chat completions API
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// PromptChunk фрагмент контекста, доступный в шаблоне
+type PromptChunk struct {
+	Index      int
+	ID         string
+	DocumentID string
+	Content    string
+	Similarity float64
+}
+
+// PromptData данные, передаваемые в шаблоны промптов
+type PromptData struct {
+	Query    string
+	Chunks   []PromptChunk
+	Context  string // Фрагменты, собранные в единый блок контекста
+	History  []Message
+	Language string
+	Metadata map[string]string
+}
+
+// PromptOptions параметры построения промпта для отдельного запроса
+type PromptOptions struct {
+	Template string            // Имя шаблона (qa, summarize, compare); пусто - шаблон по умолчанию
+	Language string            // Язык ответа; пусто - язык из конфигурации
+	History  []Message         // Предыдущие сообщения диалога
+	Metadata map[string]string // Произвольные переменные для шаблона
+	Profile  string            // Профиль конфигурации (модель, параметры генерации); пусто - профиль по умолчанию
+}
+
+// PromptTemplate именованный шаблон с системной и пользовательской частями
+type PromptTemplate struct {
+	Name string
+	tmpl *template.Template
+}
+
+// PromptSet набор именованных шаблонов промптов
+type PromptSet struct {
+	templates       map[string]*PromptTemplate
+	defaultName     string
+	defaultLanguage string
+}
+
+// defaultTemplateName имя шаблона, используемого по умолчанию
+const defaultTemplateName = "qa"
+
+// builtinQATemplate встроенный шаблон, используемый если директория шаблонов не задана
+const builtinQATemplate = `{{define "system"}}Ты ассистент, который отвечает на вопросы по базе знаний. Отвечай на {{languageName .Language}} языке.{{end}}
+{{define "user"}}Ответь на вопрос, используя только информацию из следующего контекста.
+
+Контекст:
+{{.Context}}
+
+Вопрос: {{.Query}}
+
+Ответ:{{end}}`
+
+// languageNames названия языков для подстановки в инструкции
+var languageNames = map[string]string{
+	"ru": "русском",
+	"en": "английском",
+	"de": "немецком",
+	"fr": "французском",
+	"es": "испанском",
+	"tr": "турецком",
+}
+
+// templateFuncs функции, доступные в шаблонах
+var templateFuncs = template.FuncMap{
+	"languageName": func(code string) string {
+		if name, ok := languageNames[strings.ToLower(code)]; ok {
+			return name
+		}
+		return code
+	},
+	"trim": strings.TrimSpace,
+	"join": strings.Join,
+}
+
+// parsePromptTemplate разбирает шаблон и проверяет наличие обязательного блока "user"
+func parsePromptTemplate(name, text string) (*PromptTemplate, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора шаблона %q: %w", name, err)
+	}
+	if tmpl.Lookup("user") == nil {
+		return nil, fmt.Errorf("шаблон %q не содержит обязательный блок {{define \"user\"}}", name)
+	}
+
+	pt := &PromptTemplate{Name: name, tmpl: tmpl}
+
+	// Пробный рендеринг, чтобы ошибки выполнения шаблона обнаруживались при загрузке, а не при запросе
+	if _, err := pt.render(samplePromptData()); err != nil {
+		return nil, err
+	}
+
+	return pt, nil
+}
+
+// samplePromptData возвращает тестовые данные для проверки шаблонов при загрузке
+func samplePromptData() PromptData {
+	return PromptData{
+		Query: "Пример вопроса",
+		Chunks: []PromptChunk{
+			{Index: 1, ID: "doc_chunk_0", DocumentID: "doc", Content: "Пример фрагмента", Similarity: 1},
+			{Index: 2, ID: "doc_chunk_1", DocumentID: "doc", Content: "Второй фрагмент", Similarity: 0.5},
+		},
+		Context:  "Пример фрагмента\n\nВторой фрагмент",
+		History:  []Message{{Role: "user", Content: "Предыдущий вопрос"}, {Role: "assistant", Content: "Предыдущий ответ"}},
+		Language: "ru",
+		Metadata: map[string]string{},
+	}
+}
+
+// render выполняет шаблон и возвращает системное и пользовательское сообщения
+func (t *PromptTemplate) render(data PromptData) ([]Message, error) {
+	var messages []Message
+
+	if t.tmpl.Lookup("system") != nil {
+		var buf bytes.Buffer
+		if err := t.tmpl.ExecuteTemplate(&buf, "system", data); err != nil {
+			return nil, fmt.Errorf("ошибка рендеринга системной части шаблона %q: %w", t.Name, err)
+		}
+		if system := strings.TrimSpace(buf.String()); system != "" {
+			messages = append(messages, Message{Role: "system", Content: system})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := t.tmpl.ExecuteTemplate(&buf, "user", data); err != nil {
+		return nil, fmt.Errorf("ошибка рендеринга пользовательской части шаблона %q: %w", t.Name, err)
+	}
+
+	// История диалога располагается между системным сообщением и текущим вопросом
+	messages = append(messages, data.History...)
+	messages = append(messages, Message{Role: "user", Content: strings.TrimSpace(buf.String())})
+
+	return messages, nil
+}
+
+// DefaultPromptSet возвращает набор из встроенного шаблона qa
+func DefaultPromptSet(language string) *PromptSet {
+	pt, err := parsePromptTemplate(defaultTemplateName, builtinQATemplate)
+	if err != nil {
+		// Встроенный шаблон проверяется тестами, ошибка здесь - ошибка программиста
+		panic(err)
+	}
+	if language == "" {
+		language = "ru"
+	}
+	return &PromptSet{
+		templates:       map[string]*PromptTemplate{defaultTemplateName: pt},
+		defaultName:     defaultTemplateName,
+		defaultLanguage: language,
+	}
+}
+
+// LoadPromptSet загружает шаблоны *.tmpl из директории; имя шаблона - имя файла без расширения
+func LoadPromptSet(dir, defaultName, language string) (*PromptSet, error) {
+	set := DefaultPromptSet(language)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения директории шаблонов: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("в директории %s не найдено ни одного шаблона (*.tmpl)", dir)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения шаблона %s: %w", file, err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		pt, err := parsePromptTemplate(name, string(data))
+		if err != nil {
+			return nil, err
+		}
+		set.templates[name] = pt
+	}
+
+	if defaultName != "" {
+		if _, ok := set.templates[defaultName]; !ok {
+			return nil, fmt.Errorf("шаблон по умолчанию %q не найден в %s", defaultName, dir)
+		}
+		set.defaultName = defaultName
+	}
+
+	return set, nil
+}
+
+// Names возвращает отсортированный список имен шаблонов
+func (s *PromptSet) Names() []string {
+	names := make([]string, 0, len(s.templates))
+	for name := range s.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPromptData собирает данные шаблона из запроса и найденных фрагментов с санитаризацией
+func NewPromptData(query string, chunks []domain.Chunk, opts PromptOptions) PromptData {
+	data := PromptData{
+		Query:    sanitizeInput(query, 1000),
+		History:  opts.History,
+		Language: opts.Language,
+		Metadata: opts.Metadata,
+	}
+	if data.Metadata == nil {
+		data.Metadata = map[string]string{}
+	}
+
+	contextParts := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		content := sanitizeInput(chunk.Content, 5000) // Максимум 5000 символов на чанк
+		if content == "" {
+			continue
+		}
+		data.Chunks = append(data.Chunks, PromptChunk{
+			Index:      len(data.Chunks) + 1,
+			ID:         chunk.ID,
+			DocumentID: chunk.DocumentID,
+			Content:    content,
+			Similarity: chunk.Similarity,
+		})
+		contextParts = append(contextParts, content)
+	}
+	data.Context = strings.Join(contextParts, "\n\n")
+
+	return data
+}
+
+// Render строит сообщения для запроса по указанному (или используемому по умолчанию) шаблону
+func (s *PromptSet) Render(data PromptData, name string) ([]Message, error) {
+	if name == "" {
+		name = s.defaultName
+	}
+	pt, ok := s.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный шаблон промпта %q (доступны: %s)", name, strings.Join(s.Names(), ", "))
+	}
+	if data.Language == "" {
+		data.Language = s.defaultLanguage
+	}
+	return pt.render(data)
+}
@@ -0,0 +1,214 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldType тип поля JSON схемы
+type FieldType string
+
+// Поддерживаемые типы полей
+const (
+	FieldString      FieldType = "string"
+	FieldNumber      FieldType = "number"
+	FieldBoolean     FieldType = "boolean"
+	FieldStringArray FieldType = "string_array"
+)
+
+// SchemaField описание поля схемы
+type SchemaField struct {
+	Name        string
+	Type        FieldType
+	Required    bool
+	Description string
+	Min, Max    *float64 // Границы для чисел
+	NonEmpty    bool     // Строка не пустая / массив содержит хотя бы один элемент
+}
+
+// Schema схема структурированного ответа, объявленная в Go коде
+type Schema struct {
+	Name   string
+	Fields []SchemaField
+}
+
+// floatPtr возвращает указатель на число (для границ полей схемы)
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// AnswerSchema схема ответа с полями answer, confidence, sources и followups (см. domain.StructuredAnswer)
+var AnswerSchema = Schema{
+	Name: "rag_answer",
+	Fields: []SchemaField{
+		{Name: "answer", Type: FieldString, Required: true, NonEmpty: true, Description: "ответ на вопрос"},
+		{Name: "confidence", Type: FieldNumber, Required: true, Min: floatPtr(0), Max: floatPtr(1), Description: "уверенность в ответе от 0 до 1"},
+		{Name: "sources", Type: FieldStringArray, Required: true, Description: "идентификаторы документов (source), на которых основан ответ"},
+		{Name: "followups", Type: FieldStringArray, Required: false, Description: "уточняющие вопросы, которые может задать пользователь"},
+	},
+}
+
+// ValidationError ошибки проверки ответа по схеме; содержит все найденные проблемы
+type ValidationError struct {
+	Problems []string
+}
+
+// Error возвращает все проблемы одной строкой
+func (e *ValidationError) Error() string {
+	return "ответ не соответствует схеме: " + strings.Join(e.Problems, "; ")
+}
+
+// stripCodeFence убирает обрамление ```json ... ```, которое модели часто добавляют к JSON
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
+
+// Validate проверяет JSON по схеме и возвращает нормализованный JSON без обрамления
+func (s Schema) Validate(raw string) ([]byte, error) {
+	data := []byte(stripCodeFence(raw))
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("невалидный JSON объект: %v", err)}}
+	}
+
+	var problems []string
+	known := make(map[string]bool, len(s.Fields))
+
+	for _, field := range s.Fields {
+		known[field.Name] = true
+		value, ok := object[field.Name]
+		if !ok || value == nil {
+			if field.Required {
+				problems = append(problems, fmt.Sprintf("отсутствует обязательное поле %q", field.Name))
+			}
+			continue
+		}
+		problems = append(problems, field.check(value)...)
+	}
+
+	var unknown []string
+	for name := range object {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fmt.Sprintf("неизвестное поле %q", name))
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return data, nil
+}
+
+// check проверяет значение поля на соответствие типу и ограничениям
+func (f SchemaField) check(value interface{}) []string {
+	switch f.Type {
+	case FieldString:
+		str, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("поле %q должно быть строкой", f.Name)}
+		}
+		if f.NonEmpty && strings.TrimSpace(str) == "" {
+			return []string{fmt.Sprintf("поле %q не должно быть пустым", f.Name)}
+		}
+
+	case FieldNumber:
+		num, ok := value.(float64)
+		if !ok {
+			return []string{fmt.Sprintf("поле %q должно быть числом", f.Name)}
+		}
+		if f.Min != nil && num < *f.Min {
+			return []string{fmt.Sprintf("поле %q должно быть не меньше %v, получено %v", f.Name, *f.Min, num)}
+		}
+		if f.Max != nil && num > *f.Max {
+			return []string{fmt.Sprintf("поле %q должно быть не больше %v, получено %v", f.Name, *f.Max, num)}
+		}
+
+	case FieldBoolean:
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("поле %q должно быть true или false", f.Name)}
+		}
+
+	case FieldStringArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("поле %q должно быть массивом строк", f.Name)}
+		}
+		if f.NonEmpty && len(items) == 0 {
+			return []string{fmt.Sprintf("поле %q не должно быть пустым", f.Name)}
+		}
+		for i, item := range items {
+			if _, ok := item.(string); !ok {
+				return []string{fmt.Sprintf("элемент %d поля %q должен быть строкой", i, f.Name)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// JSONSchema возвращает схему в формате JSON Schema для response_format типа json_schema
+func (s Schema) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(s.Fields))
+	required := make([]string, 0, len(s.Fields))
+
+	for _, field := range s.Fields {
+		prop := map[string]interface{}{"description": field.Description}
+		switch field.Type {
+		case FieldStringArray:
+			prop["type"] = "array"
+			prop["items"] = map[string]string{"type": "string"}
+		default:
+			prop["type"] = string(field.Type)
+		}
+		if field.Min != nil {
+			prop["minimum"] = *field.Min
+		}
+		if field.Max != nil {
+			prop["maximum"] = *field.Max
+		}
+		properties[field.Name] = prop
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// Instructions возвращает текстовое описание формата для моделей без поддержки response_format
+func (s Schema) Instructions() string {
+	var b strings.Builder
+	b.WriteString("Ответь строго одним JSON объектом без пояснений и без обрамления ```. Поля объекта:\n")
+	for _, field := range s.Fields {
+		requirement := "необязательное"
+		if field.Required {
+			requirement = "обязательное"
+		}
+		typeName := string(field.Type)
+		if field.Type == FieldStringArray {
+			typeName = "массив строк"
+		}
+		fmt.Fprintf(&b, "- %q (%s, %s): %s\n", field.Name, typeName, requirement, field.Description)
+	}
+	return strings.TrimSpace(b.String())
+}
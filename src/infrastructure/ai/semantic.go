@@ -0,0 +1,246 @@
+package ai
+
+import (
+	"hash/fnv"
+	"math"
+	"rag-system/src/domain"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Embedder строит векторное представление текста
+type Embedder interface {
+	Embed(text string) []float64
+}
+
+// HashEmbedder локальный лексический эмбеддер на хешировании признаков: основы слов и символьные триграммы
+// без учета порядка (мешок слов). Не требует внешней модели и устойчив к перестановке слов и изменению
+// окончаний, но не распознает синонимы и не различает вопросы из одних слов в разном порядке.
+type HashEmbedder struct {
+	Dims int
+}
+
+// defaultEmbeddingDims размерность векторов HashEmbedder по умолчанию
+const defaultEmbeddingDims = 512
+
+// Embed возвращает нормированный вектор текста
+func (e HashEmbedder) Embed(text string) []float64 {
+	dims := e.Dims
+	if dims <= 0 {
+		dims = defaultEmbeddingDims
+	}
+	vector := make([]float64, dims)
+
+	add := func(feature string, weight float64) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		sum := h.Sum32()
+		// Старший бит задает знак, чтобы коллизии хешей в среднем компенсировались
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vector[int(sum%uint32(dims))] += weight
+	}
+
+	for _, token := range tokenize(text) {
+		add("w:"+token, 1)
+		runes := []rune("^" + token + "$")
+		for i := 0; i+3 <= len(runes); i++ {
+			add("t:"+string(runes[i:i+3]), 0.3)
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector
+}
+
+// cosine возвращает косинусное сходство нормированных векторов
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
+
+// normalizeQuery приводит запрос к нижнему регистру и убирает лишние пробелы и знаки препинания по краям
+func normalizeQuery(query string) string {
+	return strings.Trim(strings.Join(strings.Fields(strings.ToLower(query)), " "), " ?!.,;:")
+}
+
+// SemanticMatch найденный семантически близкий запрос
+type SemanticMatch struct {
+	Query      string  // Исходный запрос, ответ на который переиспользуется
+	Answer     string  // Ответ модели
+	Similarity float64 // Косинусное сходство запросов
+	Overlap    float64 // Доля общих найденных фрагментов (коэффициент Жаккара)
+}
+
+// semanticEntry запомненный запрос с ответом
+type semanticEntry struct {
+	query     string
+	vector    []float64
+	scope     string
+	chunks    map[string]bool
+	documents []string
+	answer    string
+	expiresAt time.Time
+}
+
+// SemanticCache кэш ответов для перефразированных вопросов. Ответ переиспользуется, если запрос
+// достаточно похож на сохраненный и найденные фрагменты в основном совпадают.
+type SemanticCache struct {
+	mu         sync.Mutex
+	embedder   Embedder
+	threshold  float64
+	minOverlap float64
+	maxEntries int
+	ttl        time.Duration
+	entries    []*semanticEntry // От старых к новым
+}
+
+// NewSemanticCache создает семантический кэш
+func NewSemanticCache(embedder Embedder, threshold, minOverlap float64, maxEntries int, ttl time.Duration) *SemanticCache {
+	if threshold <= 0 || threshold > 1 {
+		threshold = 0.85
+	}
+	if minOverlap <= 0 || minOverlap > 1 {
+		minOverlap = 0.5
+	}
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &SemanticCache{embedder: embedder, threshold: threshold, minOverlap: minOverlap, maxEntries: maxEntries, ttl: ttl}
+}
+
+// chunkSet возвращает множество идентификаторов фрагментов
+func chunkSet(chunks []domain.Chunk) map[string]bool {
+	set := make(map[string]bool, len(chunks))
+	for _, chunk := range chunks {
+		set[chunk.ID] = true
+	}
+	return set
+}
+
+// jaccard возвращает долю общих элементов двух множеств
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	common := 0
+	for id := range a {
+		if b[id] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// Lookup ищет самый похожий сохраненный запрос в той же области (модель, шаблон, язык) с пересекающимися фрагментами
+func (s *SemanticCache) Lookup(query, scope string, chunks []domain.Chunk) *SemanticMatch {
+	vector := s.embedder.Embed(normalizeQuery(query))
+	set := chunkSet(chunks)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var best *SemanticMatch
+	kept := s.entries[:0]
+	for _, entry := range s.entries {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			continue
+		}
+		kept = append(kept, entry)
+
+		if entry.scope != scope {
+			continue
+		}
+		similarity := cosine(vector, entry.vector)
+		if similarity < s.threshold || (best != nil && similarity <= best.Similarity) {
+			continue
+		}
+		if overlap := jaccard(set, entry.chunks); overlap >= s.minOverlap {
+			best = &SemanticMatch{Query: entry.query, Answer: entry.answer, Similarity: similarity, Overlap: overlap}
+		}
+	}
+	s.entries = kept
+	return best
+}
+
+// Add запоминает ответ модели на запрос
+func (s *SemanticCache) Add(query, scope string, chunks []domain.Chunk, answer string) {
+	entry := &semanticEntry{
+		query:     query,
+		vector:    s.embedder.Embed(normalizeQuery(query)),
+		scope:     scope,
+		chunks:    chunkSet(chunks),
+		documents: chunkDocuments(chunks),
+		answer:    answer,
+	}
+	if s.ttl > 0 {
+		entry.expiresAt = time.Now().Add(s.ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+	if len(s.entries) > s.maxEntries {
+		s.entries = s.entries[len(s.entries)-s.maxEntries:]
+	}
+}
+
+// Invalidate удаляет ответы, основанные на любом из документов, и возвращает их количество
+func (s *SemanticCache) Invalidate(documents ...string) int {
+	targets := make(map[string]bool, len(documents))
+	for _, id := range documents {
+		targets[id] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.entries[:0]
+	for _, entry := range s.entries {
+		stale := false
+		for _, id := range entry.documents {
+			if targets[id] {
+				stale = true
+				break
+			}
+		}
+		if !stale {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(s.entries) - len(kept)
+	s.entries = kept
+	return removed
+}
+
+// Clear удаляет все сохраненные ответы
+func (s *SemanticCache) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = nil
+}
+
+// semanticScope область переиспользования ответа: ответ другой модели, по другому шаблону или на другом языке не подходит
+func semanticScope(model string, opts PromptOptions) string {
+	return model + "\x00" + opts.Template + "\x00" + opts.Language
+}
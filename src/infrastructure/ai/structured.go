@@ -0,0 +1,134 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"rag-system/src/infrastructure/logging"
+	"rag-system/src/infrastructure/tracing"
+	"time"
+)
+
+// Способы запроса JSON у провайдера (поле ai.json_mode)
+const (
+	JSONModeInstructions = "instructions" // Только текстовые инструкции в промпте
+	JSONModeObject       = "json_object"  // response_format {"type": "json_object"}
+	JSONModeSchema       = "json_schema"  // response_format с JSON Schema
+)
+
+// responseFormat возвращает дополнительные поля запроса для выбранного режима JSON
+func (c *AIClient) responseFormat(schema Schema) map[string]interface{} {
+	switch c.config.AI.JSONMode {
+	case JSONModeObject:
+		return map[string]interface{}{"response_format": map[string]string{"type": "json_object"}}
+	case JSONModeSchema:
+		return map[string]interface{}{"response_format": map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   schema.Name,
+				"schema": schema.JSONSchema(),
+			},
+		}}
+	default:
+		return nil
+	}
+}
+
+// GenerateStructured генерирует ответ в формате JSON, проверяет его по схеме и декодирует в out.
+// Если ответ не проходит проверку, ошибка проверки отправляется модели и попытка повторяется.
+func (c *AIClient) GenerateStructured(ctx context.Context, req GenerateRequest, schema Schema, out interface{}) (*GenerateResult, error) {
+	ctx, span := tracing.Start(ctx, "ai.GenerateStructured", tracing.String("profile", req.Profile),
+		tracing.Int("chunks.count", len(req.Chunks)), tracing.String("schema", schema.Name))
+	defer span.End()
+
+	result, err := c.generateStructured(ctx, req, schema, out)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(resultAttributes(result)...)
+	span.SetAttributes(tracing.Int("schema.retries", result.Metrics.SchemaRetries))
+	return result, nil
+}
+
+// generateStructured выполняет GenerateStructured в span ai.GenerateStructured
+func (c *AIClient) generateStructured(ctx context.Context, req GenerateRequest, schema Schema, out interface{}) (*GenerateResult, error) {
+	c, err := c.active().withProfile(req.Profile)
+	if err != nil {
+		return nil, err
+	}
+	tracing.SpanFromContext(ctx).SetAttributes(tracing.String("ai.model", c.config.AI.Model))
+	ctx = logging.EnsureRequestID(ctx)
+	startTime := time.Now()
+	metrics := &RequestMetrics{}
+
+	query := sanitizeInput(req.Query, 1000)
+	chunks, quarantined := c.screenChunks(ctx, req.Chunks, metrics)
+
+	prompt, err := c.preparePrompt(ctx, query, chunks, req.PromptOptions, metrics)
+	if err != nil {
+		metrics.Error = err
+		metrics.Duration = time.Since(startTime)
+		c.logRequest(ctx, slog.LevelError, "Ошибка построения промпта", metrics)
+		return nil, err
+	}
+
+	// Инструкции о формате нужны и при response_format: часть провайдеров требует упоминания JSON в промпте
+	messages := prompt.messages
+	last := &messages[len(messages)-1]
+	last.Content += "\n\n" + schema.Instructions()
+
+	// Значение по умолчанию задает config.Default(), явный 0 отключает повторы
+	retries := max(c.config.AI.StructuredRetries, 0)
+	extra := c.responseFormat(schema)
+
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		metrics.SchemaRetries = attempt
+
+		raw, err := c.complete(ctx, messages, extra, metrics)
+		if err != nil {
+			metrics.Error = err
+			metrics.Duration = time.Since(startTime)
+			c.observeUncached(metrics)
+			c.logRequest(ctx, slog.LevelError, "Не удалось получить ответ от AI API", metrics)
+			return nil, err
+		}
+
+		// Проверяем ответ в том виде, в котором его вернула модель: исходные значения подставляются
+		// только в уже проверенный JSON
+		data, err := schema.Validate(raw)
+		if err == nil {
+			text := prompt.restore(string(data))
+			if err := json.Unmarshal([]byte(text), out); err != nil {
+				err = fmt.Errorf("ошибка декодирования структурированного ответа: %w", err)
+				metrics.Error = err
+				metrics.Duration = time.Since(startTime)
+				c.observeUncached(metrics)
+				c.logRequest(ctx, slog.LevelError, "Не удалось декодировать структурированный ответ", metrics)
+				return nil, err
+			}
+			metrics.Duration = time.Since(startTime)
+			c.observeUncached(metrics)
+			c.logRequest(ctx, slog.LevelInfo, "Успешный структурированный запрос к AI API", metrics)
+			return &GenerateResult{Text: text, Metrics: *metrics, Quarantined: quarantined}, nil
+		}
+
+		lastErr = err
+		c.logRequest(ctx, slog.LevelWarn, "Структурированный ответ не прошел проверку", nil,
+			slog.Int("attempt", attempt+1), slog.Int("attempts", retries+1), slog.Any("error", err))
+
+		// Возвращаем модели ее ответ вместе с ошибкой проверки, чтобы она исправила формат
+		messages = append(messages,
+			Message{Role: "assistant", Content: raw},
+			Message{Role: "user", Content: prompt.redact(fmt.Sprintf("%v. Исправь ответ и верни только JSON объект, соответствующий описанию.", err))},
+		)
+	}
+
+	metrics.Error = lastErr
+	metrics.Duration = time.Since(startTime)
+	c.observeUncached(metrics)
+	c.logRequest(ctx, slog.LevelError, "Структурированный ответ не прошел проверку", metrics)
+	return nil, fmt.Errorf("модель не вернула ответ по схеме %s после %d попыток: %w", schema.Name, retries+1, lastErr)
+}
@@ -0,0 +1,404 @@
+// Package archive перенос базы знаний между машинами.
+//
+// Экспорт записывает документы с метаданными и фрагментами из согласованного снимка базы
+// в переносимый архив одного из двух форматов:
+//   - JSONL: первая строка - манифест, далее по одному документу на строку;
+//   - tar.gz: manifest.json и documents.jsonl с документами в том же виде.
+//
+// Фрагменты сохраняются как есть, поэтому после импорта поиск работает так же, как в исходной базе,
+// даже если размер фрагмента в конфигурации отличается. Формат импортируемого архива определяется
+// по содержимому.
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure"
+	"strings"
+	"time"
+)
+
+// Version версия формата архива; архивы более новых версий не импортируются
+const Version = 1
+
+// Форматы архива
+const (
+	FormatJSONL = "jsonl"
+	FormatTarGz = "tar.gz"
+)
+
+// Имена файлов внутри tar.gz
+const (
+	manifestName  = "manifest.json"
+	documentsName = "documents.jsonl"
+)
+
+// Политики импорта
+const (
+	// PolicyUpsert перезаписывает документы с совпадающими ID, остальные документы базы сохраняются
+	PolicyUpsert = "upsert"
+	// PolicyReplace дополнительно удаляет документы, которых нет в архиве: база совпадает с архивом
+	PolicyReplace = "replace"
+)
+
+// Manifest описание содержимого архива
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Documents int       `json:"documents"`
+	Chunks    int       `json:"chunks"`
+}
+
+// Record документ архива с метаданными и фрагментами
+type Record struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	Chunks    []string  `json:"chunks"`
+}
+
+// FormatFromPath определяет формат архива по расширению файла: .tar.gz и .tgz - tar.gz, иначе JSONL
+func FormatFromPath(path string) string {
+	lower := strings.ToLower(path)
+	if strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz") {
+		return FormatTarGz
+	}
+	return FormatJSONL
+}
+
+// ExportFile экспортирует базу в файл path в формате, определенном по расширению. Архив записывается
+// во временный файл и переименовывается по завершении, поэтому прерванный экспорт не оставляет неполный архив
+func ExportFile(repo *infrastructure.SQLiteDocumentRepository, path string) (Manifest, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("не удалось создать файл архива: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := Export(repo, tmp, FormatFromPath(path))
+	if closeErr := tmp.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("не удалось записать файл архива: %w", closeErr)
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return manifest, fmt.Errorf("не удалось сохранить архив: %w", err)
+	}
+	return manifest, nil
+}
+
+// Export записывает все документы базы в w в формате FormatJSONL или FormatTarGz
+func Export(repo *infrastructure.SQLiteDocumentRepository, w io.Writer, format string) (Manifest, error) {
+	snapshot, err := repo.Snapshot()
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer snapshot.Close()
+
+	manifest := Manifest{Version: Version, CreatedAt: time.Now().UTC()}
+	if manifest.Documents, manifest.Chunks, err = snapshot.Counts(); err != nil {
+		return manifest, err
+	}
+
+	switch format {
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		if err := writeJSONL(bw, manifest, snapshot); err != nil {
+			return manifest, err
+		}
+		return manifest, bw.Flush()
+	case FormatTarGz:
+		return manifest, writeTarGz(w, manifest, snapshot)
+	default:
+		return manifest, fmt.Errorf("неизвестный формат архива %q (допустимо: %s, %s)", format, FormatJSONL, FormatTarGz)
+	}
+}
+
+// writeJSONL записывает манифест первой строкой, а за ним документы
+func writeJSONL(w io.Writer, manifest Manifest, snapshot *infrastructure.Snapshot) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(manifest); err != nil {
+		return fmt.Errorf("ошибка записи манифеста: %w", err)
+	}
+	return writeDocuments(enc, snapshot)
+}
+
+func writeDocuments(enc *json.Encoder, snapshot *infrastructure.Snapshot) error {
+	return snapshot.Documents(func(doc infrastructure.PreparedDocument) error {
+		record := Record{ID: doc.ID, Title: doc.Title, Content: doc.Content, CreatedAt: doc.CreatedAt, Chunks: doc.Chunks}
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("ошибка записи документа %s: %w", doc.ID, err)
+		}
+		return nil
+	})
+}
+
+// writeTarGz записывает tar.gz с manifest.json и documents.jsonl. Размер записи tar нужен до ее содержимого,
+// поэтому документы сначала выгружаются во временный файл
+func writeTarGz(w io.Writer, manifest Manifest, snapshot *infrastructure.Snapshot) error {
+	docs, err := os.CreateTemp("", "rag-export-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("не удалось создать временный файл: %w", err)
+	}
+	defer os.Remove(docs.Name())
+	defer docs.Close()
+
+	bw := bufio.NewWriter(docs)
+	if err := writeDocuments(json.NewEncoder(bw), snapshot); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("ошибка записи временного файла: %w", err)
+	}
+	size, err := docs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("ошибка записи временного файла: %w", err)
+	}
+	if _, err := docs.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("ошибка чтения временного файла: %w", err)
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка записи манифеста: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	header := func(name string, size int64) *tar.Header {
+		return &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: manifest.CreatedAt, Format: tar.FormatPAX}
+	}
+	if err := tw.WriteHeader(header(manifestName, int64(len(manifestData)))); err != nil {
+		return fmt.Errorf("ошибка записи архива: %w", err)
+	}
+	if _, err := tw.Write(manifestData); err != nil {
+		return fmt.Errorf("ошибка записи архива: %w", err)
+	}
+	if err := tw.WriteHeader(header(documentsName, size)); err != nil {
+		return fmt.Errorf("ошибка записи архива: %w", err)
+	}
+	if _, err := io.Copy(tw, docs); err != nil {
+		return fmt.Errorf("ошибка записи архива: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("ошибка записи архива: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("ошибка записи архива: %w", err)
+	}
+	return nil
+}
+
+// ImportOptions настройки импорта
+type ImportOptions struct {
+	Policy    string // PolicyUpsert (по умолчанию) или PolicyReplace
+	BatchSize int    // Документов в транзакции (0 - storage.batch_size репозитория)
+}
+
+// ImportResult итог импорта
+type ImportResult struct {
+	Manifest Manifest               `json:"manifest"`
+	Imported []string               `json:"imported"` // ID сохраненных документов
+	Deleted  []string               `json:"deleted"`  // ID документов, удаленных политикой replace
+	Errors   []domain.DocumentError `json:"-"`        // Ошибки отдельных документов
+}
+
+// ImportFile импортирует архив из файла path
+func ImportFile(repo *infrastructure.SQLiteDocumentRepository, path string, opts ImportOptions) (ImportResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("не удалось открыть архив: %w", err)
+	}
+	defer f.Close()
+	return Import(repo, f, opts)
+}
+
+// Import загружает документы архива из r. Архив сначала проверяется целиком по манифесту, поэтому неполный
+// или поврежденный архив отклоняется до первой записи в базу. Ошибки отдельных документов не прерывают импорт
+// и перечислены в ImportResult.Errors. Политика replace удаляет лишние документы только после безошибочного
+// импорта, поэтому ошибка сохранения не приводит к потере документов
+func Import(repo *infrastructure.SQLiteDocumentRepository, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	var result ImportResult
+	if opts.Policy == "" {
+		opts.Policy = PolicyUpsert
+	}
+	if opts.Policy != PolicyUpsert && opts.Policy != PolicyReplace {
+		return result, fmt.Errorf("неизвестная политика импорта %q (допустимо: %s, %s)", opts.Policy, PolicyUpsert, PolicyReplace)
+	}
+
+	manifest, stream, err := openArchive(r)
+	if err != nil {
+		return result, err
+	}
+	result.Manifest = manifest
+	if manifest.Version < 1 || manifest.Version > Version {
+		return result, fmt.Errorf("неподдерживаемая версия архива %d (поддерживается до %d)", manifest.Version, Version)
+	}
+
+	docs, inArchive, err := spool(stream, manifest)
+	if err != nil {
+		return result, err
+	}
+	defer os.Remove(docs.Name())
+	defer docs.Close()
+
+	indexer, err := repo.NewBulkIndexer(infrastructure.BulkOptions{BatchSize: opts.BatchSize, Replace: true,
+		OnResult: func(id string, err error) {
+			if err != nil {
+				result.Errors = append(result.Errors, domain.DocumentError{ID: id, Err: err})
+			} else {
+				result.Imported = append(result.Imported, id)
+			}
+		}})
+	if err != nil {
+		return result, err
+	}
+
+	readErr := readRecords(bufio.NewReader(docs), func(record Record) error {
+		return indexer.AddPrepared(infrastructure.PreparedDocument{
+			Document: domain.Document{ID: record.ID, Title: record.Title, Content: record.Content, CreatedAt: record.CreatedAt},
+			Chunks:   record.Chunks,
+		})
+	})
+	// Ошибки отдельных документов уже учтены в OnResult
+	var bulkErr *domain.BulkError
+	if err := indexer.Close(); err != nil && !errors.As(err, &bulkErr) && readErr == nil {
+		readErr = err
+	}
+	if readErr != nil {
+		return result, readErr
+	}
+
+	if opts.Policy == PolicyReplace && len(result.Errors) == 0 {
+		existing, err := repo.MatchDocumentIDs("*")
+		if err != nil {
+			return result, err
+		}
+		for _, id := range existing {
+			if inArchive[id] {
+				continue
+			}
+			if err := repo.DeleteDocument(id); err != nil {
+				return result, err
+			}
+			result.Deleted = append(result.Deleted, id)
+		}
+	}
+	return result, nil
+}
+
+// spool копирует документы архива во временный файл, проверяя их количество по манифесту, и возвращает
+// файл, установленный на начало, вместе с ID документов. Поток tar.gz читается один раз, поэтому без копии
+// проверить архив до импорта нельзя
+func spool(stream io.Reader, manifest Manifest) (*os.File, map[string]bool, error) {
+	docs, err := os.CreateTemp("", "rag-import-*.jsonl")
+	if err != nil {
+		return nil, nil, fmt.Errorf("не удалось создать временный файл: %w", err)
+	}
+	fail := func(err error) (*os.File, map[string]bool, error) {
+		docs.Close()
+		os.Remove(docs.Name())
+		return nil, nil, err
+	}
+
+	bw := bufio.NewWriter(docs)
+	ids := make(map[string]bool, manifest.Documents)
+	chunks := 0
+	err = readRecords(io.TeeReader(stream, bw), func(record Record) error {
+		ids[record.ID] = true
+		chunks += len(record.Chunks)
+		return nil
+	})
+	if err != nil {
+		return fail(err)
+	}
+	if len(ids) != manifest.Documents || chunks != manifest.Chunks {
+		return fail(fmt.Errorf("архив неполный: документов %d из %d, фрагментов %d из %d",
+			len(ids), manifest.Documents, chunks, manifest.Chunks))
+	}
+	if err := bw.Flush(); err != nil {
+		return fail(fmt.Errorf("ошибка записи временного файла: %w", err))
+	}
+	if _, err := docs.Seek(0, io.SeekStart); err != nil {
+		return fail(fmt.Errorf("ошибка чтения временного файла: %w", err))
+	}
+	return docs, ids, nil
+}
+
+// openArchive читает манифест и возвращает поток документов. tar.gz распознается по сигнатуре gzip
+func openArchive(r io.Reader) (Manifest, io.Reader, error) {
+	var manifest Manifest
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("не удалось прочитать архив: %w", err)
+	}
+
+	if magic[0] != 0x1f || magic[1] != 0x8b {
+		line, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return manifest, nil, fmt.Errorf("не удалось прочитать архив: %w", err)
+		}
+		if err := json.Unmarshal(line, &manifest); err != nil {
+			return manifest, nil, fmt.Errorf("некорректный манифест архива: %w", err)
+		}
+		return manifest, br, nil
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("не удалось распаковать архив: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	hasManifest := false
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return manifest, nil, fmt.Errorf("в архиве нет %s", documentsName)
+		}
+		if err != nil {
+			return manifest, nil, fmt.Errorf("не удалось прочитать архив: %w", err)
+		}
+		switch header.Name {
+		case manifestName:
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return manifest, nil, fmt.Errorf("некорректный манифест архива: %w", err)
+			}
+			hasManifest = true
+		case documentsName:
+			// Документы читаются потоком, поэтому манифест должен предшествовать им
+			if !hasManifest {
+				return manifest, nil, fmt.Errorf("в архиве нет %s перед %s", manifestName, documentsName)
+			}
+			return manifest, tr, nil
+		}
+	}
+}
+
+// readRecords передает в fn документы из потока JSONL
+func readRecords(r io.Reader, fn func(Record) error) error {
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var record Record
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("некорректный документ %d в архиве: %w", n, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
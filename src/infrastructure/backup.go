@@ -0,0 +1,112 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Backup сохраняет согласованную копию базы в новый файл path командой VACUUM INTO. Копия снимается
+// в одной транзакции чтения; файлы -wal и -shm копировать не нужно. VACUUM INTO запрещен соединениям
+// только для чтения, поэтому выполняется через соединение записи: запись этого процесса ждет окончания
+// копирования, а в режиме WAL другие процессы продолжают писать
+func (r *SQLiteDocumentRepository) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("файл %s уже существует", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("не удалось проверить файл копии: %w", err)
+	}
+	if _, err := r.db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("ошибка создания резервной копии: %w", err)
+	}
+	r.logger.Info("Создана резервная копия базы", "path", path)
+	return nil
+}
+
+// Snapshot согласованный снимок документов для экспорта: все чтения выполняются в одной транзакции,
+// поэтому одновременная запись не попадает в снимок частично. Снимок нужно закрыть методом Close
+type Snapshot struct {
+	tx *sqlx.Tx
+}
+
+// Snapshot открывает снимок документов
+func (r *SQLiteDocumentRepository) Snapshot() (*Snapshot, error) {
+	tx, err := r.reader.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	return &Snapshot{tx: tx}, nil
+}
+
+// Counts возвращает количество документов и фрагментов в снимке
+func (s *Snapshot) Counts() (documents, chunks int, err error) {
+	if err := s.tx.Get(&documents, "SELECT COUNT(*) FROM documents"); err != nil {
+		return 0, 0, fmt.Errorf("ошибка подсчета документов: %w", err)
+	}
+	if err := s.tx.Get(&chunks, "SELECT COUNT(*) FROM chunks"); err != nil {
+		return 0, 0, fmt.Errorf("ошибка подсчета фрагментов: %w", err)
+	}
+	return documents, chunks, nil
+}
+
+// Documents передает в fn документы снимка в порядке ID вместе с фрагментами в порядке их сохранения.
+// Документы и фрагменты читаются двумя курсорами в одном порядке ID и сопоставляются слиянием, поэтому
+// в памяти находится один документ, а его содержимое читается один раз. Ошибка fn прекращает обход
+// и возвращается как есть
+func (s *Snapshot) Documents(fn func(PreparedDocument) error) error {
+	docs, err := s.tx.Query("SELECT id, title, content, " + createdColumn + " FROM documents ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer docs.Close()
+	chunks, err := s.tx.Query("SELECT document_id, content FROM chunks ORDER BY document_id, rowid")
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer chunks.Close()
+
+	var chunkDoc, chunkContent string
+	nextChunk := func() (bool, error) {
+		if !chunks.Next() {
+			return false, chunks.Err()
+		}
+		return true, chunks.Scan(&chunkDoc, &chunkContent)
+	}
+	hasChunk, err := nextChunk()
+	if err != nil {
+		return fmt.Errorf("ошибка чтения фрагментов: %w", err)
+	}
+
+	for docs.Next() {
+		var doc PreparedDocument
+		var createdAtStr string
+		if err := docs.Scan(&doc.ID, &doc.Title, &doc.Content, &createdAtStr); err != nil {
+			return fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		doc.CreatedAt = parseTimestamp(createdAtStr)
+		doc.Chunks = []string{}
+		// Строки сравниваются побайтно, как в SQLite; фрагменты без документа пропускаются
+		for hasChunk && chunkDoc <= doc.ID {
+			if chunkDoc == doc.ID {
+				doc.Chunks = append(doc.Chunks, chunkContent)
+			}
+			if hasChunk, err = nextChunk(); err != nil {
+				return fmt.Errorf("ошибка чтения фрагментов: %w", err)
+			}
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	if err := docs.Err(); err != nil {
+		return fmt.Errorf("ошибка чтения строк: %w", err)
+	}
+	return nil
+}
+
+// Close завершает транзакцию снимка
+func (s *Snapshot) Close() error {
+	return s.tx.Rollback()
+}
@@ -0,0 +1,177 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"rag-system/src/domain"
+)
+
+// BulkOptions настройки пакетной индексации
+type BulkOptions struct {
+	BatchSize int  // Документов в одной транзакции (0 - storage.batch_size репозитория)
+	Replace   bool // Перезаписывать существующие документы (время создания сохраняется); иначе дубликат ID - ошибка
+	// OnResult вызывается для каждого документа после фиксации его пакета: err == nil - документ сохранен
+	OnResult func(id string, err error)
+}
+
+// BulkIndexer потоково сохраняет документы пакетами: документы пакета записываются в одной транзакции
+// через одни и те же подготовленные запросы. Ошибка одного документа откатывает только его (SAVEPOINT)
+// и не прерывает пакет. BulkIndexer не потокобезопасен: документы добавляет один писатель
+type BulkIndexer struct {
+	repo      *SQLiteDocumentRepository
+	batchSize int
+	onResult  func(id string, err error)
+	stmts     *insertStatements // Подготовлены один раз на все пакеты
+
+	tx      *sql.Tx
+	txStmts *insertStatements
+	batch   []bulkItem
+	indexed int
+	errs    []domain.DocumentError
+}
+
+// bulkItem документ текущего пакета
+type bulkItem struct {
+	id     string
+	chunks int
+	err    error
+}
+
+// NewBulkIndexer создает пакетный индексатор; после использования его нужно закрыть методом Close
+func (r *SQLiteDocumentRepository) NewBulkIndexer(opts BulkOptions) (*BulkIndexer, error) {
+	if opts.BatchSize < 0 {
+		return nil, fmt.Errorf("размер пакета не может быть отрицательным: %d", opts.BatchSize)
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = r.batchSize
+	}
+	stmts, err := prepareInsertStatements(r.db, opts.Replace)
+	if err != nil {
+		return nil, err
+	}
+	return &BulkIndexer{repo: r, batchSize: opts.BatchSize, onResult: opts.OnResult, stmts: stmts}, nil
+}
+
+// Add добавляет документ в текущий пакет и фиксирует пакет, когда он заполнен.
+// Ошибки отдельных документов накапливаются и возвращаются из Close; Add возвращает только ошибки
+// транзакции, после которых продолжать бессмысленно
+func (b *BulkIndexer) Add(doc domain.Document) error {
+	return b.AddPrepared(b.repo.PrepareDocument(doc))
+}
+
+// AddPrepared добавляет документ, уже разбитый на фрагменты методом PrepareDocument
+func (b *BulkIndexer) AddPrepared(doc PreparedDocument) error {
+	if b.tx == nil {
+		var tx *sql.Tx
+		err := b.repo.retryBusy(func() (err error) {
+			tx, err = b.repo.db.Begin()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("не удалось начать транзакцию: %w", err)
+		}
+		b.tx = tx
+		b.txStmts = &insertStatements{document: tx.Stmt(b.stmts.document), chunk: tx.Stmt(b.stmts.chunk)}
+		if b.stmts.deleteChunks != nil {
+			b.txStmts.deleteChunks = tx.Stmt(b.stmts.deleteChunks)
+		}
+	}
+
+	item := bulkItem{id: doc.ID}
+	if doc.ID == "" {
+		item.err = errors.New("пустой ID документа")
+	} else {
+		item.chunks, item.err = b.insert(doc)
+	}
+	b.batch = append(b.batch, item)
+
+	if len(b.batch) >= b.batchSize {
+		return b.Flush()
+	}
+	return nil
+}
+
+// insert сохраняет документ внутри точки сохранения, чтобы ошибка не затронула остальные документы пакета
+func (b *BulkIndexer) insert(doc PreparedDocument) (int, error) {
+	if _, err := b.tx.Exec("SAVEPOINT bulk_document"); err != nil {
+		return 0, fmt.Errorf("не удалось создать точку сохранения: %w", err)
+	}
+	chunks, err := b.repo.insertDocument(b.txStmts, doc)
+	if err != nil {
+		if _, rollbackErr := b.tx.Exec("ROLLBACK TO bulk_document"); rollbackErr != nil {
+			return 0, fmt.Errorf("%w (откат: %v)", err, rollbackErr)
+		}
+	}
+	if _, releaseErr := b.tx.Exec("RELEASE bulk_document"); releaseErr != nil && err == nil {
+		err = fmt.Errorf("не удалось освободить точку сохранения: %w", releaseErr)
+	}
+	return chunks, err
+}
+
+// Flush фиксирует текущий пакет и сообщает результаты его документов.
+// Если фиксация не удалась, все документы пакета считаются непроиндексированными
+func (b *BulkIndexer) Flush() error {
+	if b.tx == nil {
+		return nil
+	}
+	b.txStmts.Close()
+	commitErr := b.tx.Commit()
+	if commitErr != nil {
+		b.tx.Rollback()
+		commitErr = fmt.Errorf("не удалось зафиксировать транзакцию: %w", commitErr)
+	}
+
+	for _, item := range b.batch {
+		err := item.err
+		if err == nil && commitErr != nil {
+			err = commitErr
+		}
+		if err != nil {
+			b.errs = append(b.errs, domain.DocumentError{ID: item.id, Err: err})
+		} else {
+			b.indexed++
+			b.repo.metrics.DocumentIndexed(item.chunks)
+		}
+		if b.onResult != nil {
+			b.onResult(item.id, err)
+		}
+	}
+	b.tx, b.txStmts, b.batch = nil, nil, b.batch[:0]
+	return commitErr
+}
+
+// Indexed возвращает количество сохраненных документов в зафиксированных пакетах
+func (b *BulkIndexer) Indexed() int {
+	return b.indexed
+}
+
+// Close фиксирует последний пакет и освобождает подготовленные запросы. Если часть документов
+// не сохранена, возвращает *domain.BulkError с ошибкой каждого из них
+func (b *BulkIndexer) Close() error {
+	err := b.Flush()
+	b.stmts.Close()
+	if err != nil {
+		return err
+	}
+	if len(b.errs) > 0 {
+		return &domain.BulkError{Errors: b.errs}
+	}
+	return nil
+}
+
+// SaveDocuments сохраняет документы пакетами по storage.batch_size. Ошибка одного документа
+// не прерывает сохранение остальных: непроиндексированные документы перечисляются в *domain.BulkError
+func (r *SQLiteDocumentRepository) SaveDocuments(docs []domain.Document) error {
+	indexer, err := r.NewBulkIndexer(BulkOptions{})
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := indexer.Add(doc); err != nil {
+			indexer.Close()
+			return err
+		}
+	}
+	return indexer.Close()
+}
@@ -0,0 +1,211 @@
+// Package cache хранилища кэша ответов AI с TTL, ограничением размера и LRU вытеснением
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"rag-system/src/config"
+	"time"
+)
+
+// Поддерживаемые хранилища (поле cache.backend)
+const (
+	BackendFile   = "file"   // Файлы в директории, переживают перезапуск
+	BackendMemory = "memory" // LRU в памяти процесса
+	BackendSQLite = "sqlite" // Таблица в отдельной базе SQLite
+	BackendOff    = "off"    // Кэширование отключено
+)
+
+// Значения по умолчанию для путей хранилищ
+const (
+	DefaultDir  = "./cache/ai"
+	DefaultPath = "./cache/ai_cache.db"
+)
+
+// Config настройки кэша (секция cache в config.yaml)
+type Config = config.CacheConfig
+
+// Stats статистика кэша
+type Stats struct {
+	Backend     string
+	Entries     int
+	Bytes       int64
+	Hits        int64
+	Misses      int64
+	Evictions   int64 // Записи, вытесненные из-за ограничений размера
+	Expirations int64 // Записи, удаленные по истечении TTL
+}
+
+// HitRate возвращает долю попаданий среди всех обращений
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Cache хранилище ответов. Теги связывают запись с внешними сущностями (например, документами)
+// и позволяют удалить все записи, зависящие от измененной сущности.
+type Cache interface {
+	// Get возвращает значение по ключу; просроченные записи считаются отсутствующими
+	Get(key string) ([]byte, bool)
+
+	// Set сохраняет значение с тегами, при необходимости вытесняя давно не используемые записи
+	Set(key string, value []byte, tags []string) error
+
+	// Delete удаляет запись по ключу
+	Delete(key string) error
+
+	// InvalidateTags удаляет записи с любым из тегов и возвращает их количество
+	InvalidateTags(tags ...string) (int, error)
+
+	// Clear удаляет все записи
+	Clear() error
+
+	// Stats возвращает статистику кэша
+	Stats() Stats
+
+	// Close освобождает ресурсы хранилища
+	Close() error
+}
+
+// New создает кэш по конфигурации
+func New(cfg Config) (Cache, error) {
+	if cfg.TTL < 0 || cfg.MaxEntries < 0 || cfg.MaxBytes < 0 {
+		return nil, fmt.Errorf("ttl, max_entries и max_bytes кэша не могут быть отрицательными")
+	}
+
+	switch cfg.Backend {
+	case "", BackendFile:
+		if cfg.Dir == "" {
+			cfg.Dir = DefaultDir
+		}
+		return NewFileCache(cfg)
+	case BackendMemory:
+		return NewMemoryCache(cfg), nil
+	case BackendSQLite:
+		if cfg.Path == "" {
+			cfg.Path = DefaultPath
+		}
+		return NewSQLiteCache(cfg)
+	case BackendOff:
+		return noopCache{}, nil
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище кэша %q (допустимо: file, memory, sqlite, off)", cfg.Backend)
+	}
+}
+
+// noopCache кэш, который ничего не хранит (backend off)
+type noopCache struct{}
+
+func (noopCache) Get(string) ([]byte, bool)             { return nil, false }
+func (noopCache) Set(string, []byte, []string) error    { return nil }
+func (noopCache) Delete(string) error                   { return nil }
+func (noopCache) InvalidateTags(...string) (int, error) { return 0, nil }
+func (noopCache) Clear() error                          { return nil }
+func (noopCache) Stats() Stats                          { return Stats{Backend: BackendOff} }
+func (noopCache) Close() error                          { return nil }
+
+// expiresAt вычисляет момент истечения записи; нулевое время - без ограничения
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// item метаданные записи в LRU индексе; value заполняется только в памяти
+type item struct {
+	key       string
+	value     []byte
+	size      int64
+	tags      []string
+	expiresAt time.Time
+}
+
+// expired сообщает, истек ли срок жизни записи
+func (it *item) expired(now time.Time) bool {
+	return !it.expiresAt.IsZero() && now.After(it.expiresAt)
+}
+
+// lru индекс записей в порядке использования (начало списка - последние использованные)
+type lru struct {
+	order *list.List
+	items map[string]*list.Element
+	bytes int64
+}
+
+func newLRU() *lru {
+	return &lru{order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get возвращает запись и отмечает ее как использованную
+func (l *lru) get(key string) *item {
+	el, ok := l.items[key]
+	if !ok {
+		return nil
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(*item)
+}
+
+// add добавляет или заменяет запись
+func (l *lru) add(it *item) {
+	l.remove(it.key)
+	l.items[it.key] = l.order.PushFront(it)
+	l.bytes += it.size
+}
+
+// addOldest добавляет запись в конец очереди (при загрузке существующих записей)
+func (l *lru) addOldest(it *item) {
+	l.remove(it.key)
+	l.items[it.key] = l.order.PushBack(it)
+	l.bytes += it.size
+}
+
+// remove удаляет запись и возвращает ее
+func (l *lru) remove(key string) *item {
+	el, ok := l.items[key]
+	if !ok {
+		return nil
+	}
+	l.order.Remove(el)
+	delete(l.items, key)
+	it := el.Value.(*item)
+	l.bytes -= it.size
+	return it
+}
+
+// oldest возвращает давно не использованную запись
+func (l *lru) oldest() *item {
+	if el := l.order.Back(); el != nil {
+		return el.Value.(*item)
+	}
+	return nil
+}
+
+// overflow сообщает, превышены ли ограничения конфигурации
+func (l *lru) overflow(cfg Config) bool {
+	return (cfg.MaxEntries > 0 && len(l.items) > cfg.MaxEntries) ||
+		(cfg.MaxBytes > 0 && l.bytes > cfg.MaxBytes)
+}
+
+// tagged возвращает ключи записей с любым из тегов
+func (l *lru) tagged(tags []string) []string {
+	targets := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		targets[tag] = true
+	}
+
+	var keys []string
+	for key, el := range l.items {
+		for _, tag := range el.Value.(*item).tags {
+			if targets[tag] {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fileRecord формат записи файлового кэша
+type fileRecord struct {
+	Key       string    `json:"key"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Value     []byte    `json:"value"`
+}
+
+// safeFileName допустимые имена файлов записей; остальные ключи хешируются
+var safeFileName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// FileCache кэш в директории: одна запись - один JSON файл.
+// Порядок использования хранится во времени модификации файлов, поэтому LRU сохраняется после перезапуска.
+type FileCache struct {
+	mu    sync.Mutex
+	cfg   Config
+	index *lru
+	stats Stats
+}
+
+// NewFileCache открывает файловый кэш и загружает индекс существующих записей
+func NewFileCache(cfg Config) (*FileCache, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию кэша: %w", err)
+	}
+
+	c := &FileCache{cfg: cfg, index: newLRU(), stats: Stats{Backend: BackendFile}}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// path возвращает путь к файлу записи
+func (c *FileCache) path(key string) string {
+	name := key
+	if !safeFileName.MatchString(key) {
+		name = fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+	}
+	return filepath.Join(c.cfg.Dir, name+".json")
+}
+
+// load строит индекс по файлам директории, от давно использованных к недавним
+func (c *FileCache) load() error {
+	// Записи формата до появления хранилищ (*.txt) не читаются
+	legacy, _ := filepath.Glob(filepath.Join(c.cfg.Dir, "*.txt"))
+	for _, file := range legacy {
+		os.Remove(file)
+	}
+
+	files, err := filepath.Glob(filepath.Join(c.cfg.Dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("ошибка чтения директории кэша: %w", err)
+	}
+
+	type loaded struct {
+		item    *item
+		modTime time.Time
+	}
+	var entries []loaded
+	now := time.Now()
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		record, err := readRecord(file)
+		if err != nil || record.Key == "" {
+			// Поврежденная запись или запись старого формата
+			os.Remove(file)
+			continue
+		}
+		it := &item{key: record.Key, size: int64(len(record.Value)), tags: record.Tags, expiresAt: record.ExpiresAt}
+		if it.expired(now) {
+			os.Remove(file)
+			continue
+		}
+		entries = append(entries, loaded{item: it, modTime: info.ModTime()})
+	}
+
+	// Новые записи добавляются в конец, поэтому начинаем с самых свежих
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.After(entries[j].modTime) })
+	for _, e := range entries {
+		c.index.addOldest(e.item)
+	}
+	return nil
+}
+
+// readRecord читает запись из файла
+func readRecord(file string) (*fileRecord, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var record fileRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Get возвращает значение по ключу. Запись, добавленная другим процессом после открытия кэша, тоже находится.
+func (c *FileCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file := c.path(key)
+	record, err := readRecord(file)
+	if err != nil || record.Key != key {
+		c.index.remove(key)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	now := time.Now()
+	it := &item{key: key, size: int64(len(record.Value)), tags: record.Tags, expiresAt: record.ExpiresAt}
+	if it.expired(now) {
+		c.index.remove(key)
+		os.Remove(file)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return nil, false
+	}
+
+	// Отмечаем использование и в индексе, и во времени модификации файла
+	c.index.add(it)
+	os.Chtimes(file, now, now)
+	c.stats.Hits++
+	return record.Value, true
+}
+
+// Set сохраняет значение; значение больше max_bytes не кэшируется
+func (c *FileCache) Set(key string, value []byte, tags []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := int64(len(value))
+	if c.cfg.MaxBytes > 0 && size > c.cfg.MaxBytes {
+		return c.delete(key)
+	}
+
+	now := time.Now()
+	record := fileRecord{Key: key, Tags: tags, CreatedAt: now, ExpiresAt: expiresAt(now, c.cfg.TTL), Value: value}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации записи кэша: %w", err)
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы читатели не увидели запись частично
+	file := c.path(key)
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи кэша: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ошибка записи кэша: %w", err)
+	}
+
+	c.index.add(&item{key: key, size: size, tags: tags, expiresAt: record.ExpiresAt})
+	c.evict()
+	return nil
+}
+
+// evict удаляет давно не использованные записи, пока не соблюдены ограничения
+func (c *FileCache) evict() {
+	for c.index.overflow(c.cfg) {
+		it := c.index.remove(c.index.oldest().key)
+		os.Remove(c.path(it.key))
+		c.stats.Evictions++
+	}
+}
+
+// delete удаляет запись; вызывается под блокировкой
+func (c *FileCache) delete(key string) error {
+	c.index.remove(key)
+	if err := os.Remove(c.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка удаления записи кэша: %w", err)
+	}
+	return nil
+}
+
+// Delete удаляет запись по ключу
+func (c *FileCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.delete(key)
+}
+
+// InvalidateTags удаляет записи с любым из тегов
+func (c *FileCache) InvalidateTags(tags ...string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := c.index.tagged(tags)
+	for _, key := range keys {
+		if err := c.delete(key); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
+
+// Clear удаляет все записи
+func (c *FileCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(c.cfg.Dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("ошибка чтения директории кэша: %w", err)
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("ошибка удаления записи кэша: %w", err)
+		}
+	}
+
+	c.index = newLRU()
+	return nil
+}
+
+// Stats возвращает статистику кэша
+func (c *FileCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.index.items)
+	stats.Bytes = c.index.bytes
+	return stats
+}
+
+// Close ничего не делает: записи уже сохранены на диске
+func (c *FileCache) Close() error {
+	return nil
+}
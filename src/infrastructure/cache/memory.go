@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryCache LRU кэш в памяти процесса
+type MemoryCache struct {
+	mu    sync.Mutex
+	cfg   Config
+	index *lru
+	stats Stats
+}
+
+// NewMemoryCache создает кэш в памяти
+func NewMemoryCache(cfg Config) *MemoryCache {
+	return &MemoryCache{cfg: cfg, index: newLRU(), stats: Stats{Backend: BackendMemory}}
+}
+
+// Get возвращает значение по ключу
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	it := c.index.get(key)
+	if it == nil {
+		c.stats.Misses++
+		return nil, false
+	}
+	if it.expired(time.Now()) {
+		c.index.remove(key)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	return append([]byte(nil), it.value...), true
+}
+
+// Set сохраняет значение; значение больше max_bytes не кэшируется
+func (c *MemoryCache) Set(key string, value []byte, tags []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := int64(len(value))
+	if c.cfg.MaxBytes > 0 && size > c.cfg.MaxBytes {
+		c.index.remove(key)
+		return nil
+	}
+
+	c.index.add(&item{
+		key:       key,
+		value:     append([]byte(nil), value...),
+		size:      size,
+		tags:      append([]string(nil), tags...),
+		expiresAt: expiresAt(time.Now(), c.cfg.TTL),
+	})
+
+	for c.index.overflow(c.cfg) {
+		c.index.remove(c.index.oldest().key)
+		c.stats.Evictions++
+	}
+	return nil
+}
+
+// Delete удаляет запись по ключу
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.index.remove(key)
+	return nil
+}
+
+// InvalidateTags удаляет записи с любым из тегов
+func (c *MemoryCache) InvalidateTags(tags ...string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := c.index.tagged(tags)
+	for _, key := range keys {
+		c.index.remove(key)
+	}
+	return len(keys), nil
+}
+
+// Clear удаляет все записи
+func (c *MemoryCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.index = newLRU()
+	return nil
+}
+
+// Stats возвращает статистику кэша
+func (c *MemoryCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.index.items)
+	stats.Bytes = c.index.bytes
+	return stats
+}
+
+// Close ничего не делает: кэш в памяти не держит внешних ресурсов
+func (c *MemoryCache) Close() error {
+	return nil
+}
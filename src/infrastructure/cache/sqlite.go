@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteCache кэш в таблице SQLite. Время последнего обращения хранится в таблице, поэтому
+// LRU порядок и записи переживают перезапуск и доступны нескольким процессам.
+type SQLiteCache struct {
+	mu    sync.Mutex
+	db    *sqlx.DB
+	cfg   Config
+	stats Stats
+}
+
+// NewSQLiteCache открывает базу кэша и создает таблицы
+func NewSQLiteCache(cfg Config) (*SQLiteCache, error) {
+	if dir := filepath.Dir(cfg.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("не удалось создать директорию кэша: %w", err)
+		}
+	}
+
+	db, err := sqlx.Connect("sqlite3", cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть базу кэша: %w", err)
+	}
+	// Одно соединение: SQLite все равно сериализует запись, а так не возникает SQLITE_BUSY внутри процесса
+	db.SetMaxOpenConns(1)
+
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS ai_cache (
+			key TEXT PRIMARY KEY,
+			value BLOB NOT NULL,
+			size INTEGER NOT NULL,
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL DEFAULT 0,
+			accessed_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_cache_accessed ON ai_cache(accessed_at)`,
+		`CREATE TABLE IF NOT EXISTS ai_cache_tags (
+			key TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (key, tag)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_cache_tags_tag ON ai_cache_tags(tag)`,
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("ошибка при создании таблицы кэша: %w", err)
+		}
+	}
+
+	c := &SQLiteCache{db: db, cfg: cfg, stats: Stats{Backend: BackendSQLite}}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.evict(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get возвращает значение по ключу
+func (c *SQLiteCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var row struct {
+		Value     []byte `db:"value"`
+		ExpiresAt int64  `db:"expires_at"`
+	}
+	err := c.db.Get(&row, "SELECT value, expires_at FROM ai_cache WHERE key = ?", key)
+	if err != nil {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	now := time.Now()
+	if row.ExpiresAt > 0 && now.UnixNano() > row.ExpiresAt {
+		if err := c.deleteKeys(key); err == nil {
+			c.stats.Expirations++
+		}
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.db.Exec("UPDATE ai_cache SET accessed_at = ? WHERE key = ?", now.UnixNano(), key)
+	c.stats.Hits++
+	return row.Value, true
+}
+
+// Set сохраняет значение; значение больше max_bytes не кэшируется
+func (c *SQLiteCache) Set(key string, value []byte, tags []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cfg.MaxBytes > 0 && int64(len(value)) > c.cfg.MaxBytes {
+		return c.deleteKeys(key)
+	}
+
+	now := time.Now()
+	var expires int64
+	if t := expiresAt(now, c.cfg.TTL); !t.IsZero() {
+		expires = t.UnixNano()
+	}
+
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции кэша: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO ai_cache (key, value, size, created_at, expires_at, accessed_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, key, value, len(value), now.UnixNano(), expires, now.UnixNano()); err != nil {
+		return fmt.Errorf("ошибка записи кэша: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM ai_cache_tags WHERE key = ?", key); err != nil {
+		return fmt.Errorf("ошибка записи тегов кэша: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO ai_cache_tags (key, tag) VALUES (?, ?)", key, tag); err != nil {
+			return fmt.Errorf("ошибка записи тегов кэша: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации записи кэша: %w", err)
+	}
+
+	return c.evict()
+}
+
+// evict удаляет давно не использованные записи, пока не соблюдены ограничения; вызывается под блокировкой
+func (c *SQLiteCache) evict() error {
+	if c.cfg.MaxEntries <= 0 && c.cfg.MaxBytes <= 0 {
+		return nil
+	}
+
+	for {
+		entries, bytes, err := c.usage()
+		if err != nil {
+			return err
+		}
+		if !(c.cfg.MaxEntries > 0 && entries > c.cfg.MaxEntries) && !(c.cfg.MaxBytes > 0 && bytes > c.cfg.MaxBytes) {
+			return nil
+		}
+
+		var key string
+		if err := c.db.Get(&key, "SELECT key FROM ai_cache ORDER BY accessed_at ASC LIMIT 1"); err != nil {
+			return fmt.Errorf("ошибка выбора записи для вытеснения: %w", err)
+		}
+		if err := c.deleteKeys(key); err != nil {
+			return err
+		}
+		c.stats.Evictions++
+	}
+}
+
+// usage возвращает количество записей и суммарный размер значений
+func (c *SQLiteCache) usage() (int, int64, error) {
+	var row struct {
+		Entries int           `db:"entries"`
+		Bytes   sql.NullInt64 `db:"bytes"`
+	}
+	if err := c.db.Get(&row, "SELECT COUNT(*) AS entries, SUM(size) AS bytes FROM ai_cache"); err != nil {
+		return 0, 0, fmt.Errorf("ошибка подсчета размера кэша: %w", err)
+	}
+	return row.Entries, row.Bytes.Int64, nil
+}
+
+// deleteKeys удаляет записи и их теги; вызывается под блокировкой
+func (c *SQLiteCache) deleteKeys(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции кэша: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"ai_cache", "ai_cache_tags"} {
+		query, args, err := sqlx.In("DELETE FROM "+table+" WHERE key IN (?)", keys)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return fmt.Errorf("ошибка удаления записи кэша: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete удаляет запись по ключу
+func (c *SQLiteCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.deleteKeys(key)
+}
+
+// InvalidateTags удаляет записи с любым из тегов
+func (c *SQLiteCache) InvalidateTags(tags ...string) (int, error) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	query, args, err := sqlx.In("SELECT DISTINCT key FROM ai_cache_tags WHERE tag IN (?)", tags)
+	if err != nil {
+		return 0, err
+	}
+	var keys []string
+	if err := c.db.Select(&keys, query, args...); err != nil {
+		return 0, fmt.Errorf("ошибка поиска записей кэша по тегам: %w", err)
+	}
+	if err := c.deleteKeys(keys...); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// Clear удаляет все записи
+func (c *SQLiteCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, table := range []string{"ai_cache", "ai_cache_tags"} {
+		if _, err := c.db.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("ошибка очистки кэша: %w", err)
+		}
+	}
+	return nil
+}
+
+// Stats возвращает статистику кэша
+func (c *SQLiteCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	if entries, bytes, err := c.usage(); err == nil {
+		stats.Entries = entries
+		stats.Bytes = bytes
+	}
+	return stats
+}
+
+// Close закрывает соединение с базой кэша
+func (c *SQLiteCache) Close() error {
+	return c.db.Close()
+}
@@ -0,0 +1,370 @@
+// Package ingest конвейер загрузки каталогов с документами.
+//
+// Файлы читаются и разбиваются на фрагменты параллельно несколькими воркерами, а в SQLite пишет
+// единственный писатель через BulkIndexer. Стадии связаны очередями ограниченной емкости, поэтому
+// медленная запись притормаживает чтение, а не накапливает документы в памяти. Проиндексированные
+// файлы записываются в журнал состояния: прерванная загрузка продолжается с места остановки,
+// а неизмененные файлы, документы которых есть в базе, при повторной загрузке пропускаются.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options настройки конвейера (секция ingest конфигурации)
+type Options struct {
+	Workers    int      // Воркеров чтения и разбиения (0 - 4)
+	QueueSize  int      // Емкость очередей между стадиями (0 - 64)
+	BatchSize  int      // Документов в транзакции записи (0 - storage.batch_size)
+	Extensions []string // Расширения загружаемых файлов (пусто - .txt и .md)
+	StatePath  string   // Журнал проиндексированных файлов; пусто - загрузка не возобновляется
+
+	Progress         func(Progress) // Вызывается периодически и по завершении
+	ProgressInterval time.Duration  // Период вызова Progress (0 - 1 секунда)
+	Logger           *slog.Logger
+}
+
+// Progress ход загрузки
+type Progress struct {
+	Total   int           `json:"total"`   // Файлов к загрузке в этом запуске
+	Done    int           `json:"done"`    // Проиндексировано
+	Failed  int           `json:"failed"`  // Не удалось прочитать или сохранить
+	Skipped int           `json:"skipped"` // Не изменились с прошлой загрузки
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Rate файлов в секунду
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Done+p.Failed) / p.Elapsed.Seconds()
+}
+
+// ETA оценка оставшегося времени; 0, если оценить нельзя
+func (p Progress) ETA() time.Duration {
+	rate := p.Rate()
+	remaining := p.Total - p.Done - p.Failed
+	if rate <= 0 || remaining <= 0 {
+		return 0
+	}
+	return time.Duration(float64(remaining) / rate * float64(time.Second))
+}
+
+// Result итог загрузки
+type Result struct {
+	Progress
+	Indexed []string               // ID проиндексированных документов
+	Errors  []domain.DocumentError // Ошибки отдельных файлов
+}
+
+// Pipeline конвейер загрузки
+type Pipeline struct {
+	repo   *infrastructure.SQLiteDocumentRepository
+	opts   Options
+	logger *slog.Logger
+}
+
+// file файл для загрузки
+type file struct {
+	path    string // Путь, как он получен обходом каталога; становится ID документа
+	abs     string
+	size    int64
+	modTime time.Time
+}
+
+// item результат стадии чтения
+type item struct {
+	file file
+	doc  infrastructure.PreparedDocument
+	err  error
+}
+
+// New создает конвейер загрузки в репозиторий repo
+func New(repo *infrastructure.SQLiteDocumentRepository, opts Options) *Pipeline {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 64
+	}
+	if len(opts.Extensions) == 0 {
+		opts.Extensions = []string{".txt", ".md"}
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Pipeline{repo: repo, opts: opts, logger: opts.Logger.With("component", "ingest")}
+}
+
+// Run загружает файл или каталог root. Документ получает ID и название по пути файла, как при -action=index;
+// существующие документы перезаписываются. При отмене ctx уже прочитанные и записанные документы
+// фиксируются, а Run возвращает частичный результат вместе с ошибкой контекста
+func (p *Pipeline) Run(ctx context.Context, root string) (Result, error) {
+	var result Result
+	st, err := openState(p.opts.StatePath)
+	if err != nil {
+		return result, err
+	}
+	defer st.close()
+
+	files, skipped, err := p.scan(ctx, root, st)
+	if err != nil {
+		return result, err
+	}
+
+	tracker := &tracker{start: time.Now(), progress: Progress{Total: len(files), Skipped: skipped}}
+	stopReporter := p.report(tracker)
+	finish := func() {
+		stopReporter()
+		result.Progress = tracker.snapshot()
+		if p.opts.Progress != nil {
+			p.opts.Progress(result.Progress)
+		}
+	}
+
+	// Стадии чтения работают в отдельном контексте: писатель останавливает их и при собственной ошибке
+	readCtx, cancelRead := context.WithCancel(ctx)
+	defer cancelRead()
+	prepared := p.read(readCtx, files)
+
+	pending := make(map[string]file)
+	var stateErr error
+	indexer, err := p.repo.NewBulkIndexer(infrastructure.BulkOptions{BatchSize: p.opts.BatchSize, Replace: true,
+		OnResult: func(id string, err error) {
+			f := pending[id]
+			delete(pending, id)
+			if err != nil {
+				result.Errors = append(result.Errors, domain.DocumentError{ID: id, Err: err})
+				tracker.add(0, 1)
+				return
+			}
+			if recordErr := st.record(f); recordErr != nil && stateErr == nil {
+				stateErr = recordErr
+			}
+			result.Indexed = append(result.Indexed, id)
+			tracker.add(1, 0)
+		}})
+	if err != nil {
+		finish()
+		return result, err
+	}
+
+	// Единственный писатель: SQLite не допускает параллельных транзакций записи
+	var writeErr error
+	for it := range prepared {
+		if ctx.Err() != nil {
+			// Документы, прочитанные после отмены, не записываются: их загрузит следующий запуск
+			continue
+		}
+		if it.err != nil {
+			result.Errors = append(result.Errors, domain.DocumentError{ID: it.file.path, Err: it.err})
+			tracker.add(0, 1)
+			continue
+		}
+		pending[it.doc.ID] = it.file
+		if err := indexer.AddPrepared(it.doc); err != nil {
+			writeErr = err
+			cancelRead()
+			break
+		}
+	}
+	for range prepared {
+		// Дожидаемся остановки воркеров
+	}
+
+	// Ошибки отдельных документов уже учтены в OnResult
+	var bulkErr *domain.BulkError
+	if err := indexer.Close(); err != nil && !errors.As(err, &bulkErr) && writeErr == nil {
+		writeErr = err
+	}
+	if writeErr == nil {
+		writeErr = stateErr
+	}
+	if writeErr == nil {
+		writeErr = ctx.Err()
+	}
+	finish()
+	p.logger.Debug("Загрузка завершена", "root", root, "indexed", len(result.Indexed),
+		"failed", len(result.Errors), "skipped", skipped, "error", writeErr)
+	return result, writeErr
+}
+
+// scan обходит root и возвращает файлы с подходящими расширениями, которых нет в журнале состояния
+// или чьих документов уже нет в базе
+func (p *Pipeline) scan(ctx context.Context, root string, st *state) ([]file, int, error) {
+	var files, unchanged []file
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() || !p.matches(path) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		f := file{path: path, abs: abs, size: info.Size(), modTime: info.ModTime()}
+		if st.done(f) {
+			unchanged = append(unchanged, f)
+			return nil
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка обхода %s: %w", root, err)
+	}
+	if len(unchanged) == 0 {
+		return files, 0, nil
+	}
+
+	// Журнал не знает об удалении документов (-action=delete, import -policy=replace), поэтому
+	// неизмененный файл пропускается, только если его документ есть в базе
+	ids, err := p.repo.MatchDocumentIDs("*")
+	if err != nil {
+		return nil, 0, err
+	}
+	existing := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		existing[id] = true
+	}
+	skipped := 0
+	for _, f := range unchanged {
+		if existing[f.path] {
+			skipped++
+		} else {
+			files = append(files, f)
+		}
+	}
+	return files, skipped, nil
+}
+
+// matches проверяет расширение файла без учета регистра
+func (p *Pipeline) matches(path string) bool {
+	ext := filepath.Ext(path)
+	for _, allowed := range p.opts.Extensions {
+		if strings.EqualFold(ext, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// read запускает подачу файлов и воркеры чтения; возвращаемый канал закрывается, когда воркеры завершились
+func (p *Pipeline) read(ctx context.Context, files []file) <-chan item {
+	paths := make(chan file, p.opts.QueueSize)
+	prepared := make(chan item, p.opts.QueueSize)
+
+	go func() {
+		defer close(paths)
+		for _, f := range files {
+			select {
+			case paths <- f:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range paths {
+				it := p.prepare(f)
+				select {
+				case prepared <- it:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(prepared)
+	}()
+	return prepared
+}
+
+// prepare читает файл и разбивает его на фрагменты
+func (p *Pipeline) prepare(f file) item {
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return item{file: f, err: fmt.Errorf("ошибка чтения документа: %w", err)}
+	}
+	doc := domain.Document{ID: f.path, Title: f.path, Content: string(content)}
+	return item{file: f, doc: p.repo.PrepareDocument(doc)}
+}
+
+// report периодически вызывает Progress; возвращает функцию остановки
+func (p *Pipeline) report(t *tracker) func() {
+	if p.opts.Progress == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(p.opts.ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.opts.Progress(t.snapshot())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// tracker счетчики хода загрузки, общие для писателя и отчета о прогрессе
+type tracker struct {
+	mu       sync.Mutex
+	start    time.Time
+	progress Progress
+}
+
+func (t *tracker) add(done, failed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Done += done
+	t.progress.Failed += failed
+}
+
+func (t *tracker) snapshot() Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	progress := t.progress
+	progress.Elapsed = time.Since(t.start)
+	return progress
+}
@@ -0,0 +1,88 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// stateEntry проиндексированный файл: при повторном запуске файл пропускается, если размер и время изменения совпадают
+type stateEntry struct {
+	Path    string    `json:"path"` // Абсолютный путь
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// state журнал проиндексированных файлов в формате JSON lines. Записи только добавляются,
+// поэтому прерванный запуск теряет не больше одной строки
+type state struct {
+	mu      sync.Mutex
+	entries map[string]stateEntry
+	file    *os.File
+}
+
+// openState читает журнал path и открывает его для дополнения; пустой path - журнал не ведется
+func openState(path string) (*state, error) {
+	s := &state{entries: make(map[string]stateEntry)}
+	if path == "" {
+		return s, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл состояния: %w", err)
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry stateEntry
+		// Недописанная последняя строка после аварийного завершения пропускается
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && entry.Path != "" {
+			s.entries[entry.Path] = entry
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("не удалось прочитать файл состояния: %w", err)
+	}
+	s.file = file
+	return s, nil
+}
+
+// done сообщает, проиндексирован ли файл в текущем виде
+func (s *state) done(f file) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[f.abs]
+	return ok && entry.Size == f.size && entry.ModTime.Equal(f.modTime)
+}
+
+// record отмечает файл проиндексированным
+func (s *state) record(f file) error {
+	entry := stateEntry{Path: f.abs, Size: f.size, ModTime: f.modTime}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[f.abs] = entry
+	if s.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("не удалось записать файл состояния: %w", err)
+	}
+	return nil
+}
+
+// close сбрасывает журнал на диск
+func (s *state) close() error {
+	if s.file == nil {
+		return nil
+	}
+	return errors.Join(s.file.Sync(), s.file.Close())
+}
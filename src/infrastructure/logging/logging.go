@@ -0,0 +1,141 @@
+// Package logging журнал процесса на log/slog: уровень и формат из секции logging конфигурации,
+// скрытие секретов, идентификаторы запросов и трасс из контекста
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"rag-system/src/config"
+	"rag-system/src/infrastructure/tracing"
+	"sync/atomic"
+)
+
+// Output журнал процесса. Уровень и скрываемые секреты меняются методом Apply при перезагрузке конфигурации;
+// логгеры, уже переданные компонентам, продолжают работать с новыми настройками.
+type Output struct {
+	level  slog.LevelVar
+	writer *swapWriter
+	logger *slog.Logger
+}
+
+// New создает журнал, пишущий в w в формате logging.format (text или json) с уровнем logging.level.
+// Значения секретов конфигурации заменяются маской.
+func New(w io.Writer, cfg config.Config) *Output {
+	o := &Output{writer: &swapWriter{base: w}}
+	o.Apply(cfg)
+
+	opts := &slog.HandlerOptions{Level: &o.level}
+	var handler slog.Handler
+	if cfg.Logging.Format == "json" {
+		handler = slog.NewJSONHandler(o.writer, opts)
+	} else {
+		handler = slog.NewTextHandler(o.writer, opts)
+	}
+	o.logger = slog.New(contextHandler{handler})
+	return o
+}
+
+// Logger возвращает логгер журнала
+func (o *Output) Logger() *slog.Logger {
+	return o.logger
+}
+
+// Apply применяет уровень и секреты новой конфигурации. Формат меняется только при перезапуске.
+func (o *Output) Apply(cfg config.Config) {
+	o.level.Set(ParseLevel(cfg.Logging.Level))
+	o.writer.set(config.NewMaskingWriter(o.writer.base, cfg.Secrets()...))
+}
+
+// ParseLevel возвращает уровень по имени (debug, info, warn, error); неизвестное имя - info
+func ParseLevel(name string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// Discard возвращает логгер, который ничего не пишет
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// swapWriter io.Writer, назначение которого можно заменить во время работы
+type swapWriter struct {
+	base    io.Writer
+	current atomic.Pointer[writerBox]
+}
+
+// writerBox обертка для хранения интерфейса в atomic.Pointer
+type writerBox struct {
+	io.Writer
+}
+
+func (s *swapWriter) set(w io.Writer) {
+	s.current.Store(&writerBox{w})
+}
+
+// Write пишет запись целиком в текущее назначение; обработчики slog передают каждую запись одним вызовом
+func (s *swapWriter) Write(p []byte) (int, error) {
+	return s.current.Load().Write(p)
+}
+
+// requestIDKey ключ идентификатора запроса в контексте
+type requestIDKey struct{}
+
+// WithRequestID возвращает контекст с идентификатором запроса; записи журнала с этим контекстом содержат request_id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// EnsureRequestID возвращает контекст с идентификатором запроса, создавая новый, если его еще нет
+func EnsureRequestID(ctx context.Context) context.Context {
+	if RequestID(ctx) != "" {
+		return ctx
+	}
+	return WithRequestID(ctx, NewRequestID())
+}
+
+// NewRequestID создает случайный идентификатор запроса
+func NewRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// contextHandler добавляет к записям идентификатор запроса и трассы из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		r.AddAttrs(slog.String("trace_id", span.SpanContext().TraceID.String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
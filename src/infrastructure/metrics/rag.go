@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"net/http"
+	"rag-system/src/domain"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Источники ответа для метки source метрики rag_generation_duration_seconds
+const (
+	SourceAPI       = "api"       // Вызов AI API
+	SourceCache     = "cache"     // Кэш ответов
+	SourceSemantic  = "semantic"  // Семантический кэш
+	SourceCoalesced = "coalesced" // Вызов API, запущенный одновременным одинаковым запросом
+	SourceError     = "error"     // Запрос завершился ошибкой
+)
+
+// RAG метрики поиска, генерации, кэша, HTTP API и индексации. Методы nil получателя ничего не делают,
+// поэтому компоненты могут работать без метрик.
+type RAG struct {
+	registry *Registry
+
+	searchDuration     *Histogram
+	generationDuration *Histogram
+	generationRetries  *Histogram
+	cacheHits          *Counter
+	cacheMisses        *Counter
+	httpRequests       *Counter
+	indexedDocuments   *Counter
+	indexedChunks      *Counter
+	updatedDocuments   *Counter
+}
+
+// NewRAG регистрирует метрики RAG системы в registry
+func NewRAG(registry *Registry) *RAG {
+	return &RAG{
+		registry: registry,
+		searchDuration: registry.Histogram("rag_search_duration_seconds",
+			"Длительность поиска фрагментов в индексе", nil),
+		generationDuration: registry.Histogram("rag_generation_duration_seconds",
+			"Длительность генерации ответа по источнику ответа", nil, "source"),
+		generationRetries: registry.Histogram("rag_generation_retries",
+			"Повторные попытки запроса к AI API", []float64{0, 1, 2, 3, 5, 10}),
+		cacheHits: registry.Counter("rag_cache_hits_total",
+			"Ответы, полученные из кэша, по виду кэша", "kind"),
+		cacheMisses: registry.Counter("rag_cache_misses_total",
+			"Запросы генерации, не найденные в кэше"),
+		httpRequests: registry.Counter("rag_http_requests_total",
+			"Запросы к HTTP API по методу, маршруту и коду ответа", "method", "path", "code"),
+		indexedDocuments: registry.Counter("rag_indexed_documents_total",
+			"Проиндексированные документы"),
+		indexedChunks: registry.Counter("rag_indexed_chunks_total",
+			"Фрагменты проиндексированных документов"),
+		updatedDocuments: registry.Counter("rag_updated_documents_total",
+			"Обновленные документы"),
+	}
+}
+
+// Registry возвращает реестр метрик
+func (m *RAG) Registry() *Registry {
+	if m == nil {
+		return nil
+	}
+	return m.registry
+}
+
+// ObserveSearch записывает длительность поиска
+func (m *RAG) ObserveSearch(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.searchDuration.Observe(d.Seconds())
+}
+
+// ObserveGeneration записывает длительность генерации и, если выполнялся вызов API, количество повторов
+func (m *RAG) ObserveGeneration(source string, d time.Duration, retries int) {
+	if m == nil {
+		return
+	}
+	m.generationDuration.Observe(d.Seconds(), source)
+	if source == SourceAPI || source == SourceError {
+		m.generationRetries.Observe(float64(retries))
+	}
+}
+
+// CacheHit учитывает ответ из кэша вида kind (cache или semantic)
+func (m *RAG) CacheHit(kind string) {
+	if m == nil {
+		return
+	}
+	m.cacheHits.Inc(kind)
+}
+
+// CacheMiss учитывает промах кэша
+func (m *RAG) CacheMiss() {
+	if m == nil {
+		return
+	}
+	m.cacheMisses.Inc()
+}
+
+// httpMethods методы HTTP, которые попадают в метку method как есть
+var httpMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// HTTPRequest учитывает запрос к HTTP API. Нестандартные методы учитываются как other, чтобы клиент
+// не мог создавать новые ряды метрики
+func (m *RAG) HTTPRequest(method, path string, code int) {
+	if m == nil {
+		return
+	}
+	if !httpMethods[method] {
+		method = "other"
+	}
+	m.httpRequests.Inc(method, path, strconv.Itoa(code))
+}
+
+// DocumentIndexed учитывает проиндексированный документ из chunks фрагментов
+func (m *RAG) DocumentIndexed(chunks int) {
+	if m == nil {
+		return
+	}
+	m.indexedDocuments.Inc()
+	m.indexedChunks.Add(float64(chunks))
+}
+
+// DocumentUpdated учитывает обновление существующего документа; счетчики индексации не меняются
+func (m *RAG) DocumentUpdated() {
+	if m == nil {
+		return
+	}
+	m.updatedDocuments.Inc()
+}
+
+// ObserveIndex регистрирует измерители размера индекса. stats вызывается один раз при каждом выводе метрик,
+// и все три измерителя показывают результат этого вызова
+func (m *RAG) ObserveIndex(stats func() (domain.IndexStats, error)) {
+	if m == nil {
+		return
+	}
+	var (
+		mu      sync.Mutex
+		current domain.IndexStats
+		lastErr error
+	)
+	m.registry.OnCollect(func() {
+		s, err := stats()
+		mu.Lock()
+		defer mu.Unlock()
+		current, lastErr = s, err
+	})
+	gauge := func(name, help string, value func(domain.IndexStats) float64) {
+		m.registry.GaugeFunc(name, help, func() (float64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return value(current), lastErr
+		})
+	}
+	gauge("rag_index_documents", "Документы в индексе", func(s domain.IndexStats) float64 { return float64(s.Documents) })
+	gauge("rag_index_chunks", "Фрагменты в индексе", func(s domain.IndexStats) float64 { return float64(s.Chunks) })
+	gauge("rag_index_size_bytes", "Размер базы данных индекса в байтах", func(s domain.IndexStats) float64 { return float64(s.Bytes) })
+}
@@ -0,0 +1,368 @@
+// Package metrics реестр метрик с выводом в текстовом формате Prometheus без внешних зависимостей
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets границы гистограмм длительности в секундах по умолчанию
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// ContentType тип содержимого текстового формата Prometheus
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry набор метрик. Метрики выводятся в порядке регистрации, значения внутри метрики - по меткам.
+type Registry struct {
+	mu         sync.Mutex
+	metrics    []metric
+	names      map[string]bool
+	collectors []func()
+}
+
+// metric метрика реестра
+type metric interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// NewRegistry создает пустой реестр
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register добавляет метрику; повторная регистрация имени - ошибка программы
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[m.name()] {
+		panic(fmt.Sprintf("метрика %s уже зарегистрирована", m.name()))
+	}
+	r.names[m.name()] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Counter регистрирует счетчик с метками labels
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// Gauge регистрирует измеритель с метками labels
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{family: newFamily(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// GaugeFunc регистрирует измеритель, значение которого вычисляется fn при каждом выводе.
+// Если fn возвращает ошибку, значение не выводится.
+func (r *Registry) GaugeFunc(name, help string, fn func() (float64, error)) {
+	r.register(&gaugeFunc{family: newFamily(name, help, "gauge", nil), fn: fn})
+}
+
+// OnCollect регистрирует функцию, которая вызывается один раз перед каждым выводом метрик, например чтобы
+// несколько вычисляемых измерителей использовали одно чтение данных
+func (r *Registry) OnCollect(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, fn)
+}
+
+// Histogram регистрирует гистограмму с верхними границами корзин buckets (nil - DefaultBuckets)
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &Histogram{family: newFamily(name, help, "histogram", labels), buckets: sorted}
+	r.register(h)
+	return h
+}
+
+// WriteText выводит все метрики в текстовом формате Prometheus
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	collectors := append([]func(){}, r.collectors...)
+	r.mu.Unlock()
+
+	for _, collect := range collectors {
+		collect()
+	}
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler возвращает обработчик HTTP, отдающий метрики
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteText(w)
+	})
+}
+
+// family общая часть метрик: имя, описание, тип и значения по наборам меток
+type family struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series значения метрики для одного набора меток
+type series struct {
+	labelValues []string
+	value       float64  // Счетчик и измеритель
+	counts      []uint64 // Гистограмма: количество наблюдений в каждой корзине (не накопительное)
+	sum         float64  // Гистограмма: сумма наблюдений
+	count       uint64   // Гистограмма: количество наблюдений
+}
+
+// newFamily создает метрику; метрика без меток выводится со значением 0 еще до первого наблюдения
+func newFamily(name, help, kind string, labels []string) *family {
+	f := &family{metricName: name, help: help, kind: kind, labels: labels, series: make(map[string]*series)}
+	if len(labels) == 0 {
+		f.get(nil)
+	}
+	return f
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+// get возвращает значения для набора меток, создавая их при первом обращении. Вызывается под f.mu.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("метрика %s: ожидается %d меток, передано %d", f.metricName, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// lookup возвращает значения для набора меток без создания; nil, если наблюдений не было. Вызывается под f.mu.
+func (f *family) lookup(labelValues []string) *series {
+	return f.series[strings.Join(labelValues, "\xff")]
+}
+
+// sorted возвращает значения, упорядоченные по меткам. Вызывается под f.mu.
+func (f *family) sorted() []*series {
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*series, len(keys))
+	for i, k := range keys {
+		out[i] = f.series[k]
+	}
+	return out
+}
+
+// writeHeader выводит строки HELP и TYPE
+func (f *family) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, f.kind)
+}
+
+// writeSample выводит одно значение; extra - дополнительная метка (le для гистограммы)
+func (f *family) writeSample(w *bufio.Writer, suffix string, labelValues []string, extraName, extraValue string, value float64) {
+	w.WriteString(f.metricName + suffix)
+	if len(labelValues) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range f.labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, escapeLabel(labelValues[i]))
+		}
+		if extraName != "" {
+			if len(labelValues) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+// Counter монотонно растущий счетчик
+type Counter struct {
+	*family
+}
+
+// Inc увеличивает счетчик на 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add увеличивает счетчик на v (v >= 0)
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("счетчик %s не может уменьшаться", c.metricName))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(labelValues).value += v
+}
+
+// Value возвращает текущее значение счетчика
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s := c.lookup(labelValues); s != nil {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w)
+	for _, s := range c.sorted() {
+		c.writeSample(w, "", s.labelValues, "", "", s.value)
+	}
+}
+
+// Gauge значение, которое может расти и уменьшаться
+type Gauge struct {
+	*family
+}
+
+// Set устанавливает значение
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labelValues).value = v
+}
+
+// Add изменяет значение на v
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labelValues).value += v
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(w)
+	for _, s := range g.sorted() {
+		g.writeSample(w, "", s.labelValues, "", "", s.value)
+	}
+}
+
+// gaugeFunc измеритель, вычисляемый при выводе
+type gaugeFunc struct {
+	*family
+	fn func() (float64, error)
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	value, err := g.fn()
+	if err != nil {
+		return
+	}
+	g.writeHeader(w)
+	g.writeSample(w, "", nil, "", "", value)
+}
+
+// Histogram распределение наблюдений по корзинам
+type Histogram struct {
+	*family
+	buckets []float64
+}
+
+// Observe добавляет наблюдение v
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labelValues)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Count возвращает количество наблюдений
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s := h.lookup(labelValues); s != nil {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, s := range h.sorted() {
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			if s.counts != nil {
+				cumulative += s.counts[i]
+			}
+			h.writeSample(w, "_bucket", s.labelValues, "le", formatFloat(upper), float64(cumulative))
+		}
+		h.writeSample(w, "_bucket", s.labelValues, "le", "+Inf", float64(s.count))
+		h.writeSample(w, "_sum", s.labelValues, "", "", s.sum)
+		h.writeSample(w, "_count", s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// formatFloat форматирует число так, как его ожидает Prometheus
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabel экранирует значение метки
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// escapeHelp экранирует описание метрики
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,234 @@
+// Package migrations версионные миграции схемы SQLite.
+//
+// Миграции хранятся в каталоге sql как файлы NNNN_name.sql и встраиваются в бинарный файл.
+// Каждая миграция выполняется в отдельной транзакции вместе с записью в таблицу schema_migrations,
+// поэтому прерванная миграция не оставляет схему в промежуточном состоянии. Транзакция начинается
+// с BEGIN IMMEDIATE, поэтому процессы, одновременно обновляющие одну базу, применяют миграцию по очереди.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Migration одна миграция схемы
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	SQL     string `json:"-"`
+}
+
+// State миграция и сведения о ее применении
+type State struct {
+	Migration
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"applied_at"` // Нулевое, если миграция не применена
+}
+
+// Migrator применяет упорядоченный набор миграций
+type Migrator struct {
+	migrations []Migration
+}
+
+// Embedded возвращает миграции, встроенные в бинарный файл
+func Embedded() []Migration {
+	list, err := parse(files, "sql")
+	if err != nil {
+		// Встроенные файлы проверяются тестами, ошибка здесь - ошибка сборки
+		panic(err)
+	}
+	return list
+}
+
+// parse читает файлы NNNN_name.sql из каталога dir
+func parse(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать миграции: %w", err)
+	}
+	var list []Migration
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		number, title, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("некорректное имя файла миграции %q (ожидается NNNN_name.sql)", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать миграцию %s: %w", entry.Name(), err)
+		}
+		list = append(list, Migration{Version: version, Name: title, SQL: string(content)})
+	}
+	return list, nil
+}
+
+// NewMigrator создает мигратор; версии миграций должны быть положительными и уникальными
+func NewMigrator(list []Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), list...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("версия миграции %q должна быть положительной", m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("повторяющаяся версия миграции %d", m.Version)
+		}
+	}
+	return &Migrator{migrations: sorted}, nil
+}
+
+// ensureTable создает таблицу версий схемы
+func ensureTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("не удалось создать таблицу schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// Status возвращает все миграции с отметкой о применении. Если база содержит версию,
+// неизвестную программе (база обновлена более новой версией), возвращается ошибка. База не изменяется
+func (m *Migrator) Status(db *sql.DB) ([]State, error) {
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		at, ok := applied[migration.Version]
+		states = append(states, State{Migration: migration, Applied: ok, AppliedAt: at})
+		delete(applied, migration.Version)
+	}
+	for version := range applied {
+		return nil, fmt.Errorf("база данных содержит неизвестную миграцию %d: схема новее программы", version)
+	}
+	return states, nil
+}
+
+// appliedVersions возвращает примененные версии и время применения; без таблицы schema_migrations - пусто
+func appliedVersions(db *sql.DB) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&tables); err != nil {
+		return nil, fmt.Errorf("не удалось проверить таблицу schema_migrations: %w", err)
+	}
+	if tables == 0 {
+		return applied, nil
+	}
+
+	rows, err := db.Query("SELECT version, COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', applied_at), '') FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("не удалось прочитать schema_migrations: %w", err)
+		}
+		applied[version], _ = time.Parse(time.RFC3339, at)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать schema_migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// Pending возвращает непримененные миграции в порядке применения
+func (m *Migrator) Pending(db *sql.DB) ([]Migration, error) {
+	states, err := m.Status(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, state := range states {
+		if !state.Applied {
+			pending = append(pending, state.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up применяет непримененные миграции по порядку и возвращает примененные.
+// При ошибке миграция откатывается, а уже примененные до нее остаются
+func (m *Migrator) Up(db *sql.DB) ([]Migration, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+	pending, err := m.Pending(db)
+	if err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, migration := range pending {
+		ok, err := apply(db, migration)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// apply выполняет одну миграцию в транзакции и возвращает false, если миграцию уже применил другой процесс.
+// BEGIN IMMEDIATE сразу берет блокировку записи, а применение проверяется повторно внутри транзакции:
+// список непримененных миграций, прочитанный до нее, мог устареть
+func apply(db *sql.DB, migration Migration) (bool, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("не удалось получить соединение: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return false, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+
+	var exists int
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = ?", migration.Version).Scan(&exists); err != nil {
+		return false, fmt.Errorf("не удалось прочитать schema_migrations: %w", err)
+	}
+	if exists > 0 {
+		return false, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, migration.SQL); err != nil {
+		return false, fmt.Errorf("ошибка миграции %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name); err != nil {
+		return false, fmt.Errorf("не удалось записать миграцию %d: %w", migration.Version, err)
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return false, fmt.Errorf("не удалось зафиксировать миграцию %d: %w", migration.Version, err)
+	}
+	committed = true
+	return true, nil
+}
@@ -0,0 +1,17 @@
+-- Исходная схема; IF NOT EXISTS позволяет принять базы, созданные до появления миграций
+CREATE TABLE IF NOT EXISTS documents (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS chunks (
+	id TEXT PRIMARY KEY,
+	document_id TEXT NOT NULL,
+	content TEXT NOT NULL,
+	FOREIGN KEY(document_id) REFERENCES documents(id)
+);
+
+-- Индекс для быстрого поиска по содержимому (fallback если FTS5 недоступен)
+CREATE INDEX IF NOT EXISTS idx_chunks_content ON chunks(content);
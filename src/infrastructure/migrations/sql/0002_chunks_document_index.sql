@@ -0,0 +1,2 @@
+-- Выборка, подсчет и удаление фрагментов документа без полного просмотра таблицы
+CREATE INDEX IF NOT EXISTS idx_chunks_document_id ON chunks(document_id);
@@ -1,35 +1,92 @@
 package infrastructure
 
 import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"rag-system/src/domain"
+	"rag-system/src/infrastructure/metrics"
+	"rag-system/src/infrastructure/migrations"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// defaultChunkSize размер фрагмента по умолчанию в байтах
+const defaultChunkSize = 500
+
+// defaultBatchSize документов в транзакции пакетной индексации по умолчанию
+const defaultBatchSize = 100
+
 // SQLiteDocumentRepository реализация репозитория с использованием SQLite
 type SQLiteDocumentRepository struct {
-	db          *sqlx.DB
-	fts5Enabled bool // Флаг поддержки FTS5
+	db          *sqlx.DB // Пул записи из одного соединения
+	reader      *sqlx.DB // Пул чтения
+	fts5Enabled bool     // Флаг поддержки FTS5
+	chunkSize   int
+	batchSize   int
+	busyRetries int
+	logger      *slog.Logger
+	metrics     *metrics.RAG
+}
+
+// RepositoryOptions настройки репозитория (секции storage и chunking конфигурации)
+type RepositoryOptions struct {
+	ChunkSize int           // Максимальный размер фрагмента в байтах (0 - по умолчанию 500)
+	BatchSize int           // Документов в транзакции пакетной индексации (0 - по умолчанию 100)
+	SQLite    SQLiteOptions // Режим журнала, таймауты и пулы соединений
+	Logger    *slog.Logger  // Журнал репозитория (nil - slog.Default())
+	Metrics   *metrics.RAG  // Счетчики проиндексированных документов и фрагментов (nil - без метрик)
 }
 
-// NewSQLiteDocumentRepository создает новый экземпляр репозитория
+// NewSQLiteDocumentRepository создает новый экземпляр репозитория с настройками по умолчанию
 func NewSQLiteDocumentRepository(dbPath string) (*SQLiteDocumentRepository, error) {
-	db, err := sqlx.Connect("sqlite3", dbPath)
+	return NewSQLiteDocumentRepositoryWithOptions(dbPath, RepositoryOptions{})
+}
+
+// NewSQLiteDocumentRepositoryWithOptions создает новый экземпляр репозитория
+func NewSQLiteDocumentRepositoryWithOptions(dbPath string, opts RepositoryOptions) (*SQLiteDocumentRepository, error) {
+	if opts.ChunkSize < 0 {
+		return nil, fmt.Errorf("размер фрагмента не может быть отрицательным: %d", opts.ChunkSize)
+	}
+	if opts.ChunkSize == 0 {
+		opts.ChunkSize = defaultChunkSize
+	}
+	if opts.BatchSize < 0 {
+		return nil, fmt.Errorf("размер пакета не может быть отрицательным: %d", opts.BatchSize)
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	opts.SQLite = opts.SQLite.withDefaults()
+	if err := opts.SQLite.validate(); err != nil {
+		return nil, err
+	}
+
+	db, reader, err := openPools(dbPath, opts.SQLite)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось подключиться к базе данных: %w", err)
 	}
 
-	repo := &SQLiteDocumentRepository{db: db, fts5Enabled: false}
+	repo := &SQLiteDocumentRepository{db: db, reader: reader, fts5Enabled: false, chunkSize: opts.ChunkSize,
+		batchSize: opts.BatchSize, busyRetries: opts.SQLite.BusyRetries, logger: opts.Logger.With("component", "storage"), metrics: opts.Metrics}
 
 	// Проверяем поддержку FTS5
 	repo.fts5Enabled = repo.checkFTS5Support()
 
 	err = repo.initSchema()
 	if err != nil {
+		repo.Close()
 		return nil, fmt.Errorf("не удалось инициализировать схему: %w", err)
 	}
 
@@ -41,152 +98,275 @@ func (r *SQLiteDocumentRepository) checkFTS5Support() bool {
 	var result string
 	err := r.db.Get(&result, "SELECT 'fts5' WHERE 'fts5' IN (SELECT name FROM pragma_module_list())")
 	if err != nil {
-		log.Printf("FTS5 не поддерживается в данной версии SQLite, используется fallback на LIKE поиск")
+		r.logger.Warn("FTS5 не поддерживается в данной версии SQLite, используется fallback на LIKE поиск")
 		return false
 	}
 	return result == "fts5"
 }
 
-// initSchema инициализирует схему базы данных
+// initSchema применяет миграции схемы и создает FTS5 индекс, если он поддерживается
 func (r *SQLiteDocumentRepository) initSchema() error {
-	tables := []string{
-		`CREATE TABLE IF NOT EXISTS documents (
-			id TEXT PRIMARY KEY,
-			title TEXT NOT NULL,
-			content TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS chunks (
-			id TEXT PRIMARY KEY,
-			document_id TEXT NOT NULL,
-			content TEXT NOT NULL,
-			FOREIGN KEY(document_id) REFERENCES documents(id)
-		)`,
-
-		// Индекс для быстрого поиска по содержимому (fallback если FTS5 недоступен)
-		`CREATE INDEX IF NOT EXISTS idx_chunks_content ON chunks(content)`,
-	}
-
-	// Добавляем FTS5 таблицу и триггеры только если FTS5 поддерживается
+	migrator, err := migrations.NewMigrator(migrations.Embedded())
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(r.db.DB)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции схемы: %w", err)
+	}
+	for _, m := range applied {
+		r.logger.Info("Применена миграция схемы", "version", m.Version, "name", m.Name)
+	}
+
+	// FTS5 таблица и триггеры зависят от сборки SQLite, поэтому создаются вне версионных миграций
 	if r.fts5Enabled {
-		fts5Tables := []string{
-			// FTS5 виртуальная таблица для полнотекстового поиска
-			`CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
-				content,
-				content='chunks',
-				content_rowid='rowid'
-			)`,
+		if err := r.ensureFTSIndex(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			// Триггеры для автоматической синхронизации данных между chunks и chunks_fts
-			`CREATE TRIGGER IF NOT EXISTS chunks_fts_insert AFTER INSERT ON chunks BEGIN
-				INSERT INTO chunks_fts(rowid, content) VALUES (new.rowid, new.content);
-			END`,
+// ftsTriggers триггеры синхронизации chunks_fts с таблицей chunks.
+// chunks_fts - таблица с внешним содержимым (content='chunks'): при удалении и изменении
+// старый текст нужно передать командой 'delete', иначе его термы остаются в индексе
+var ftsTriggers = []struct{ name, sql string }{
+	{"chunks_fts_insert", `CREATE TRIGGER chunks_fts_insert AFTER INSERT ON chunks BEGIN
+	INSERT INTO chunks_fts(rowid, content) VALUES (new.rowid, new.content);
+END`},
+	{"chunks_fts_delete", `CREATE TRIGGER chunks_fts_delete AFTER DELETE ON chunks BEGIN
+	INSERT INTO chunks_fts(chunks_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+END`},
+	{"chunks_fts_update", `CREATE TRIGGER chunks_fts_update AFTER UPDATE OF content ON chunks BEGIN
+	INSERT INTO chunks_fts(chunks_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+	INSERT INTO chunks_fts(rowid, content) VALUES (new.rowid, new.content);
+END`},
+}
 
-			`CREATE TRIGGER IF NOT EXISTS chunks_fts_update AFTER UPDATE OF content ON chunks BEGIN
-				UPDATE chunks_fts SET content = new.content WHERE rowid = new.rowid;
-			END`,
+// ensureFTSIndex создает FTS5 таблицу и триггеры. Если таблица создается впервые или триггеры
+// отличаются от ftsTriggers (база создана прежней версией), индекс перестраивается целиком
+func (r *SQLiteDocumentRepository) ensureFTSIndex() error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
 
-			`CREATE TRIGGER IF NOT EXISTS chunks_fts_delete AFTER DELETE ON chunks BEGIN
-				DELETE FROM chunks_fts WHERE rowid = old.rowid;
-			END`,
-		}
-		tables = append(tables, fts5Tables...)
+	var existing int
+	if err := tx.Get(&existing, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'chunks_fts'"); err != nil {
+		return fmt.Errorf("ошибка проверки FTS5 таблицы: %w", err)
+	}
+	rebuild := existing == 0
+	_, err = tx.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
+		content,
+		content='chunks',
+		content_rowid='rowid'
+	)`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания FTS5 таблицы: %w", err)
 	}
 
-	for _, tableSQL := range tables {
-		_, err := r.db.Exec(tableSQL)
-		if err != nil {
-			log.Printf("Ошибка выполнения SQL: %s, ошибка: %v", tableSQL, err)
-			return fmt.Errorf("ошибка при создании таблицы: %w", err)
+	for _, trigger := range ftsTriggers {
+		var current string
+		err := tx.Get(&current, "SELECT sql FROM sqlite_master WHERE type = 'trigger' AND name = ?", trigger.name)
+		if err == nil && current == trigger.sql {
+			continue
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("ошибка проверки триггера %s: %w", trigger.name, err)
 		}
+		if _, err := tx.Exec("DROP TRIGGER IF EXISTS " + trigger.name); err != nil {
+			return fmt.Errorf("ошибка удаления триггера %s: %w", trigger.name, err)
+		}
+		if _, err := tx.Exec(trigger.sql); err != nil {
+			return fmt.Errorf("ошибка создания триггера %s: %w", trigger.name, err)
+		}
+		rebuild = true
 	}
 
-	// Миграция существующих данных в FTS5 индекс (только если FTS5 поддерживается)
-	if r.fts5Enabled {
-		err := r.rebuildFTSIndex()
-		if err != nil {
-			log.Printf("Предупреждение: не удалось переиндексировать FTS5: %v", err)
-			// Не возвращаем ошибку, так как это может быть первичная инициализация
+	if rebuild {
+		if _, err := tx.Exec("INSERT INTO chunks_fts(chunks_fts) VALUES ('rebuild')"); err != nil {
+			return fmt.Errorf("ошибка переиндексации FTS5: %w", err)
 		}
 	}
-
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	if rebuild {
+		r.logger.Info("FTS5 индекс перестроен")
+	}
 	return nil
 }
 
-// rebuildFTSIndex переиндексирует существующие данные в FTS5 таблицу
-func (r *SQLiteDocumentRepository) rebuildFTSIndex() error {
-	// Проверяем, есть ли уже данные в FTS5
-	var count int
-	err := r.db.Get(&count, "SELECT COUNT(*) FROM chunks_fts")
-	if err == nil && count > 0 {
-		// Проверяем, все ли данные синхронизированы
-		var chunksCount int
-		err = r.db.Get(&chunksCount, "SELECT COUNT(*) FROM chunks")
-		if err == nil && count == chunksCount {
-			// Индекс уже заполнен и синхронизирован
-			return nil
-		}
-	}
-
-	// Заполняем FTS5 индекс из существующих данных
-	// Используем INSERT OR IGNORE для избежания дубликатов
-	_, err = r.db.Exec(`
-		INSERT INTO chunks_fts(rowid, content) 
-		SELECT rowid, content FROM chunks
-		WHERE rowid NOT IN (SELECT rowid FROM chunks_fts)
-	`)
+// SaveDocument сохраняет документ в базе данных
+func (r *SQLiteDocumentRepository) SaveDocument(doc domain.Document) error {
+	return r.retryBusy(func() error { return r.saveDocument(doc) })
+}
+
+func (r *SQLiteDocumentRepository) saveDocument(doc domain.Document) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Вставка документа выполняется до подготовки запросов: подготовка читает схему и берет блокировку
+	// на чтение, с которой параллельные транзакции записи взаимно блокируются (database is locked)
+	if _, err := tx.Exec(insertDocumentSQL, doc.ID, doc.Title, doc.Content, formatTimestamp(doc.CreatedAt)); err != nil {
+		return fmt.Errorf("не удалось вставить документ: %w", err)
+	}
+	stmt, err := tx.Prepare(insertChunkSQL)
+	if err != nil {
+		return fmt.Errorf("не удалось подготовить SQL для фрагмента: %w", err)
+	}
+	defer stmt.Close()
+
+	chunks, err := insertChunks(stmt, doc.ID, splitIntoChunks(doc.Content, r.chunkSize))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("ошибка переиндексации FTS5: %w", err)
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
 	}
 
-	log.Printf("FTS5 индекс успешно переиндексирован")
+	r.metrics.DocumentIndexed(chunks)
 	return nil
 }
 
-// SaveDocument сохраняет документ в базе данных
-func (r *SQLiteDocumentRepository) SaveDocument(doc domain.Document) error {
+// UpdateDocument заменяет название и содержимое документа и заново разбивает его на фрагменты.
+// Время создания сохраняется; если документа нет, ошибка оборачивает domain.ErrDocumentNotFound.
+func (r *SQLiteDocumentRepository) UpdateDocument(doc domain.Document) error {
+	return r.retryBusy(func() error { return r.updateDocument(doc) })
+}
+
+func (r *SQLiteDocumentRepository) updateDocument(doc domain.Document) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return fmt.Errorf("не удалось начать транзакцию: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Сохраняем документ
-	stmt, err := tx.Prepare(`INSERT INTO documents (id, title, content) VALUES (?, ?, ?)`)
+	result, err := tx.Exec("UPDATE documents SET title = ?, content = ? WHERE id = ?", doc.Title, doc.Content, doc.ID)
 	if err != nil {
-		return fmt.Errorf("не удалось подготовить SQL для документа: %w", err)
+		return fmt.Errorf("не удалось обновить документ: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("не удалось обновить документ: %w", err)
+	} else if updated == 0 {
+		return fmt.Errorf("%w: %s", domain.ErrDocumentNotFound, doc.ID)
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(doc.ID, doc.Title, doc.Content)
+	if _, err := tx.Exec("DELETE FROM chunks WHERE document_id = ?", doc.ID); err != nil {
+		return fmt.Errorf("ошибка удаления фрагментов: %w", err)
+	}
+	stmt, err := tx.Prepare(insertChunkSQL)
 	if err != nil {
-		return fmt.Errorf("не удалось вставить документ: %w", err)
+		return fmt.Errorf("не удалось подготовить SQL для фрагмента: %w", err)
 	}
+	defer stmt.Close()
 
-	// Разбиваем документ на фрагменты (в реальном приложении использовать токенизацию)
-	chunks := splitIntoChunks(doc.Content, 500) // Разбиваем на фрагменты по 500 символов
+	if _, err := insertChunks(stmt, doc.ID, splitIntoChunks(doc.Content, r.chunkSize)); err != nil {
+		return err
+	}
 
-	for i, chunkText := range chunks {
-		chunkID := fmt.Sprintf("%s_chunk_%d", doc.ID, i)
-		chunkStmt, err := tx.Prepare(`INSERT INTO chunks (id, document_id, content) VALUES (?, ?, ?)`)
-		if err != nil {
-			return fmt.Errorf("не удалось подготовить SQL для фрагмента: %w", err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+
+	r.metrics.DocumentUpdated()
+	return nil
+}
+
+// PreparedDocument документ, уже разбитый на фрагменты
+type PreparedDocument struct {
+	domain.Document
+	Chunks []string
+}
+
+// PrepareDocument разбивает документ на фрагменты размером chunking.size. Не обращается к базе,
+// поэтому может вызываться параллельно, например воркерами конвейера загрузки
+func (r *SQLiteDocumentRepository) PrepareDocument(doc domain.Document) PreparedDocument {
+	// В реальном приложении использовать токенизацию
+	return PreparedDocument{Document: doc, Chunks: splitIntoChunks(doc.Content, r.chunkSize)}
+}
+
+// Запросы вставки; без CreatedAt время создания задает база данных
+const (
+	insertDocumentSQL = `INSERT INTO documents (id, title, content, created_at) VALUES (?1, ?2, ?3, COALESCE(?4, CURRENT_TIMESTAMP))`
+	insertChunkSQL    = `INSERT INTO chunks (id, document_id, content) VALUES (?, ?, ?)`
+)
+
+// insertStatements подготовленные запросы вставки документа и его фрагментов
+type insertStatements struct {
+	document     *sql.Stmt
+	chunk        *sql.Stmt
+	deleteChunks *sql.Stmt // Только в режиме замены
+}
+
+// preparer *sql.DB или *sql.Tx
+type preparer interface {
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// prepareInsertStatements подготавливает запросы вставки. В режиме замены существующий документ
+// перезаписывается с сохранением времени создания
+func prepareInsertStatements(p preparer, replace bool) (*insertStatements, error) {
+	query := insertDocumentSQL
+	if replace {
+		query += ` ON CONFLICT(id) DO UPDATE SET title = excluded.title, content = excluded.content,
+			created_at = COALESCE(?4, documents.created_at)`
+	}
+	stmts := &insertStatements{}
+	var err error
+	if stmts.document, err = p.Prepare(query); err != nil {
+		return nil, fmt.Errorf("не удалось подготовить SQL для документа: %w", err)
+	}
+	if stmts.chunk, err = p.Prepare(insertChunkSQL); err != nil {
+		stmts.Close()
+		return nil, fmt.Errorf("не удалось подготовить SQL для фрагмента: %w", err)
+	}
+	if replace {
+		if stmts.deleteChunks, err = p.Prepare(`DELETE FROM chunks WHERE document_id = ?`); err != nil {
+			stmts.Close()
+			return nil, fmt.Errorf("не удалось подготовить SQL для удаления фрагментов: %w", err)
 		}
-		defer chunkStmt.Close()
+	}
+	return stmts, nil
+}
 
-		_, err = chunkStmt.Exec(chunkID, doc.ID, chunkText)
-		if err != nil {
-			return fmt.Errorf("не удалось вставить фрагмент: %w", err)
+// Close освобождает подготовленные запросы
+func (s *insertStatements) Close() {
+	for _, stmt := range []*sql.Stmt{s.document, s.chunk, s.deleteChunks} {
+		if stmt != nil {
+			stmt.Close()
 		}
 	}
+}
 
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+// insertDocument сохраняет документ и его фрагменты; возвращает количество фрагментов
+func (r *SQLiteDocumentRepository) insertDocument(stmts *insertStatements, doc PreparedDocument) (int, error) {
+	if _, err := stmts.document.Exec(doc.ID, doc.Title, doc.Content, formatTimestamp(doc.CreatedAt)); err != nil {
+		return 0, fmt.Errorf("не удалось вставить документ: %w", err)
+	}
+	if stmts.deleteChunks != nil {
+		if _, err := stmts.deleteChunks.Exec(doc.ID); err != nil {
+			return 0, fmt.Errorf("ошибка удаления фрагментов: %w", err)
+		}
 	}
+	return insertChunks(stmts.chunk, doc.ID, doc.Chunks)
+}
 
-	return nil
+// insertChunks сохраняет фрагменты документа; возвращает их количество
+func insertChunks(stmt *sql.Stmt, docID string, chunks []string) (int, error) {
+	for i, chunkText := range chunks {
+		chunkID := fmt.Sprintf("%s_chunk_%d", docID, i)
+		if _, err := stmt.Exec(chunkID, docID, chunkText); err != nil {
+			return 0, fmt.Errorf("не удалось вставить фрагмент: %w", err)
+		}
+	}
+	return len(chunks), nil
 }
 
 // splitIntoChunks разбивает текст на фрагменты заданного размера
@@ -272,7 +452,7 @@ func (r *SQLiteDocumentRepository) findRelevantChunksFTS5(query string, limit in
 
 	// Обработка пустого запроса
 	if strings.TrimSpace(query) == "" {
-		rows, err := r.db.Queryx(`
+		rows, err := r.reader.Queryx(`
 			SELECT id, document_id, content 
 			FROM chunks 
 			LIMIT ?`, limit)
@@ -314,7 +494,7 @@ func (r *SQLiteDocumentRepository) findRelevantChunksFTS5(query string, limit in
 		ORDER BY rank_score
 		LIMIT ?`
 
-	rows, err := r.db.Queryx(querySQL, ftsQuery, limit)
+	rows, err := r.reader.Queryx(querySQL, ftsQuery, limit)
 	if err != nil {
 		// Если FTS5 таблица не существует или произошла ошибка, возвращаем ошибку
 		return nil, fmt.Errorf("ошибка выполнения FTS5 запроса: %w", err)
@@ -375,10 +555,10 @@ func (r *SQLiteDocumentRepository) findRelevantChunksLike(query string, limit in
 
 	if len(queryWords) == 0 {
 		// Если нет слов в запросе, возвращаем все фрагменты
-		rows, err = r.db.Queryx("SELECT id, document_id, content FROM chunks LIMIT ?", limit)
+		rows, err = r.reader.Queryx("SELECT id, document_id, content FROM chunks LIMIT ?", limit)
 	} else if len(queryWords) == 1 {
 		// Если одно слово, используем простой LIKE
-		rows, err = r.db.Queryx(
+		rows, err = r.reader.Queryx(
 			"SELECT id, document_id, content FROM chunks WHERE content LIKE ? LIMIT ?",
 			"%"+queryWords[0]+"%", limit,
 		)
@@ -398,7 +578,7 @@ func (r *SQLiteDocumentRepository) findRelevantChunksLike(query string, limit in
 		// Добавляем лимит к параметрам
 		params = append(params, limit)
 
-		rows, err = r.db.Queryx(queryStr, params...)
+		rows, err = r.reader.Queryx(queryStr, params...)
 	}
 
 	if err != nil {
@@ -445,7 +625,7 @@ func calculateSimpleSimilarity(query, content string) float64 {
 
 // GetAllDocuments возвращает все документы
 func (r *SQLiteDocumentRepository) GetAllDocuments() ([]domain.Document, error) {
-	rows, err := r.db.Query("SELECT id, title, content, created_at FROM documents")
+	rows, err := r.reader.Query("SELECT id, title, content, " + createdColumn + " FROM documents")
 	if err != nil {
 		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
 	}
@@ -459,15 +639,348 @@ func (r *SQLiteDocumentRepository) GetAllDocuments() ([]domain.Document, error)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
 		}
+		doc.CreatedAt = parseTimestamp(createdAtStr)
 
 		docs = append(docs, doc)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения строк: %w", err)
+	}
 
 	return docs, nil
 }
 
+// Stats возвращает количество документов и фрагментов и размер базы данных
+func (r *SQLiteDocumentRepository) Stats() (domain.IndexStats, error) {
+	var stats domain.IndexStats
+	if err := r.reader.Get(&stats.Documents, "SELECT COUNT(*) FROM documents"); err != nil {
+		return stats, fmt.Errorf("ошибка подсчета документов: %w", err)
+	}
+	if err := r.reader.Get(&stats.Chunks, "SELECT COUNT(*) FROM chunks"); err != nil {
+		return stats, fmt.Errorf("ошибка подсчета фрагментов: %w", err)
+	}
+	err := r.reader.Get(&stats.Bytes, "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()")
+	if err != nil {
+		return stats, fmt.Errorf("ошибка определения размера базы данных: %w", err)
+	}
+	err = r.reader.Get(&stats.AvgChunkSize, "SELECT COALESCE(AVG(length(CAST(content AS BLOB))), 0) FROM chunks")
+	if err != nil {
+		return stats, fmt.Errorf("ошибка определения размера фрагментов: %w", err)
+	}
+	stats.FTS5 = r.fts5Enabled
+	return stats, nil
+}
+
+// CheckIndex проверяет согласованность FTS5 индекса с таблицей chunks: выполняет integrity-check
+// и сравнивает rowid фрагментов с rowid, присутствующими в индексе. Без FTS5 проверять нечего
+func (r *SQLiteDocumentRepository) CheckIndex() (domain.IndexCheck, error) {
+	check := domain.IndexCheck{FTS5: r.fts5Enabled, Missing: []int64{}, Stale: []int64{}}
+	if err := r.db.Get(&check.Chunks, "SELECT COUNT(*) FROM chunks"); err != nil {
+		return check, fmt.Errorf("ошибка подсчета фрагментов: %w", err)
+	}
+	if !r.fts5Enabled {
+		check.OK = true
+		return check, nil
+	}
+
+	// rank = 1 сравнивает индекс с содержимым таблицы chunks, а не только внутреннюю структуру
+	if _, err := r.db.Exec("INSERT INTO chunks_fts(chunks_fts, rank) VALUES ('integrity-check', 1)"); err != nil {
+		check.Integrity = err.Error()
+	}
+
+	// rowid из самого индекса доступны только через fts5vocab: запрос к chunks_fts читает таблицу chunks
+	ctx := context.Background()
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		return check, fmt.Errorf("не удалось получить соединение: %w", err)
+	}
+	defer conn.Close()
+	// Фрагменты без индексируемых слов (только знаки препинания и символы) законно отсутствуют в индексе,
+	// поэтому фрагменты, которых нет в индексе, разбиваются на слова тем же токенизатором во временной таблице
+	setup := []string{
+		"CREATE VIRTUAL TABLE IF NOT EXISTS temp.chunks_fts_vocab USING fts5vocab(main, chunks_fts, instance)",
+		"CREATE VIRTUAL TABLE IF NOT EXISTS temp.chunks_fts_probe USING fts5(content)",
+		"CREATE VIRTUAL TABLE IF NOT EXISTS temp.chunks_fts_probe_vocab USING fts5vocab(temp, chunks_fts_probe, instance)",
+	}
+	defer func() {
+		for _, table := range []string{"chunks_fts_probe_vocab", "chunks_fts_probe", "chunks_fts_vocab"} {
+			conn.ExecContext(ctx, "DROP TABLE IF EXISTS temp."+table)
+		}
+	}()
+	for _, statement := range setup {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return check, fmt.Errorf("ошибка создания временной таблицы проверки: %w", err)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO temp.chunks_fts_probe(rowid, content)
+		SELECT rowid, content FROM chunks WHERE rowid NOT IN (SELECT doc FROM temp.chunks_fts_vocab)`); err != nil {
+		return check, fmt.Errorf("ошибка разбора фрагментов на слова: %w", err)
+	}
+
+	queries := []struct {
+		dest  *[]int64
+		query string
+	}{
+		// Фрагменты со словами, которых нет в индексе
+		{&check.Missing, `SELECT DISTINCT doc FROM temp.chunks_fts_probe_vocab ORDER BY doc`},
+		// Записи индекса, оставшиеся от удаленных фрагментов
+		{&check.Stale, `SELECT DISTINCT doc FROM temp.chunks_fts_vocab
+			WHERE doc NOT IN (SELECT rowid FROM chunks) ORDER BY doc`},
+	}
+	for _, q := range queries {
+		if err := conn.SelectContext(ctx, q.dest, q.query); err != nil {
+			return check, fmt.Errorf("ошибка сверки rowid индекса: %w", err)
+		}
+	}
+	if err := conn.GetContext(ctx, &check.Indexed, "SELECT COUNT(DISTINCT doc) FROM temp.chunks_fts_vocab"); err != nil {
+		return check, fmt.Errorf("ошибка подсчета записей индекса: %w", err)
+	}
+
+	check.OK = check.Integrity == "" && len(check.Missing) == 0 && len(check.Stale) == 0
+	return check, nil
+}
+
+// RebuildIndex перестраивает FTS5 индекс целиком по таблице chunks
+func (r *SQLiteDocumentRepository) RebuildIndex() error {
+	if !r.fts5Enabled {
+		return nil
+	}
+	if _, err := r.db.Exec("INSERT INTO chunks_fts(chunks_fts) VALUES ('rebuild')"); err != nil {
+		return fmt.Errorf("ошибка перестроения FTS5 индекса: %w", err)
+	}
+	r.logger.Info("FTS5 индекс перестроен")
+	return nil
+}
+
+// defaultPageSize размер страницы списка документов по умолчанию
+const defaultPageSize = 20
+
+// timestampLayout формат created_at в запросах: createdColumn приводит к нему любое сохраненное представление времени
+const timestampLayout = "2006-01-02T15:04:05.000Z"
+
+// createdColumn выражение created_at в формате timestampLayout (UTC)
+const createdColumn = "COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', created_at), '')"
+
+// parseTimestamp разбирает created_at, прочитанный через createdColumn
+func parseTimestamp(s string) time.Time {
+	t, err := time.Parse(timestampLayout, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// formatTimestamp возвращает время для записи в created_at в формате CURRENT_TIMESTAMP с миллисекундами;
+// нулевое время - nil
+func formatTimestamp(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format("2006-01-02 15:04:05.000")
+}
+
+// sortColumns выражения сортировки списка документов по полям domain.SortBy*
+var sortColumns = map[string]string{
+	domain.SortByID:      "id",
+	domain.SortByTitle:   "title",
+	domain.SortByCreated: "created",
+	domain.SortBySize:    "size",
+	domain.SortByChunks:  "chunks",
+}
+
+// listCursor позиция в списке документов: поле и значение сортировки и ID последнего документа страницы
+type listCursor struct {
+	Sort string      `json:"s"`
+	Key  interface{} `json:"k"`
+	ID   string      `json:"id"`
+}
+
+// encodeCursor возвращает непрозрачный курсор для передачи клиенту
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor разбирает курсор, полученный от клиента
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.ID == "" {
+		return c, fmt.Errorf("некорректный курсор %q", s)
+	}
+	return c, nil
+}
+
+// ListDocuments возвращает страницу сведений о документах без содержимого, подходящих под фильтр.
+// Страницы выбираются по курсору (значение поля сортировки и ID), поэтому добавление и удаление документов
+// не сдвигает следующие страницы. Пустой курсор - первая страница, limit <= 0 - 20 документов.
+func (r *SQLiteDocumentRepository) ListDocuments(filter domain.DocumentFilter, cursor string, limit int) (domain.DocumentPage, error) {
+	var page domain.DocumentPage
+	if filter.Sort == "" {
+		filter.Sort = domain.SortByID
+	}
+	column, ok := sortColumns[filter.Sort]
+	if !ok {
+		return page, fmt.Errorf("неизвестное поле сортировки %q (допустимо: id, title, created, size, chunks)", filter.Sort)
+	}
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	// Условия фильтра
+	var conditions []string
+	var args []interface{}
+	if filter.IDPrefix != "" {
+		conditions = append(conditions, "substr(id, 1, length(?)) = ?")
+		args = append(args, filter.IDPrefix, filter.IDPrefix)
+	}
+	if filter.Title != "" {
+		conditions = append(conditions, "instr(title, ?) > 0")
+		args = append(args, filter.Title)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created >= ?")
+		args = append(args, filter.CreatedAfter.UTC().Format(timestampLayout))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created < ?")
+		args = append(args, filter.CreatedBefore.UTC().Format(timestampLayout))
+	}
+
+	from := `FROM (
+		SELECT d.id, d.title, ` + createdColumn + ` AS created,
+			length(CAST(d.content AS BLOB)) AS size,
+			(SELECT COUNT(*) FROM chunks c WHERE c.document_id = d.id) AS chunks
+		FROM documents d)`
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if err := r.reader.Get(&page.Total, "SELECT COUNT(*) "+from+where, args...); err != nil {
+		return page, fmt.Errorf("ошибка подсчета документов: %w", err)
+	}
+
+	order, compare := "ASC", ">"
+	if filter.Desc {
+		order, compare = "DESC", "<"
+	}
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return page, err
+		}
+		if c.Sort != filter.Sort {
+			return page, fmt.Errorf("курсор получен для сортировки %q, а не %q", c.Sort, filter.Sort)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (?, ?)", column, compare))
+		args = append(args, c.Key, c.ID)
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query := "SELECT id, title, created, size, chunks " + from + where +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ?", column, order, order)
+	args = append(args, limit+1)
+
+	rows, err := r.reader.Query(query, args...)
+	if err != nil {
+		return page, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var created []string
+	page.Documents = []domain.DocumentSummary{}
+	for rows.Next() {
+		var doc domain.DocumentSummary
+		var createdStr string
+		if err := rows.Scan(&doc.ID, &doc.Title, &createdStr, &doc.Size, &doc.Chunks); err != nil {
+			return page, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		doc.CreatedAt = parseTimestamp(createdStr)
+		page.Documents = append(page.Documents, doc)
+		created = append(created, createdStr)
+	}
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("ошибка чтения строк: %w", err)
+	}
+
+	// Лишняя строка означает, что есть следующая страница
+	if len(page.Documents) > limit {
+		page.Documents = page.Documents[:limit]
+		last := page.Documents[limit-1]
+		var key interface{}
+		switch filter.Sort {
+		case domain.SortByID:
+			key = last.ID
+		case domain.SortByTitle:
+			key = last.Title
+		case domain.SortByCreated:
+			key = created[limit-1]
+		case domain.SortBySize:
+			key = last.Size
+		case domain.SortByChunks:
+			key = last.Chunks
+		}
+		page.NextCursor = encodeCursor(listCursor{Sort: filter.Sort, Key: key, ID: last.ID})
+	}
+	return page, nil
+}
+
+// GetDocument возвращает документ по ID; если документа нет, ошибка оборачивает domain.ErrDocumentNotFound
+func (r *SQLiteDocumentRepository) GetDocument(id string) (*domain.Document, error) {
+	var doc domain.Document
+	var createdStr string
+	err := r.reader.QueryRow("SELECT id, title, content, "+createdColumn+" FROM documents WHERE id = ?", id).
+		Scan(&doc.ID, &doc.Title, &doc.Content, &createdStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrDocumentNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения документа: %w", err)
+	}
+	doc.CreatedAt = parseTimestamp(createdStr)
+	return &doc, nil
+}
+
+// GetChunks возвращает фрагменты документа в порядке следования в тексте
+func (r *SQLiteDocumentRepository) GetChunks(docID string) ([]domain.Chunk, error) {
+	rows, err := r.reader.Query("SELECT id, document_id, content FROM chunks WHERE document_id = ? ORDER BY rowid", docID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения фрагментов: %w", err)
+	}
+	defer rows.Close()
+
+	chunks := []domain.Chunk{}
+	for rows.Next() {
+		var chunk domain.Chunk
+		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.Content); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения строк: %w", err)
+	}
+	return chunks, nil
+}
+
+// MatchDocumentIDs возвращает ID документов, подходящих под шаблон GLOB (* - любая последовательность символов,
+// ? - один символ, [abc] - символ из набора)
+func (r *SQLiteDocumentRepository) MatchDocumentIDs(pattern string) ([]string, error) {
+	ids := []string{}
+	if err := r.reader.Select(&ids, "SELECT id FROM documents WHERE id GLOB ? ORDER BY id", pattern); err != nil {
+		return nil, fmt.Errorf("ошибка поиска документов по шаблону: %w", err)
+	}
+	return ids, nil
+}
+
 // DeleteDocument удаляет документ по ID
 func (r *SQLiteDocumentRepository) DeleteDocument(id string) error {
+	return r.retryBusy(func() error { return r.deleteDocument(id) })
+}
+
+func (r *SQLiteDocumentRepository) deleteDocument(id string) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return fmt.Errorf("не удалось начать транзакцию: %w", err)
@@ -496,5 +1009,11 @@ func (r *SQLiteDocumentRepository) DeleteDocument(id string) error {
 
 // Close закрывает соединение с базой данных
 func (r *SQLiteDocumentRepository) Close() error {
+	if r.reader != r.db {
+		if err := r.reader.Close(); err != nil {
+			r.db.Close()
+			return err
+		}
+	}
 	return r.db.Close()
 }
@@ -0,0 +1,215 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteOptions параметры соединений SQLite (секция storage конфигурации)
+type SQLiteOptions struct {
+	JournalMode string        // Режим журнала: wal, delete, truncate, persist, memory, off (пусто - wal)
+	Synchronous string        // Уровень synchronous: off, normal, full, extra (пусто - normal)
+	BusyTimeout time.Duration // Ожидание блокировки другим соединением (0 - 5 секунд)
+	CacheSizeKB int           // Размер кэша страниц на соединение в КиБ (0 - по умолчанию SQLite)
+	MmapSize    int64         // Объем файла, читаемый через mmap, в байтах (0 - не использовать)
+	ReadConns   int           // Соединений для чтения (0 - 4)
+	BusyRetries int           // Повторов транзакции записи при SQLITE_BUSY (0 - 3)
+}
+
+// withDefaults заполняет незаданные параметры
+func (o SQLiteOptions) withDefaults() SQLiteOptions {
+	if o.JournalMode == "" {
+		o.JournalMode = "wal"
+	}
+	if o.Synchronous == "" {
+		o.Synchronous = "normal"
+	}
+	if o.BusyTimeout == 0 {
+		o.BusyTimeout = 5 * time.Second
+	}
+	if o.ReadConns == 0 {
+		o.ReadConns = 4
+	}
+	if o.BusyRetries == 0 {
+		o.BusyRetries = 3
+	}
+	return o
+}
+
+// validate проверяет значения, которые подставляются в PRAGMA
+func (o SQLiteOptions) validate() error {
+	journalModes := []string{"wal", "delete", "truncate", "persist", "memory", "off"}
+	if !containsFold(journalModes, o.JournalMode) {
+		return fmt.Errorf("неизвестный режим журнала %q (допустимо: %s)", o.JournalMode, strings.Join(journalModes, ", "))
+	}
+	levels := []string{"off", "normal", "full", "extra"}
+	if !containsFold(levels, o.Synchronous) {
+		return fmt.Errorf("неизвестный уровень synchronous %q (допустимо: %s)", o.Synchronous, strings.Join(levels, ", "))
+	}
+	if o.BusyTimeout < 0 || o.CacheSizeKB < 0 || o.MmapSize < 0 || o.ReadConns < 0 || o.BusyRetries < 0 {
+		return errors.New("параметры соединений SQLite не могут быть отрицательными")
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// pragmas PRAGMA, выполняемые при открытии каждого соединения
+func (o SQLiteOptions) pragmas(readOnly bool) []string {
+	pragmas := []string{
+		fmt.Sprintf("PRAGMA busy_timeout = %d", o.BusyTimeout.Milliseconds()),
+		"PRAGMA synchronous = " + strings.ToUpper(o.Synchronous),
+	}
+	if !readOnly {
+		// Режим журнала хранится в файле базы, его достаточно задать соединению записи
+		pragmas = append([]string{"PRAGMA journal_mode = " + strings.ToUpper(o.JournalMode)}, pragmas...)
+	}
+	if o.CacheSizeKB > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA cache_size = -%d", o.CacheSizeKB))
+	}
+	if o.MmapSize > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA mmap_size = %d", o.MmapSize))
+	}
+	if readOnly {
+		pragmas = append(pragmas, "PRAGMA query_only = 1")
+	}
+	return pragmas
+}
+
+// connector открывает соединения SQLite и выполняет на каждом из них PRAGMA
+type connector struct {
+	dsn     string
+	pragmas []string
+	driver  *sqlite3.SQLiteDriver
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, pragma := range c.pragmas {
+		if _, err := conn.(*sqlite3.SQLiteConn).Exec(pragma, nil); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ошибка выполнения %s: %w", pragma, err)
+		}
+	}
+	return conn, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// openPools открывает пул записи из одного соединения и пул чтения. Транзакции записи начинаются
+// с BEGIN IMMEDIATE: блокировка на запись берется сразу, а не при первой вставке, поэтому транзакции
+// не блокируют друг друга взаимно. База в памяти существует в единственном соединении, поэтому
+// для нее оба пула совпадают (см. isMemoryDSN)
+func openPools(dbPath string, opts SQLiteOptions) (writer, reader *sqlx.DB, err error) {
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	writerDB := sql.OpenDB(&connector{dsn: dbPath + separator + "_txlock=immediate", pragmas: opts.pragmas(false),
+		driver: &sqlite3.SQLiteDriver{}})
+	writerDB.SetMaxOpenConns(1)
+	writer = sqlx.NewDb(writerDB, "sqlite3")
+	if err := writer.Ping(); err != nil {
+		writer.Close()
+		return nil, nil, err
+	}
+
+	if isMemoryDSN(dbPath) {
+		return writer, writer, nil
+	}
+	readerDB := sql.OpenDB(&connector{dsn: dbPath, pragmas: opts.pragmas(true), driver: &sqlite3.SQLiteDriver{}})
+	readerDB.SetMaxOpenConns(opts.ReadConns)
+	readerDB.SetMaxIdleConns(opts.ReadConns)
+	return writer, sqlx.NewDb(readerDB, "sqlite3"), nil
+}
+
+// OpenSQLite открывает базу вне репозитория (например, для миграций схемы) с теми же PRAGMA, что и соединения
+// репозитория. С readOnly открывается только существующий файл и только для чтения (mode=ro): отсутствующий
+// файл не создается, а запись в базу завершается ошибкой.
+func OpenSQLite(dbPath string, opts SQLiteOptions, readOnly bool) (*sql.DB, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	dsn := dbPath
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	if readOnly && !isMemoryDSN(dbPath) {
+		name, _, _ := strings.Cut(strings.TrimPrefix(dbPath, "file:"), "?")
+		if _, err := os.Stat(name); err != nil {
+			return nil, fmt.Errorf("база данных %s недоступна: %w", name, err)
+		}
+		if !strings.HasPrefix(dsn, "file:") {
+			dsn = "file:" + dsn
+		}
+		dsn += separator + "mode=ro"
+	} else {
+		dsn += separator + "_txlock=immediate"
+	}
+
+	db := sql.OpenDB(&connector{dsn: dsn, pragmas: opts.pragmas(readOnly), driver: &sqlite3.SQLiteDriver{}})
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("не удалось подключиться к базе данных: %w", err)
+	}
+	return db, nil
+}
+
+// isMemoryDSN сообщает, что каждое соединение с dsn получает собственную базу: в памяти (":memory:",
+// "file::memory:", "file:name?mode=memory") или временную (пустое имя файла)
+func isMemoryDSN(dsn string) bool {
+	name, query, _ := strings.Cut(dsn, "?")
+	name = strings.TrimPrefix(name, "file:")
+	if name == "" || name == ":memory:" {
+		return true
+	}
+	values, err := url.ParseQuery(query)
+	return err == nil && values.Get("mode") == "memory"
+}
+
+// isBusy сообщает, что операция не выполнена из-за блокировки базы другим соединением
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// retryBusy выполняет транзакцию записи op и повторяет ее при SQLITE_BUSY с растущей паузой.
+// op должна начинать транзакцию заново: после ошибки предыдущая попытка откатывается
+func (r *SQLiteDocumentRepository) retryBusy(op func() error) error {
+	delay := 50 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || !isBusy(err) || attempt >= r.busyRetries {
+			return err
+		}
+		r.logger.Warn("База данных заблокирована, повторяем транзакцию", "attempt", attempt+1, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,247 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"rag-system/src/config"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter получатель завершенных span
+type Exporter interface {
+	// Export отправляет пакет span
+	Export(ctx context.Context, spans []SpanData) error
+
+	// Shutdown освобождает ресурсы экспортера
+	Shutdown(ctx context.Context) error
+}
+
+// FromConfig создает Tracer по секции tracing; при выключенной трассировке возвращает nil
+func FromConfig(cfg config.TracingConfig, logger *slog.Logger) (*Tracer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var exporter Exporter
+	switch cfg.Exporter {
+	case "", "file":
+		fileExporter, err := NewFileExporter(cfg.Path, cfg.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		exporter = fileExporter
+	case "otlp":
+		exporter = NewOTLPExporter(cfg.Endpoint, cfg.ServiceName)
+	default:
+		return nil, fmt.Errorf("неизвестный экспортер трассировки %q (допустимо: file, otlp)", cfg.Exporter)
+	}
+	return NewTracer(exporter, TracerOptions{SampleRatio: cfg.SampleRatio, Logger: logger}), nil
+}
+
+// FileExporter записывает span в файл в формате JSON, по одному span на строку
+type FileExporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	closer  io.Closer
+	service string
+}
+
+// fileSpan запись span в файле
+type fileSpan struct {
+	TraceID       string                 `json:"trace_id"`
+	SpanID        string                 `json:"span_id"`
+	ParentSpanID  string                 `json:"parent_span_id,omitempty"`
+	Name          string                 `json:"name"`
+	Kind          string                 `json:"kind"`
+	Service       string                 `json:"service,omitempty"`
+	Start         time.Time              `json:"start"`
+	End           time.Time              `json:"end"`
+	DurationMS    float64                `json:"duration_ms"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Status        string                 `json:"status"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+}
+
+// NewFileExporter открывает файл path для дозаписи span
+func NewFileExporter(path, service string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл трассировки: %w", err)
+	}
+	return &FileExporter{w: f, closer: f, service: service}, nil
+}
+
+// NewWriterExporter записывает span в w; используется для вывода в stdout и в тестах
+func NewWriterExporter(w io.Writer, service string) *FileExporter {
+	return &FileExporter{w: w, service: service}
+}
+
+// Export записывает span
+func (e *FileExporter) Export(_ context.Context, spans []SpanData) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, span := range spans {
+		record := fileSpan{
+			TraceID:       span.Context.TraceID.String(),
+			SpanID:        span.Context.SpanID.String(),
+			Name:          span.Name,
+			Kind:          span.Kind.String(),
+			Service:       e.service,
+			Start:         span.Start,
+			End:           span.End,
+			DurationMS:    float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+			Status:        span.Status,
+			StatusMessage: span.StatusMessage,
+		}
+		if span.Parent != (SpanID{}) {
+			record.ParentSpanID = span.Parent.String()
+		}
+		if len(span.Attributes) > 0 {
+			record.Attributes = make(map[string]interface{}, len(span.Attributes))
+			for _, attr := range span.Attributes {
+				record.Attributes[attr.Key] = attr.Value
+			}
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("ошибка кодирования span: %w", err)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("ошибка записи span: %w", err)
+	}
+	return nil
+}
+
+// Shutdown закрывает файл
+func (e *FileExporter) Shutdown(context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// OTLPExporter отправляет span в коллектор OpenTelemetry по протоколу OTLP/HTTP в кодировке JSON
+type OTLPExporter struct {
+	url     string
+	service string
+	client  *http.Client
+}
+
+// NewOTLPExporter создает экспортер для коллектора endpoint (например, http://localhost:4318)
+func NewOTLPExporter(endpoint, service string) *OTLPExporter {
+	return &OTLPExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export отправляет span в коллектор
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования span: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса к коллектору: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка отправки span в коллектор: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("коллектор вернул HTTP %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
+
+// Shutdown ничего не делает: соединения закрываются вместе с процессом
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// payload возвращает тело запроса ExportTraceServiceRequest
+func (e *OTLPExporter) payload(spans []SpanData) map[string]interface{} {
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, span := range spans {
+		s := map[string]interface{}{
+			"traceId":           span.Context.TraceID.String(),
+			"spanId":            span.Context.SpanID.String(),
+			"name":              span.Name,
+			"kind":              int(span.Kind) + 1, // SPAN_KIND_INTERNAL = 1, SERVER = 2, CLIENT = 3
+			"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.Attributes),
+			"status":            otlpStatus(span),
+		}
+		if span.Parent != (SpanID{}) {
+			s["parentSpanId"] = span.Parent.String()
+		}
+		otlpSpans = append(otlpSpans, s)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes([]Attr{String("service.name", e.service)}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "rag-system"},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+}
+
+// otlpAttributes кодирует атрибуты в формате OTLP JSON
+func otlpAttributes(attrs []Attr) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(attrs))
+	for _, attr := range attrs {
+		var value map[string]interface{}
+		switch v := attr.Value.(type) {
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, map[string]interface{}{"key": attr.Key, "value": value})
+	}
+	return out
+}
+
+// otlpStatus кодирует статус span: STATUS_CODE_UNSET = 0, OK = 1, ERROR = 2
+func otlpStatus(span SpanData) map[string]interface{} {
+	code := 0
+	switch span.Status {
+	case StatusOK:
+		code = 1
+	case StatusError:
+		code = 2
+	}
+	status := map[string]interface{}{"code": code}
+	if span.StatusMessage != "" {
+		status["message"] = span.StatusMessage
+	}
+	return status
+}
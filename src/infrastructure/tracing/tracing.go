@@ -0,0 +1,452 @@
+// Package tracing трассировка этапов обработки запроса в модели OpenTelemetry: span с атрибутами и статусом,
+// распространение контекста через заголовок W3C traceparent и экспорт в файл или коллектор OTLP/HTTP
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID идентификатор трассы
+type TraceID [16]byte
+
+// SpanID идентификатор span
+type SpanID [8]byte
+
+// String возвращает идентификатор в шестнадцатеричном виде
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// String возвращает идентификатор в шестнадцатеричном виде
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext идентификаторы span, передаваемые между процессами
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid сообщает, заданы ли идентификаторы
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent возвращает значение заголовка traceparent (версия 00)
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent разбирает заголовок traceparent; ok = false для некорректного значения
+func ParseTraceparent(value string) (sc SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	// Версия 00 не допускает дополнительных полей
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// SpanKind роль span в обмене между процессами
+type SpanKind int
+
+const (
+	KindInternal SpanKind = iota // Внутренний этап обработки
+	KindServer                   // Обработка входящего запроса
+	KindClient                   // Исходящий запрос к другому сервису
+)
+
+// String возвращает имя вида span
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// Attr атрибут span
+type Attr struct {
+	Key   string
+	Value interface{} // string, int64, float64 или bool
+}
+
+// String атрибут-строка
+func String(key, value string) Attr { return Attr{Key: key, Value: value} }
+
+// Int атрибут-целое
+func Int(key string, value int) Attr { return Attr{Key: key, Value: int64(value)} }
+
+// Float64 атрибут-число
+func Float64(key string, value float64) Attr { return Attr{Key: key, Value: value} }
+
+// Bool атрибут-флаг
+func Bool(key string, value bool) Attr { return Attr{Key: key, Value: value} }
+
+// Коды статуса span
+const (
+	StatusUnset = "unset"
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// SpanData завершенный span, передаваемый экспортеру
+type SpanData struct {
+	Context       SpanContext
+	Parent        SpanID // Пустой для корневого span
+	Name          string
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attr
+	Status        string
+	StatusMessage string
+}
+
+// Span этап обработки запроса. Методы nil получателя ничего не делают: span не создается,
+// если трассировка выключена или запрос не выбран для трассировки.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SetAttributes добавляет атрибуты; атрибут с тем же ключом заменяется
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range attrs {
+		replaced := false
+		for i := range s.data.Attributes {
+			if s.data.Attributes[i].Key == attr.Key {
+				s.data.Attributes[i] = attr
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			s.data.Attributes = append(s.data.Attributes, attr)
+		}
+	}
+}
+
+// SetKind задает вид span
+func (s *Span) SetKind(kind SpanKind) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Kind = kind
+}
+
+// RecordError отмечает span как завершившийся ошибкой; nil ошибка игнорируется
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = StatusError
+	s.data.StatusMessage = err.Error()
+}
+
+// SetStatusOK отмечает span как успешный
+func (s *Span) SetStatusOK() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Status != StatusError {
+		s.data.Status = StatusOK
+	}
+}
+
+// SpanContext возвращает идентификаторы span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.Context
+}
+
+// End завершает span и передает его экспортеру; повторный вызов ничего не делает
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Attributes = append([]Attr(nil), s.data.Attributes...)
+	s.mu.Unlock()
+	s.tracer.enqueue(data)
+}
+
+// spanKey и remoteKey ключи контекста
+type (
+	spanKey   struct{}
+	remoteKey struct{}
+)
+
+// ContextWithSpan возвращает контекст с активным span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext возвращает активный span или nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext возвращает идентификаторы активного span или входящего контекста трассировки
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Extract возвращает контекст с контекстом трассировки из заголовка traceparent входящего запроса
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceparent(header.Get("traceparent"))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject добавляет заголовок traceparent активного span в исходящий запрос
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set("traceparent", sc.Traceparent())
+	}
+}
+
+// Start начинает дочерний span активного span из ctx. Без активного span возвращает ctx и nil:
+// компоненты создают span только в трассируемых запросах.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, attrs...)
+}
+
+// Tracer создает span и передает завершенные span экспортеру пакетами в фоновом режиме
+type Tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+	logger      *slog.Logger
+
+	mu      sync.RWMutex // Защищает closed и отправку в queue
+	closed  bool
+	queue   chan SpanData
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// TracerOptions параметры Tracer
+type TracerOptions struct {
+	SampleRatio   float64       // Доля новых трасс, которые записываются (0 - только продолжения входящих трасс)
+	BatchSize     int           // Максимум span в одном экспорте (0 - 128)
+	FlushInterval time.Duration // Период экспорта неполного пакета (0 - 1s)
+	Logger        *slog.Logger  // Журнал ошибок экспорта (nil - slog.Default())
+}
+
+// NewTracer создает Tracer и запускает фоновый экспорт; Shutdown отправляет оставшиеся span
+func NewTracer(exporter Exporter, opts TracerOptions) *Tracer {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 128
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	t := &Tracer{
+		exporter:    exporter,
+		sampleRatio: opts.SampleRatio,
+		logger:      opts.Logger.With("component", "tracing"),
+		queue:       make(chan SpanData, 4*opts.BatchSize),
+		done:        make(chan struct{}),
+	}
+	go t.run(opts.BatchSize, opts.FlushInterval)
+	return t
+}
+
+// Start начинает span: дочерний для активного span из ctx, продолжение входящей трассы (Extract)
+// или новую трассу. Для nil Tracer работает как пакетная функция Start.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if t == nil {
+		return Start(ctx, name, attrs...)
+	}
+
+	sc := SpanContext{SpanID: newSpanID()}
+	var parent SpanID
+	if p := SpanContextFromContext(ctx); p.IsValid() {
+		sc.TraceID, sc.Sampled, parent = p.TraceID, p.Sampled, p.SpanID
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.sample(sc.TraceID)
+	}
+	if !sc.Sampled {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: t, data: SpanData{
+		Context: sc,
+		Parent:  parent,
+		Name:    name,
+		Start:   time.Now(),
+		Status:  StatusUnset,
+	}}
+	span.SetAttributes(attrs...)
+	return ContextWithSpan(ctx, span), span
+}
+
+// sample решает по идентификатору трассы, записывать ли новую трассу
+func (t *Tracer) sample(id TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	return float64(binary.BigEndian.Uint64(id[8:])>>11)/(1<<53) < t.sampleRatio
+}
+
+// Dropped возвращает количество span, отброшенных из-за переполнения очереди
+func (t *Tracer) Dropped() int64 {
+	if t == nil {
+		return 0
+	}
+	return t.dropped.Load()
+}
+
+// enqueue ставит завершенный span в очередь экспорта; при переполнении span отбрасывается
+func (t *Tracer) enqueue(data SpanData) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		t.dropped.Add(1)
+		return
+	}
+	select {
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// run собирает span в пакеты и экспортирует их
+func (t *Tracer) run(batchSize int, interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			t.logger.Warn("Не удалось экспортировать span", "spans", len(batch), "error", err)
+		}
+		cancel()
+		batch = make([]SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case data, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Shutdown экспортирует оставшиеся span и закрывает экспортер
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return fmt.Errorf("не дождались экспорта span: %w", ctx.Err())
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// newTraceID создает случайный идентификатор трассы
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+// newSpanID создает случайный идентификатор span
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
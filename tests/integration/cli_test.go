@@ -0,0 +1,63 @@
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/config"
+	"rag-system/src/infrastructure/cache"
+)
+
+// buildCLI собирает исполняемый файл приложения во временный каталог
+func buildCLI(t *testing.T) string {
+	binary := filepath.Join(t.TempDir(), "rag")
+	output, err := exec.Command("go", "build", "-o", binary, "../..").CombinedOutput()
+	require.NoError(t, err, string(output))
+	return binary
+}
+
+// TestCLIWithoutAISection проверяет, что действия, не обращающиеся к модели, работают с пустой секцией ai,
+// а импорт сбрасывает кэшированные ответы по импортированным документам
+func TestCLIWithoutAISection(t *testing.T) {
+	binary := buildCLI(t)
+	dir := t.TempDir()
+
+	cacheConfig := config.CacheConfig{Backend: "file", Dir: filepath.Join(dir, "cache")}
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("ai: {}\ncache:\n  backend: file\n  dir: "+cacheConfig.Dir+"\n"), 0644))
+
+	docPath := filepath.Join(dir, "doc.txt")
+	require.NoError(t, os.WriteFile(docPath, []byte("Главный офис находится в Москве."), 0644))
+	archivePath := filepath.Join(dir, "kb.tar.gz")
+
+	run := func(db string, args ...string) string {
+		cmd := exec.Command(binary, append([]string{"-config=" + configPath, "-db=" + filepath.Join(dir, db)}, args...)...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return string(output)
+	}
+
+	run("source.db", "-action=index", "-doc="+docPath)
+	run("source.db", "-action=export", "-file="+archivePath)
+	run("source.db", "-action=backup", "-file="+filepath.Join(dir, "backup.db"))
+
+	// Ответ, сохраненный ранее по документу, должен быть сброшен импортом
+	responses, err := cache.New(cacheConfig)
+	require.NoError(t, err)
+	require.NoError(t, responses.Set("answer", []byte("Офис в Москве"), []string{docPath}))
+	require.NoError(t, responses.Close())
+
+	output := run("target.db", "-action=import", "-file="+archivePath)
+	assert.Contains(t, output, "Импортировано: 1 из 1")
+
+	responses, err = cache.New(cacheConfig)
+	require.NoError(t, err)
+	defer responses.Close()
+	_, found := responses.Get("answer")
+	assert.False(t, found, "Импорт должен сбросить ответы по импортированному документу")
+}
@@ -3,7 +3,10 @@ package mocks
 import (
 	"fmt"
 	"rag-system/src/domain"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
 // MockDocumentRepository имитация репозитория для тестирования
@@ -14,6 +17,7 @@ type MockDocumentRepository struct {
 	FindRelevantChunksFn func(query string, limit int, threshold float64) ([]domain.Chunk, error)
 	GetAllDocumentsFn    func() ([]domain.Document, error)
 	DeleteDocumentFn     func(id string) error
+	UpdateDocumentFn     func(doc domain.Document) error
 }
 
 func NewMockDocumentRepository() *MockDocumentRepository {
@@ -28,10 +32,30 @@ func (m *MockDocumentRepository) SaveDocument(doc domain.Document) error {
 		return m.SaveDocumentFn(doc)
 	}
 
-	// Сохраняем документ
+	// Сохраняем документ; время создания, как и в SQLite, по умолчанию текущее
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now().UTC()
+	}
 	m.Documents[doc.ID] = doc
+	m.Chunks[doc.ID] = splitChunks(doc)
+	return nil
+}
+
+func (m *MockDocumentRepository) SaveDocuments(docs []domain.Document) error {
+	var bulkErr domain.BulkError
+	for _, doc := range docs {
+		if err := m.SaveDocument(doc); err != nil {
+			bulkErr.Errors = append(bulkErr.Errors, domain.DocumentError{ID: doc.ID, Err: err})
+		}
+	}
+	if len(bulkErr.Errors) > 0 {
+		return &bulkErr
+	}
+	return nil
+}
 
-	// Создаем фрагменты из содержимого документа
+// splitChunks разбивает документ на фрагменты по 100 байт
+func splitChunks(doc domain.Document) []domain.Chunk {
 	var chunks []domain.Chunk
 	content := doc.Content
 	chunkSize := 100
@@ -50,11 +74,103 @@ func (m *MockDocumentRepository) SaveDocument(doc domain.Document) error {
 		}
 		chunks = append(chunks, chunk)
 	}
+	return chunks
+}
 
-	m.Chunks[doc.ID] = chunks
+func (m *MockDocumentRepository) UpdateDocument(doc domain.Document) error {
+	if m.UpdateDocumentFn != nil {
+		return m.UpdateDocumentFn(doc)
+	}
+
+	existing, ok := m.Documents[doc.ID]
+	if !ok {
+		return fmt.Errorf("%w: %s", domain.ErrDocumentNotFound, doc.ID)
+	}
+	doc.CreatedAt = existing.CreatedAt
+	m.Documents[doc.ID] = doc
+	m.Chunks[doc.ID] = splitChunks(doc)
 	return nil
 }
 
+func (m *MockDocumentRepository) GetDocument(id string) (*domain.Document, error) {
+	doc, ok := m.Documents[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrDocumentNotFound, id)
+	}
+	return &doc, nil
+}
+
+func (m *MockDocumentRepository) GetChunks(docID string) ([]domain.Chunk, error) {
+	return append([]domain.Chunk{}, m.Chunks[docID]...), nil
+}
+
+// ListDocuments имитирует постраничный список; курсор - ID последнего документа предыдущей страницы
+func (m *MockDocumentRepository) ListDocuments(filter domain.DocumentFilter, cursor string, limit int) (domain.DocumentPage, error) {
+	var summaries []domain.DocumentSummary
+	for _, doc := range m.Documents {
+		if !strings.HasPrefix(doc.ID, filter.IDPrefix) || !strings.Contains(doc.Title, filter.Title) ||
+			(!filter.CreatedAfter.IsZero() && doc.CreatedAt.Before(filter.CreatedAfter)) ||
+			(!filter.CreatedBefore.IsZero() && !doc.CreatedAt.Before(filter.CreatedBefore)) {
+			continue
+		}
+		summaries = append(summaries, domain.DocumentSummary{ID: doc.ID, Title: doc.Title, CreatedAt: doc.CreatedAt,
+			Size: len(doc.Content), Chunks: len(m.Chunks[doc.ID])})
+	}
+
+	less := func(a, b domain.DocumentSummary) bool {
+		switch filter.Sort {
+		case domain.SortByTitle:
+			if a.Title != b.Title {
+				return a.Title < b.Title
+			}
+		case domain.SortByCreated:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		case domain.SortBySize:
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case domain.SortByChunks:
+			if a.Chunks != b.Chunks {
+				return a.Chunks < b.Chunks
+			}
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if filter.Desc {
+			return less(summaries[j], summaries[i])
+		}
+		return less(summaries[i], summaries[j])
+	})
+
+	page := domain.DocumentPage{Documents: []domain.DocumentSummary{}, Total: len(summaries)}
+	start := 0
+	if cursor != "" {
+		start = -1
+		for i, doc := range summaries {
+			if doc.ID == cursor {
+				start = i + 1
+			}
+		}
+		if start < 0 {
+			return page, fmt.Errorf("некорректный курсор %q", cursor)
+		}
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	end := start + limit
+	if end < len(summaries) {
+		page.NextCursor = summaries[end-1].ID
+	} else {
+		end = len(summaries)
+	}
+	page.Documents = append(page.Documents, summaries[start:end]...)
+	return page, nil
+}
+
 func (m *MockDocumentRepository) FindRelevantChunks(query string, limit int, threshold float64) ([]domain.Chunk, error) {
 	if m.FindRelevantChunksFn != nil {
 		return m.FindRelevantChunksFn(query, limit, threshold)
@@ -133,6 +249,58 @@ func (m *MockDocumentRepository) GetAllDocuments() ([]domain.Document, error) {
 	return docs, nil
 }
 
+func (m *MockDocumentRepository) MatchDocumentIDs(pattern string) ([]string, error) {
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for id := range m.Documents {
+		if re.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// globRegexp переводит шаблон GLOB в регулярное выражение; как в SQLite, * совпадает и с '/'
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := i + 1
+			if end < len(runes) && runes[end] == '^' {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				b.WriteString(regexp.QuoteMeta(string(runes[i:])))
+				i = end
+				continue
+			}
+			b.WriteString(string(runes[i : end+1]))
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
 func (m *MockDocumentRepository) DeleteDocument(id string) error {
 	if m.DeleteDocumentFn != nil {
 		return m.DeleteDocumentFn(id)
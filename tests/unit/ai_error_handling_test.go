@@ -1,8 +1,10 @@
 package unit
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -80,6 +82,28 @@ func TestAIClient500Error(t *testing.T) {
 	_ = server
 }
 
+// TestAIClientRetryCount проверяет число попыток при 5xx, в том числе явное отключение повторов
+func TestAIClientRetryCount(t *testing.T) {
+	for _, retries := range []int{0, 2} {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		client := newTestClient(t, newTestConfig(server.URL, func(config *ai.Config) {
+			config.AI.MaxRetries = retries
+			config.AI.RetryDelay = 0
+		}))
+		_, err := client.Generate(context.Background(), ai.GenerateRequest{Query: "Вопрос",
+			Chunks: []domain.Chunk{{ID: "c", DocumentID: "d", Content: "Контекст"}}})
+		server.Close()
+
+		assert.Error(t, err)
+		assert.Equal(t, int32(retries+1), atomic.LoadInt32(&attempts), "max_retries = %d", retries)
+	}
+}
+
 // TestAIClientNetworkError проверяет обработку сетевых ошибок
 func TestAIClientNetworkError(t *testing.T) {
 	// Создаем сервер, который сразу закрывается
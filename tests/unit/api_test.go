@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/api"
+	"rag-system/src/application"
+	"rag-system/src/config"
+	"rag-system/src/infrastructure/logging"
+	"rag-system/tests/mocks"
+)
+
+// TestRateLimiter проверяет ограничение всплеска и изменение лимитов на лету
+func TestRateLimiter(t *testing.T) {
+	limiter := api.NewRateLimiter(0.01, 2)
+	for i := 0; i < 2; i++ {
+		ok, _ := limiter.Allow("client")
+		assert.True(t, ok)
+	}
+	ok, wait := limiter.Allow("client")
+	assert.False(t, ok)
+	assert.Greater(t, wait.Seconds(), 1.0)
+
+	// У другого клиента своя корзина
+	ok, _ = limiter.Allow("other")
+	assert.True(t, ok)
+
+	limiter.SetLimit(0, 0)
+	ok, _ = limiter.Allow("client")
+	assert.True(t, ok, "rate_limit 0 снимает ограничение")
+}
+
+// TestServerSearchAndRateLimit проверяет поиск через HTTP API и применение новых лимитов при перезагрузке
+func TestServerSearchAndRateLimit(t *testing.T) {
+	var requests []map[string]interface{}
+	aiServer := newStructuredServer(t, []string{"Главный офис находится в Москве."}, &requests)
+	defer aiServer.Close()
+
+	cfg := newTestConfig(aiServer.URL)
+	service := application.NewRAGService(mocks.NewMockDocumentRepository(), newTestClient(t, cfg))
+
+	serverCfg := config.Default().Server
+	server := api.NewServer(service, serverCfg, logging.Discard(), nil)
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	post := func(path string, body interface{}) (*http.Response, map[string]interface{}) {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		resp, err := http.Post(httpServer.URL+path, "application/json", bytes.NewReader(data))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return resp, out
+	}
+
+	resp, _ := post("/documents", map[string]string{"id": "contacts", "title": "Контакты", "content": "Главный офис находится в Москве."})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, out := post("/search", map[string]string{"query": "офис"})
+	require.Equal(t, http.StatusOK, resp.StatusCode, out)
+	assert.Equal(t, "Главный офис находится в Москве.", out["answer"])
+
+	resp, out = post("/search", map[string]string{"query": ""})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, out["error"], "query")
+
+	// Новый лимит применяется к уже работающему серверу
+	serverCfg.RateLimit = 0.01
+	serverCfg.RateBurst = 1
+	server.Reload(serverCfg)
+	resp, _ = post("/search", map[string]string{"query": "офис"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, out = post("/search", map[string]string{"query": "офис"})
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	assert.Contains(t, out["error"], "лимит")
+}
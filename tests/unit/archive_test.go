@@ -0,0 +1,158 @@
+package unit
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure"
+	"rag-system/src/infrastructure/archive"
+)
+
+// seedArchiveRepository создает базу с мелкими фрагментами, чтобы импорт с другим размером фрагмента
+// показал, что фрагменты переносятся как есть
+func seedArchiveRepository(t *testing.T) *infrastructure.SQLiteDocumentRepository {
+	repo := newTestRepository(t, "", withChunkSize(40))
+
+	created := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, repo.SaveDocument(domain.Document{
+			ID:        fmt.Sprintf("doc-%d", i),
+			Title:     fmt.Sprintf("Документ %d", i),
+			Content:   strings.Repeat(fmt.Sprintf("Предложение документа %d. ", i), 5),
+			CreatedAt: created.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	return repo
+}
+
+// assertSameDocuments сравнивает документы и фрагменты двух баз
+func assertSameDocuments(t *testing.T, want, got *infrastructure.SQLiteDocumentRepository, ids ...string) {
+	for _, id := range ids {
+		wantDoc, err := want.GetDocument(id)
+		require.NoError(t, err)
+		gotDoc, err := got.GetDocument(id)
+		require.NoError(t, err, id)
+		assert.Equal(t, wantDoc, gotDoc)
+
+		wantChunks, err := want.GetChunks(id)
+		require.NoError(t, err)
+		gotChunks, err := got.GetChunks(id)
+		require.NoError(t, err)
+		assert.Equal(t, wantChunks, gotChunks)
+	}
+}
+
+// TestExportImportRoundTrip проверяет перенос документов, метаданных и фрагментов в обоих форматах
+func TestExportImportRoundTrip(t *testing.T) {
+	source := seedArchiveRepository(t)
+	// Документ без фрагментов между документами с фрагментами
+	require.NoError(t, source.SaveDocument(domain.Document{ID: "doc-0a", Title: "Пустой"}))
+
+	for _, name := range []string{"kb.jsonl", "kb.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			manifest, err := archive.ExportFile(source, path)
+			require.NoError(t, err)
+			assert.Equal(t, archive.Version, manifest.Version)
+			assert.Equal(t, 4, manifest.Documents)
+			assert.Greater(t, manifest.Chunks, 3)
+
+			target := newTestRepository(t, "")
+			result, err := archive.ImportFile(target, path, archive.ImportOptions{})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"doc-0", "doc-0a", "doc-1", "doc-2"}, result.Imported)
+			assert.Empty(t, result.Errors)
+
+			assertSameDocuments(t, source, target, "doc-0", "doc-0a", "doc-1", "doc-2")
+		})
+	}
+}
+
+// TestImportPolicies проверяет, что upsert сохраняет документы не из архива, а replace удаляет их
+func TestImportPolicies(t *testing.T) {
+	source := seedArchiveRepository(t)
+	path := filepath.Join(t.TempDir(), "kb.jsonl")
+	_, err := archive.ExportFile(source, path)
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		policy  string
+		deleted []string
+	}{
+		{archive.PolicyUpsert, nil},
+		{archive.PolicyReplace, []string{"local"}},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			target := newTestRepository(t, "")
+			require.NoError(t, target.SaveDocument(domain.Document{ID: "doc-0", Title: "Старая версия", Content: "Устаревший текст."}))
+			require.NoError(t, target.SaveDocument(domain.Document{ID: "local", Title: "Локальный", Content: "Только в этой базе."}))
+
+			result, err := archive.ImportFile(target, path, archive.ImportOptions{Policy: tt.policy})
+			require.NoError(t, err)
+			assert.Equal(t, tt.deleted, result.Deleted)
+			assertSameDocuments(t, source, target, "doc-0")
+
+			_, err = target.GetDocument("local")
+			if tt.policy == archive.PolicyReplace {
+				assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestImportRejectsIncompleteArchive проверяет, что усеченный архив отклоняется до записи в базу
+// и не удаляет документы при политике replace
+func TestImportRejectsIncompleteArchive(t *testing.T) {
+	source := seedArchiveRepository(t)
+	var buf bytes.Buffer
+	_, err := archive.Export(source, &buf, archive.FormatJSONL)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	truncated := strings.Join(lines[:len(lines)-1], "")
+
+	target := newTestRepository(t, "")
+	require.NoError(t, target.SaveDocument(domain.Document{ID: "local", Title: "Локальный", Content: "Только в этой базе."}))
+
+	result, err := archive.Import(target, strings.NewReader(truncated), archive.ImportOptions{Policy: archive.PolicyReplace})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "архив неполный")
+	assert.Empty(t, result.Imported)
+	assert.Empty(t, result.Deleted)
+	_, err = target.GetDocument("local")
+	assert.NoError(t, err)
+	_, err = target.GetDocument("doc-0")
+	assert.ErrorIs(t, err, domain.ErrDocumentNotFound, "документы неполного архива не сохраняются")
+}
+
+// TestImportRejectsNewerVersion проверяет, что архив более новой версии формата не импортируется
+func TestImportRejectsNewerVersion(t *testing.T) {
+	target := newTestRepository(t, "")
+	data := fmt.Sprintf(`{"version": %d, "documents": 0, "chunks": 0}`+"\n", archive.Version+1)
+
+	_, err := archive.Import(target, strings.NewReader(data), archive.ImportOptions{})
+	assert.ErrorContains(t, err, "неподдерживаемая версия архива")
+
+	_, err = archive.Import(target, strings.NewReader("{}\n"), archive.ImportOptions{Policy: "merge"})
+	assert.ErrorContains(t, err, "неизвестная политика импорта")
+}
+
+// TestBackup проверяет, что резервная копия открывается как обычная база и не перезаписывает существующий файл
+func TestBackup(t *testing.T) {
+	source := seedArchiveRepository(t)
+	path := filepath.Join(t.TempDir(), "backup.db")
+	require.NoError(t, source.Backup(path))
+
+	backup := newTestRepository(t, path)
+	assertSameDocuments(t, source, backup, "doc-0", "doc-1", "doc-2")
+
+	assert.ErrorContains(t, source.Backup(path), "уже существует")
+}
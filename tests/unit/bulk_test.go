@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/application"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure"
+	"rag-system/tests/mocks"
+)
+
+// TestSaveDocumentsReportsPerDocumentErrors проверяет, что ошибки отдельных документов не прерывают пакет
+func TestSaveDocumentsReportsPerDocumentErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rag.db")
+	repo := newTestRepository(t, path, func(opts *infrastructure.RepositoryOptions) { opts.BatchSize = 2 })
+
+	// Чужой фрагмент с ID, который получит первый фрагмент документа "broken"
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("INSERT INTO chunks (id, document_id, content) VALUES ('broken_chunk_0', 'other', 'старый')")
+	require.NoError(t, err)
+
+	err = repo.SaveDocuments([]domain.Document{
+		{ID: "a", Title: "A", Content: "первый документ"},
+		{ID: "a", Title: "A2", Content: "дубликат"},
+		{ID: "", Title: "Без ID", Content: "текст"},
+		{ID: "broken", Title: "B", Content: "фрагмент не вставится"},
+		{ID: "c", Title: "C", Content: "последний документ"},
+	})
+	var bulkErr *domain.BulkError
+	require.True(t, errors.As(err, &bulkErr), "ожидается BulkError, получено %v", err)
+	require.Len(t, bulkErr.Errors, 3)
+	assert.Equal(t, []string{"a", "", "broken"},
+		[]string{bulkErr.Errors[0].ID, bulkErr.Errors[1].ID, bulkErr.Errors[2].ID})
+	assert.ErrorContains(t, err, "пустой ID")
+
+	// Документ с неудачной вставкой фрагмента откатывается целиком, остальные сохранены
+	_, err = repo.GetDocument("broken")
+	assert.True(t, errors.Is(err, domain.ErrDocumentNotFound))
+	for _, id := range []string{"a", "c"} {
+		doc, err := repo.GetDocument(id)
+		require.NoError(t, err)
+		chunks, err := repo.GetChunks(id)
+		require.NoError(t, err)
+		assert.NotEmpty(t, chunks, "фрагменты %s должны быть сохранены", doc.ID)
+	}
+	doc, err := repo.GetDocument("a")
+	require.NoError(t, err)
+	assert.Equal(t, "A", doc.Title)
+	assertIndexOK(t, repo)
+}
+
+// TestBulkIndexerBatches проверяет фиксацию пакетами и уведомления о каждом документе
+func TestBulkIndexerBatches(t *testing.T) {
+	repo := newTestRepository(t, "")
+
+	var results []string
+	indexer, err := repo.NewBulkIndexer(infrastructure.BulkOptions{BatchSize: 3, OnResult: func(id string, err error) {
+		assert.NoError(t, err)
+		results = append(results, id)
+	}})
+	require.NoError(t, err)
+
+	for i := 0; i < 7; i++ {
+		require.NoError(t, indexer.Add(domain.Document{ID: fmt.Sprintf("doc-%d", i), Title: "Документ", Content: fmt.Sprintf("текст %d", i)}))
+		// Результаты сообщаются только после фиксации пакета
+		assert.Len(t, results, (i+1)/3*3)
+	}
+	assert.Equal(t, 6, indexer.Indexed())
+	require.NoError(t, indexer.Close())
+	assert.Equal(t, 7, indexer.Indexed())
+	assert.Len(t, results, 7)
+
+	page, err := repo.ListDocuments(domain.DocumentFilter{}, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 7, page.Total)
+	assert.Equal(t, []string{"doc-3"}, searchDocuments(t, repo, "3"))
+
+	_, err = repo.NewBulkIndexer(infrastructure.BulkOptions{BatchSize: -1})
+	assert.Error(t, err)
+}
+
+// TestIndexDocumentsService проверяет пакетную индексацию через сервис
+func TestIndexDocumentsService(t *testing.T) {
+	repo := mocks.NewMockDocumentRepository()
+	service := application.NewRAGService(repo, nil)
+
+	err := service.IndexDocuments([]domain.Document{{ID: "a", Content: "первый"}, {ID: "b", Content: "второй"}})
+	require.NoError(t, err)
+	assert.Len(t, repo.Documents, 2)
+
+	repo.SaveDocumentFn = func(doc domain.Document) error { return errors.New("диск заполнен") }
+	err = service.IndexDocuments([]domain.Document{{ID: "c", Content: "третий"}})
+	var bulkErr *domain.BulkError
+	require.True(t, errors.As(err, &bulkErr))
+	assert.Equal(t, "c", bulkErr.Errors[0].ID)
+}
@@ -0,0 +1,263 @@
+package unit
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/application"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ai"
+	"rag-system/src/infrastructure/cache"
+	"rag-system/tests/mocks"
+)
+
+// TestCacheKeyCoversRequest проверяет, что ключ кэша учитывает модель, параметры генерации, шаблон и все содержимое фрагментов
+func TestCacheKeyCoversRequest(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Ответ"}, &requests)
+	defer server.Close()
+
+	prefix := strings.Repeat("Общий вводный текст документа. ", 10)
+	chunks := []domain.Chunk{{ID: "doc_chunk_0", DocumentID: "doc", Content: prefix + "Версия 1."}}
+	req := ai.GenerateRequest{Query: "Какая версия?", Chunks: chunks}
+
+	// Клиенты с разными параметрами используют один файловый кэш: промах показывает, что ключи различаются
+	config := newTestConfig(server.URL, withFileCache(t.TempDir()))
+	client := newTestClient(t, config)
+
+	generate := func(client *ai.AIClient, req ai.GenerateRequest) *ai.GenerateResult {
+		result, err := client.Generate(context.Background(), req)
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.False(t, generate(client, req).Metrics.FromCache)
+	assert.True(t, generate(client, req).Metrics.FromCache)
+	assert.Len(t, requests, 1)
+
+	// Изменение содержимого дальше первых 100 байт
+	changed := req
+	changed.Chunks = []domain.Chunk{{ID: "doc_chunk_0", DocumentID: "doc", Content: prefix + "Версия 2."}}
+	assert.False(t, generate(client, changed).Metrics.FromCache)
+
+	// Другой шаблон промпта
+	summarize := req
+	summarize.Template = "summarize"
+	withTemplates := config
+	withTemplates.Prompts.Dir = "../../config/prompts"
+	assert.False(t, generate(newTestClient(t, withTemplates), summarize).Metrics.FromCache)
+
+	// Другая модель и параметры генерации
+	otherModel := config
+	otherModel.AI.Model = "other-model"
+	assert.False(t, generate(newTestClient(t, otherModel), req).Metrics.FromCache)
+
+	otherTemperature := config
+	otherTemperature.AI.Temperature = 0.7
+	assert.False(t, generate(newTestClient(t, otherTemperature), req).Metrics.FromCache)
+
+	otherTokens := config
+	otherTokens.AI.MaxTokens = 200
+	assert.False(t, generate(newTestClient(t, otherTokens), req).Metrics.FromCache)
+
+	assert.Len(t, requests, 6)
+}
+
+// TestCacheInvalidatedOnDocumentChange проверяет сброс кэша при обновлении и удалении документа
+func TestCacheInvalidatedOnDocumentChange(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Офис в Москве"}, &requests)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL))
+	repo := mocks.NewMockDocumentRepository()
+	service := application.NewRAGService(repo, client)
+
+	require.NoError(t, service.IndexDocument(domain.Document{ID: "contacts", Title: "Контакты", Content: "Главный офис находится в Москве."}))
+	require.NoError(t, service.IndexDocument(domain.Document{ID: "company", Title: "О компании", Content: "Компания основана в 2020 году."}))
+
+	ask := func(query string) *ai.GenerateResult {
+		result, err := service.SearchAndGenerateWithOptions(context.Background(), query, 5, 0.1, ai.PromptOptions{})
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.False(t, ask("офис").Metrics.FromCache)
+	assert.False(t, ask("компания").Metrics.FromCache)
+	assert.True(t, ask("офис").Metrics.FromCache)
+
+	// Обновление другого документа не затрагивает ответ про офис
+	require.NoError(t, service.IndexDocument(domain.Document{ID: "company", Title: "О компании", Content: "Компания основана в 2021 году."}))
+	assert.True(t, ask("офис").Metrics.FromCache)
+
+	assert.Equal(t, 1, client.GetCacheStats().Entries, "Запись по обновленному документу должна быть удалена")
+
+	// Удаление документа сбрасывает ответы, основанные на нем
+	require.NoError(t, service.DeleteDocument("contacts"))
+	assert.Zero(t, client.GetCacheStats().Entries)
+}
+
+// TestIndexDocumentUpdatesExisting проверяет, что повторная индексация заменяет документ, а не падает на ограничении ключа
+func TestIndexDocumentUpdatesExisting(t *testing.T) {
+	repo := newTestRepository(t, "")
+	service := application.NewRAGService(repo, nil)
+
+	require.NoError(t, service.IndexDocument(domain.Document{ID: "company", Title: "О компании", Content: "Компания основана в 2020 году."}))
+	require.NoError(t, service.IndexDocument(domain.Document{ID: "company", Title: "О компании", Content: "Компания основана в 2021 году."}))
+
+	doc, err := repo.GetDocument("company")
+	require.NoError(t, err)
+	assert.Equal(t, "Компания основана в 2021 году.", doc.Content)
+
+	chunks, err := repo.GetChunks("company")
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "Компания основана в 2021 году.", chunks[0].Content)
+}
+
+// TestCacheRestoresPIIPerRequest проверяет, что запросы с разными персональными данными не получают чужой ответ из кэша
+func TestCacheRestoresPIIPerRequest(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Пишите на [EMAIL_1]"}, &requests)
+	defer server.Close()
+
+	config := newTestConfig(server.URL)
+	config.Privacy.Redaction.Enabled = true
+	client := newTestClient(t, config)
+
+	ask := func(email string) *ai.GenerateResult {
+		result, err := client.Generate(context.Background(), ai.GenerateRequest{
+			Query:  "Какой email?",
+			Chunks: []domain.Chunk{{ID: "c", DocumentID: "contacts", Content: "Email: " + email}},
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.Equal(t, "Пишите на a@example.com", ask("a@example.com").Text)
+	second := ask("b@example.com")
+	assert.Equal(t, "Пишите на b@example.com", second.Text)
+	assert.False(t, second.Metrics.FromCache, "Хеш содержимого фрагмента входит в ключ")
+}
+
+// openCacheBackends открывает все хранилища кэша с одинаковыми ограничениями во временной директории
+func openCacheBackends(t *testing.T, cfg cache.Config) map[string]func() cache.Cache {
+	dir := t.TempDir()
+	open := func(backend string) func() cache.Cache {
+		return func() cache.Cache {
+			backendCfg := cfg
+			backendCfg.Backend = backend
+			backendCfg.Dir = filepath.Join(dir, "files")
+			backendCfg.Path = filepath.Join(dir, "cache.db")
+			c, err := cache.New(backendCfg)
+			require.NoError(t, err)
+			return c
+		}
+	}
+	return map[string]func() cache.Cache{
+		cache.BackendFile:   open(cache.BackendFile),
+		cache.BackendMemory: open(cache.BackendMemory),
+		cache.BackendSQLite: open(cache.BackendSQLite),
+	}
+}
+
+// TestCacheBackendsEviction проверяет LRU вытеснение по количеству и размеру записей и статистику
+func TestCacheBackendsEviction(t *testing.T) {
+	for name, open := range openCacheBackends(t, cache.Config{MaxEntries: 2, MaxBytes: 10}) {
+		t.Run(name, func(t *testing.T) {
+			c := open()
+			defer c.Close()
+
+			require.NoError(t, c.Set("a", []byte("111"), nil))
+			require.NoError(t, c.Set("b", []byte("222"), nil))
+			_, ok := c.Get("a") // a становится последней использованной
+			require.True(t, ok)
+			require.NoError(t, c.Set("c", []byte("333"), nil))
+
+			_, ok = c.Get("b")
+			assert.False(t, ok, "Вытесняется давно не использованная запись")
+			value, ok := c.Get("a")
+			assert.True(t, ok)
+			assert.Equal(t, "111", string(value))
+
+			// Запись, превышающая max_bytes, не сохраняется; вытеснение по суммарному размеру
+			require.NoError(t, c.Set("huge", []byte("12345678901"), nil))
+			_, ok = c.Get("huge")
+			assert.False(t, ok)
+			require.NoError(t, c.Set("d", []byte("44444444"), nil))
+
+			stats := c.Stats()
+			assert.Equal(t, name, stats.Backend)
+			assert.Equal(t, 1, stats.Entries)
+			assert.Equal(t, int64(8), stats.Bytes)
+			assert.Equal(t, int64(3), stats.Evictions)
+			assert.Equal(t, int64(2), stats.Hits)
+			assert.Equal(t, int64(2), stats.Misses)
+			assert.InDelta(t, 0.5, stats.HitRate(), 0.001)
+		})
+	}
+}
+
+// TestCacheBackendsTTLAndTags проверяет истечение записей и удаление по тегам
+func TestCacheBackendsTTLAndTags(t *testing.T) {
+	for name, open := range openCacheBackends(t, cache.Config{TTL: 100 * time.Millisecond}) {
+		t.Run(name, func(t *testing.T) {
+			c := open()
+			defer c.Close()
+
+			require.NoError(t, c.Set("office", []byte("Москва"), []string{"contacts"}))
+			require.NoError(t, c.Set("overview", []byte("О компании"), []string{"company", "contacts"}))
+			require.NoError(t, c.Set("year", []byte("2020"), []string{"company"}))
+
+			removed, err := c.InvalidateTags("contacts")
+			require.NoError(t, err)
+			assert.Equal(t, 2, removed)
+			_, ok := c.Get("office")
+			assert.False(t, ok)
+			_, ok = c.Get("year")
+			assert.True(t, ok)
+
+			time.Sleep(150 * time.Millisecond)
+			_, ok = c.Get("year")
+			assert.False(t, ok, "Запись должна истечь по TTL")
+			assert.Equal(t, int64(1), c.Stats().Expirations)
+			assert.Zero(t, c.Stats().Entries)
+		})
+	}
+}
+
+// TestCacheBackendsPersistence проверяет, что записи и порядок использования переживают повторное открытие
+func TestCacheBackendsPersistence(t *testing.T) {
+	backends := openCacheBackends(t, cache.Config{MaxEntries: 2})
+	for _, name := range []string{cache.BackendFile, cache.BackendSQLite} {
+		t.Run(name, func(t *testing.T) {
+			c := backends[name]()
+			require.NoError(t, c.Set("old", []byte("1"), []string{"doc"}))
+			time.Sleep(10 * time.Millisecond)
+			require.NoError(t, c.Set("new", []byte("2"), nil))
+			require.NoError(t, c.Close())
+
+			reopened := backends[name]()
+			defer reopened.Close()
+			assert.Equal(t, 2, reopened.Stats().Entries)
+
+			require.NoError(t, reopened.Set("newest", []byte("3"), nil))
+			_, ok := reopened.Get("old")
+			assert.False(t, ok, "После перезапуска вытесняется давно не использованная запись")
+			value, ok := reopened.Get("new")
+			assert.True(t, ok)
+			assert.Equal(t, "2", string(value))
+
+			require.NoError(t, reopened.Clear())
+			assert.Zero(t, reopened.Stats().Entries)
+		})
+	}
+
+	_, err := cache.New(cache.Config{Backend: "redis"})
+	assert.Error(t, err)
+}
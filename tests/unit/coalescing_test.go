@@ -0,0 +1,146 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/infrastructure/ai"
+)
+
+// newBlockingServer создает фейковую модель, которая отвечает только после закрытия release.
+// В канал started отправляется контекст каждого полученного запроса.
+func newBlockingServer(release <-chan struct{}, started chan<- context.Context, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		started <- r.Context()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": "Офис в Москве"}}},
+		})
+	}))
+}
+
+// TestConcurrentIdenticalRequestsShareCall проверяет, что одновременные одинаковые запросы делают один вызов API
+func TestConcurrentIdenticalRequestsShareCall(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan context.Context, 10)
+	var calls int32
+	server := newBlockingServer(release, started, &calls)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL))
+	req := ai.GenerateRequest{Query: "Где офис?", Chunks: groundingChunks[1:]}
+
+	const concurrent = 20
+	results := make([]*ai.GenerateResult, concurrent)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrent; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := client.Generate(context.Background(), req)
+			assert.NoError(t, err)
+			results[i] = result
+		}(i)
+	}
+
+	<-started
+	time.Sleep(100 * time.Millisecond) // Даем остальным запросам присоединиться к вызову
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	coalesced := 0
+	for _, result := range results {
+		require.NotNil(t, result)
+		assert.Equal(t, "Офис в Москве", result.Text)
+		if result.Metrics.Coalesced {
+			coalesced++
+		}
+	}
+	assert.Equal(t, concurrent-1, coalesced)
+}
+
+// TestCoalescedWaitersRespectCancellation проверяет, что отмена одного ожидающего не влияет на остальных,
+// а вызов API отменяется, когда ждать результата некому
+func TestCoalescedWaitersRespectCancellation(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan context.Context, 10)
+	var calls int32
+	server := newBlockingServer(release, started, &calls)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL))
+	req := ai.GenerateRequest{Query: "Где офис?", Chunks: groundingChunks[1:]}
+
+	// Первый запрос отменяется, второй дожидается ответа
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := client.Generate(ctx, req)
+		firstErr <- err
+	}()
+	<-started
+
+	secondResult := make(chan *ai.GenerateResult, 1)
+	go func() {
+		result, err := client.Generate(context.Background(), req)
+		assert.NoError(t, err)
+		secondResult <- result
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+
+	close(release)
+	result := <-secondResult
+	require.NotNil(t, result)
+	assert.Equal(t, "Офис в Москве", result.Text)
+	assert.True(t, result.Metrics.Coalesced)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Единственный ожидающий отменяет запрос - вызов отменяется, и следующий запрос делает новый вызов,
+	// а не присоединяется к отмененному
+	require.NoError(t, client.ClearCache())
+	release2 := make(chan struct{})
+	server2 := newBlockingServer(release2, started, &calls)
+	defer server2.Close()
+	client2 := newTestClient(t, newTestConfig(server2.URL))
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := client2.Generate(ctx2, req)
+		done <- err
+	}()
+	<-started
+	cancel2()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	retried := make(chan *ai.GenerateResult, 1)
+	go func() {
+		result, err := client2.Generate(context.Background(), req)
+		assert.NoError(t, err)
+		retried <- result
+	}()
+	<-started
+	close(release2)
+	result = <-retried
+	require.NotNil(t, result)
+	assert.Equal(t, "Офис в Москве", result.Text)
+	assert.False(t, result.Metrics.Coalesced)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,140 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/config"
+)
+
+// writeConfigFile записывает YAML конфигурацию во временную директорию
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+// TestConfigDefaultsAndFileOverlay проверяет, что файл переопределяет только указанные поля
+func TestConfigDefaultsAndFileOverlay(t *testing.T) {
+	path := writeConfigFile(t, `
+retrieval:
+  limit: 8
+cache:
+  backend: memory
+  ttl: 1h
+`)
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 8, cfg.Retrieval.Limit)
+	assert.Equal(t, 0.1, cfg.Retrieval.Threshold)
+	assert.Equal(t, "./rag_system.db", cfg.Storage.DBPath)
+	assert.Equal(t, 500, cfg.Chunking.Size)
+	assert.Equal(t, "memory", cfg.Cache.Backend)
+	assert.Equal(t, time.Hour, cfg.Cache.TTL)
+	assert.Equal(t, 3, cfg.AI.MaxRetries)
+	assert.Equal(t, 2*time.Second, cfg.AI.RetryDelay)
+}
+
+// TestConfigExplicitZeroKept проверяет, что явный 0 в файле не заменяется значением по умолчанию
+func TestConfigExplicitZeroKept(t *testing.T) {
+	cfg, err := config.Load(writeConfigFile(t, `
+ai:
+  max_retries: 0
+  retry_delay: 0s
+  structured_retries: 0
+grounding:
+  threshold: 0
+`))
+	require.NoError(t, err)
+
+	assert.Zero(t, cfg.AI.MaxRetries)
+	assert.Zero(t, cfg.AI.RetryDelay)
+	assert.Zero(t, cfg.AI.StructuredRetries)
+	assert.Equal(t, 2, config.Default().AI.StructuredRetries)
+	assert.Zero(t, cfg.Grounding.Threshold)
+	assert.Equal(t, 0.5, config.Default().Grounding.Threshold)
+}
+
+// TestConfigApplyEnv проверяет переопределение полей разных типов переменными окружения RAG_*
+func TestConfigApplyEnv(t *testing.T) {
+	cfg := config.Default()
+	err := config.ApplyEnv(&cfg, []string{
+		"RAG_RETRIEVAL_LIMIT=12",
+		"RAG_RETRIEVAL_THRESHOLD=0.25",
+		"RAG_CACHE_TTL=30m",
+		"RAG_SECURITY_FENCE_CONTEXT=false",
+		"RAG_PRIVACY_REDACTION_TYPES=email, phone",
+		"RAG_AI_MODEL=test-model",
+		"UNRELATED=1",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 12, cfg.Retrieval.Limit)
+	assert.Equal(t, 0.25, cfg.Retrieval.Threshold)
+	assert.Equal(t, 30*time.Minute, cfg.Cache.TTL)
+	assert.False(t, cfg.Security.FenceContext)
+	assert.Equal(t, []string{"email", "phone"}, cfg.Privacy.Redaction.Types)
+	assert.Equal(t, "test-model", cfg.AI.Model)
+}
+
+// TestConfigApplyEnvInvalidValue проверяет, что ошибка называет переменную окружения
+func TestConfigApplyEnvInvalidValue(t *testing.T) {
+	cases := map[string]string{
+		"RAG_RETRIEVAL_LIMIT":        "много",
+		"RAG_CACHE_TTL":              "10",
+		"RAG_SEMANTIC_CACHE_ENABLED": "да",
+	}
+	for name, value := range cases {
+		cfg := config.Default()
+		err := config.ApplyEnv(&cfg, []string{name + "=" + value})
+		require.Error(t, err, name)
+		assert.Contains(t, err.Error(), name)
+	}
+}
+
+// TestConfigLegacyEnv проверяет поддержку старых имен переменных и приоритет RAG_*
+func TestConfigLegacyEnv(t *testing.T) {
+	cfg := config.Default()
+	require.NoError(t, config.ApplyEnv(&cfg, []string{"AI_API_KEY=legacy-key", "AI_MODEL=legacy-model"}))
+	assert.Equal(t, "legacy-key", cfg.AI.APIKey)
+	assert.Equal(t, "legacy-model", cfg.AI.Model)
+
+	cfg = config.Default()
+	require.NoError(t, config.ApplyEnv(&cfg, []string{"AI_API_KEY=legacy-key", "RAG_AI_API_KEY=new-key"}))
+	assert.Equal(t, "new-key", cfg.AI.APIKey)
+}
+
+// TestConfigSetOverridesEnv проверяет, что флаги (Set) применяются поверх окружения
+func TestConfigSetOverridesEnv(t *testing.T) {
+	cfg := config.Default()
+	require.NoError(t, config.ApplyEnv(&cfg, []string{"RAG_RETRIEVAL_LIMIT=12"}))
+	require.NoError(t, cfg.Set("retrieval.limit", "3"))
+	assert.Equal(t, 3, cfg.Retrieval.Limit)
+
+	assert.Error(t, cfg.Set("retrieval.unknown", "1"))
+	assert.Error(t, cfg.Set("retrieval.threshold", "abc"))
+}
+
+// TestConfigMaskedHidesSecrets проверяет, что вывод конфигурации не содержит API ключ
+func TestConfigMaskedHidesSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.AI.APIKey = "sk-very-secret"
+
+	out, err := cfg.Masked().YAML()
+	require.NoError(t, err)
+	assert.NotContains(t, out, "sk-very-secret")
+	assert.Contains(t, out, "api_key: '********'")
+	assert.Equal(t, "sk-very-secret", cfg.AI.APIKey, "исходная конфигурация не должна меняться")
+}
+
+// TestConfigEnvName проверяет формирование имен переменных окружения
+func TestConfigEnvName(t *testing.T) {
+	assert.Equal(t, "RAG_AI_BASE_URL", config.EnvName("ai.base_url"))
+	assert.Equal(t, "RAG_SEMANTIC_CACHE_MIN_CHUNK_OVERLAP", config.EnvName("semantic_cache.min_chunk_overlap"))
+}
@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/config"
+)
+
+// problemStrings возвращает проблемы из ошибки валидации в виде строк
+func problemStrings(t *testing.T, err error) []string {
+	var validationErr *config.ValidationError
+	require.True(t, errors.As(err, &validationErr), "ожидается *config.ValidationError, получено: %v", err)
+	lines := make([]string, len(validationErr.Problems))
+	for i, p := range validationErr.Problems {
+		lines[i] = p.String()
+	}
+	return lines
+}
+
+// TestConfigStrictDecoding проверяет, что неизвестные ключи и ошибки типов сообщаются все сразу с номерами строк
+func TestConfigStrictDecoding(t *testing.T) {
+	path := writeConfigFile(t, `ai:
+  max_token: 100
+  timeout: тридцать
+cache:
+  backend: memory
+loging:
+  level: debug
+`)
+
+	cfg, err := config.Load(path)
+	problems := problemStrings(t, err)
+	require.Len(t, problems, 3)
+	assert.Equal(t, path+`:2: ai.max_token: неизвестный ключ "max_token" (возможно, max_tokens)`, problems[0])
+	assert.Contains(t, problems[1], path+":3: ai.timeout: некорректное значение")
+	assert.Equal(t, path+`:6: loging: неизвестный ключ "loging" (возможно, logging)`, problems[2])
+
+	// Корректные поля все равно применяются
+	assert.Equal(t, "memory", cfg.Cache.Backend)
+}
+
+// TestConfigValidateReportsAllProblems проверяет, что валидатор возвращает все проблемы с источником значения
+func TestConfigValidateReportsAllProblems(t *testing.T) {
+	path := writeConfigFile(t, `ai:
+  base_url: "https://api.example.com/v1"
+  api_key: "key"
+  model: "model"
+  max_tokens: 0
+  temperature: 3
+grounding:
+  mode: strict
+`)
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	require.NoError(t, config.ApplyEnv(&cfg, []string{"RAG_RETRIEVAL_LIMIT=-1"}))
+
+	problems := problemStrings(t, cfg.Validate())
+	assert.Equal(t, []string{
+		"RAG_RETRIEVAL_LIMIT: retrieval.limit: должно быть положительным, текущее значение: -1",
+		path + ":5: ai.max_tokens: должно быть положительным, текущее значение: 0",
+		path + ":6: ai.temperature: должно быть в диапазоне [0, 2], текущее значение: 3",
+		path + `:8: grounding.mode: недопустимое значение "strict" (допустимо: off, lexical, llm)`,
+	}, problems)
+
+	// Проверка отдельных секций
+	assert.NoError(t, cfg.Validate("storage", "cache"))
+	assert.Len(t, problemStrings(t, cfg.Validate("ai")), 2)
+}
+
+// TestConfigValidateDefaults проверяет, что значения по умолчанию с заданным AI API проходят проверку
+func TestConfigValidateDefaults(t *testing.T) {
+	cfg := config.Default()
+	problems := problemStrings(t, cfg.Validate())
+	assert.Len(t, problems, 3) // ai.base_url, ai.api_key, ai.model
+
+	cfg.AI.BaseURL = "https://api.example.com/v1"
+	cfg.AI.APIKey = "key"
+	cfg.AI.Model = "model"
+	assert.NoError(t, cfg.Validate())
+
+	// 0 отключает повторы, отрицательные значения ошибочны
+	cfg.AI.StructuredRetries = 0
+	assert.NoError(t, cfg.Validate("ai"))
+	cfg.AI.StructuredRetries = -1
+	assert.Equal(t, []string{
+		"ai.structured_retries: не может быть отрицательным, текущее значение: -1",
+	}, problemStrings(t, cfg.Validate("ai")))
+}
+
+// TestConfigValidateTracing проверяет секцию tracing: экспортер и его адрес проверяются только при включенной трассировке
+func TestConfigValidateTracing(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tracing.Exporter = "zipkin"
+	cfg.Tracing.SampleRatio = 2
+	assert.Equal(t, []string{
+		"tracing.sample_ratio: должно быть в диапазоне [0, 1], текущее значение: 2",
+	}, problemStrings(t, cfg.Validate("tracing")))
+
+	cfg.Tracing.SampleRatio = 0.5
+	cfg.Tracing.Enabled = true
+	assert.Equal(t, []string{
+		`tracing.exporter: недопустимое значение "zipkin" (допустимо: file, otlp)`,
+	}, problemStrings(t, cfg.Validate("tracing")))
+
+	cfg.Tracing.Exporter = "otlp"
+	cfg.Tracing.Endpoint = "localhost:4318"
+	assert.Len(t, problemStrings(t, cfg.Validate("tracing")), 1)
+
+	cfg.Tracing.Endpoint = "http://localhost:4318"
+	assert.NoError(t, cfg.Validate("tracing"))
+}
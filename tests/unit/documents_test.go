@@ -0,0 +1,179 @@
+package unit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure"
+)
+
+// newDocumentsRepository создает репозиторий с фрагментами по 20 байт и документами разного размера
+func newDocumentsRepository(t *testing.T) *infrastructure.SQLiteDocumentRepository {
+	repo := newTestRepository(t, "", withChunkSize(20))
+	for i, title := range []string{"Гамма", "Альфа", "Дельта", "Бета", "Эпсилон"} {
+		require.NoError(t, repo.SaveDocument(domain.Document{
+			ID:      fmt.Sprintf("docs/%d.txt", i+1),
+			Title:   title,
+			Content: strings.Repeat("слово ", i+1),
+		}))
+	}
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "notes.md", Title: "Заметки", Content: "коротко"}))
+	return repo
+}
+
+// listIDs обходит все страницы списка и возвращает ID документов
+func listIDs(t *testing.T, repo *infrastructure.SQLiteDocumentRepository, filter domain.DocumentFilter, limit int) []string {
+	var ids []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 10, "курсор не должен зацикливаться")
+		page, err := repo.ListDocuments(filter, cursor, limit)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Documents), limit)
+		for _, doc := range page.Documents {
+			ids = append(ids, doc.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// TestListDocumentsPaging проверяет сортировку и постраничный обход списка документов по курсору
+func TestListDocumentsPaging(t *testing.T) {
+	repo := newDocumentsRepository(t)
+
+	assert.Equal(t, []string{"docs/1.txt", "docs/2.txt", "docs/3.txt", "docs/4.txt", "docs/5.txt", "notes.md"},
+		listIDs(t, repo, domain.DocumentFilter{}, 4))
+	assert.Equal(t, []string{"docs/2.txt", "docs/4.txt", "docs/1.txt", "docs/3.txt", "notes.md", "docs/5.txt"},
+		listIDs(t, repo, domain.DocumentFilter{Sort: domain.SortByTitle}, 2))
+	assert.Equal(t, []string{"docs/5.txt", "docs/4.txt", "docs/3.txt", "docs/2.txt", "notes.md", "docs/1.txt"},
+		listIDs(t, repo, domain.DocumentFilter{Sort: domain.SortBySize, Desc: true}, 4))
+
+	// Документы с одинаковым числом фрагментов упорядочиваются по ID
+	assert.Equal(t, []string{"docs/1.txt", "notes.md", "docs/2.txt", "docs/3.txt", "docs/4.txt", "docs/5.txt"},
+		listIDs(t, repo, domain.DocumentFilter{Sort: domain.SortByChunks}, 1))
+	assert.Len(t, listIDs(t, repo, domain.DocumentFilter{Sort: domain.SortByCreated}, 5), 6)
+
+	page, err := repo.ListDocuments(domain.DocumentFilter{Sort: domain.SortBySize}, "", 1)
+	require.NoError(t, err)
+	require.Len(t, page.Documents, 1)
+	assert.Equal(t, domain.DocumentSummary{ID: "docs/1.txt", Title: "Гамма", CreatedAt: page.Documents[0].CreatedAt,
+		Size: len("слово "), Chunks: 1}, page.Documents[0])
+	assert.False(t, page.Documents[0].CreatedAt.IsZero(), "время создания должно быть заполнено")
+
+	_, err = repo.ListDocuments(domain.DocumentFilter{Sort: "content"}, "", 10)
+	assert.ErrorContains(t, err, "неизвестное поле сортировки")
+	_, err = repo.ListDocuments(domain.DocumentFilter{}, "не курсор", 10)
+	assert.ErrorContains(t, err, "некорректный курсор")
+}
+
+// TestShowAndMatchDocuments проверяет получение документа с фрагментами, поиск ID по шаблону и статистику индекса
+func TestShowAndMatchDocuments(t *testing.T) {
+	repo := newDocumentsRepository(t)
+
+	doc, err := repo.GetDocument("docs/3.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "Дельта", doc.Title)
+	assert.Equal(t, strings.Repeat("слово ", 3), doc.Content)
+	assert.False(t, doc.CreatedAt.IsZero())
+
+	chunks, err := repo.GetChunks("docs/3.txt")
+	require.NoError(t, err)
+	require.NotEmpty(t, chunks)
+	var content strings.Builder
+	for i, chunk := range chunks {
+		assert.Equal(t, fmt.Sprintf("docs/3.txt_chunk_%d", i), chunk.ID)
+		assert.Equal(t, "docs/3.txt", chunk.DocumentID)
+		content.WriteString(chunk.Content)
+	}
+	assert.Equal(t, strings.Join(strings.Fields(doc.Content), ""), strings.Join(strings.Fields(content.String()), ""))
+
+	_, err = repo.GetDocument("missing")
+	assert.True(t, errors.Is(err, domain.ErrDocumentNotFound))
+	chunks, err = repo.GetChunks("missing")
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+
+	ids, err := repo.MatchDocumentIDs("docs/*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs/1.txt", "docs/2.txt", "docs/3.txt", "docs/4.txt", "docs/5.txt"}, ids)
+	ids, err = repo.MatchDocumentIDs("docs/[13].txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs/1.txt", "docs/3.txt"}, ids)
+
+	stats, err := repo.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 6, stats.Documents)
+	assert.Greater(t, stats.Chunks, 6)
+	assert.Greater(t, stats.AvgChunkSize, float64(0))
+	assert.LessOrEqual(t, stats.AvgChunkSize, float64(20))
+	assert.Greater(t, stats.Bytes, int64(0))
+}
+
+// TestListDocumentsFilter проверяет отбор документов по префиксу ID, названию и времени создания
+func TestListDocumentsFilter(t *testing.T) {
+	repo := newDocumentsRepository(t)
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "archive/old.txt", Title: "Старая бета", Content: "давно", CreatedAt: old}))
+
+	assert.Equal(t, []string{"docs/1.txt", "docs/2.txt", "docs/3.txt", "docs/4.txt", "docs/5.txt"},
+		listIDs(t, repo, domain.DocumentFilter{IDPrefix: "docs/"}, 2))
+	assert.Equal(t, []string{"archive/old.txt", "docs/4.txt"}, listIDs(t, repo, domain.DocumentFilter{Title: "ета"}, 1))
+	assert.Equal(t, []string{"archive/old.txt"}, listIDs(t, repo, domain.DocumentFilter{CreatedBefore: old.Add(time.Second)}, 5))
+	assert.Len(t, listIDs(t, repo, domain.DocumentFilter{CreatedAfter: old.Add(time.Second)}, 5), 6)
+
+	page, err := repo.ListDocuments(domain.DocumentFilter{IDPrefix: "docs/"}, "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 5, page.Total)
+	_, err = repo.ListDocuments(domain.DocumentFilter{Sort: domain.SortByTitle}, page.NextCursor, 2)
+	assert.ErrorContains(t, err, "курсор получен для сортировки")
+
+	docs, err := repo.GetAllDocuments()
+	require.NoError(t, err)
+	for _, doc := range docs {
+		if doc.ID == "archive/old.txt" {
+			assert.True(t, old.Equal(doc.CreatedAt), "время создания должно сохраняться: %v", doc.CreatedAt)
+		} else {
+			assert.False(t, doc.CreatedAt.IsZero(), "время создания %s должно быть заполнено", doc.ID)
+		}
+	}
+}
+
+// TestUpdateDocument проверяет замену содержимого с перестроением фрагментов и сохранением времени создания
+func TestUpdateDocument(t *testing.T) {
+	repo := newDocumentsRepository(t)
+	before, err := repo.GetDocument("docs/5.txt")
+	require.NoError(t, err)
+
+	content := strings.Repeat("новый текст ", 4)
+	require.NoError(t, repo.UpdateDocument(domain.Document{ID: "docs/5.txt", Title: "Омега", Content: content}))
+
+	after, err := repo.GetDocument("docs/5.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "Омега", after.Title)
+	assert.Equal(t, content, after.Content)
+	assert.True(t, before.CreatedAt.Equal(after.CreatedAt))
+
+	chunks, err := repo.GetChunks("docs/5.txt")
+	require.NoError(t, err)
+	require.NotEmpty(t, chunks)
+	for _, chunk := range chunks {
+		assert.NotContains(t, chunk.Content, "слово", "старые фрагменты должны быть удалены")
+	}
+	found, err := repo.FindRelevantChunks("новый", 10, 0)
+	require.NoError(t, err)
+	assert.NotEmpty(t, found)
+
+	err = repo.UpdateDocument(domain.Document{ID: "missing", Content: "текст"})
+	assert.True(t, errors.Is(err, domain.ErrDocumentNotFound))
+	_, err = repo.GetDocument("missing")
+	assert.True(t, errors.Is(err, domain.ErrDocumentNotFound), "обновление не должно создавать документ")
+}
@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure"
+)
+
+// requireFTS5 пропускает тест, если SQLite собран без FTS5
+func requireFTS5(t *testing.T, repo *infrastructure.SQLiteDocumentRepository) {
+	stats, err := repo.Stats()
+	require.NoError(t, err)
+	if !stats.FTS5 {
+		t.Skip("SQLite собран без FTS5, запустите go test -tags sqlite_fts5")
+	}
+}
+
+// searchDocuments возвращает ID документов, найденных по запросу
+func searchDocuments(t *testing.T, repo *infrastructure.SQLiteDocumentRepository, query string) []string {
+	chunks, err := repo.FindRelevantChunks(query, 10, 0)
+	require.NoError(t, err)
+	ids := []string{}
+	for _, chunk := range chunks {
+		ids = append(ids, chunk.DocumentID)
+	}
+	return ids
+}
+
+// assertIndexOK проверяет, что fsck не находит расхождений
+func assertIndexOK(t *testing.T, repo *infrastructure.SQLiteDocumentRepository) domain.IndexCheck {
+	check, err := repo.CheckIndex()
+	require.NoError(t, err)
+	assert.True(t, check.OK, "индекс должен быть согласован: %+v", check)
+	return check
+}
+
+// TestFTSIndexDeleteAndUpdate проверяет, что после удаления и изменения документов старый текст не находится
+func TestFTSIndexDeleteAndUpdate(t *testing.T) {
+	repo := newTestRepository(t, "")
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "office", Title: "Офис", Content: "Главный офис находится в Москве."}))
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "support", Title: "Поддержка", Content: "Поддержка работает круглосуточно."}))
+	assert.Equal(t, []string{"office"}, searchDocuments(t, repo, "Москве"))
+
+	require.NoError(t, repo.DeleteDocument("office"))
+	assert.Empty(t, searchDocuments(t, repo, "Москве"))
+
+	require.NoError(t, repo.UpdateDocument(domain.Document{ID: "support", Title: "Поддержка", Content: "Поддержка работает по будням."}))
+	assert.Empty(t, searchDocuments(t, repo, "круглосуточно"))
+	assert.Equal(t, []string{"support"}, searchDocuments(t, repo, "будням"))
+
+	// Новый документ может получить rowid удаленного фрагмента: старые термы не должны к нему относиться
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "office", Title: "Офис", Content: "Офис переехал в Казань."}))
+	assert.Empty(t, searchDocuments(t, repo, "Москве"))
+	assert.Equal(t, []string{"office"}, searchDocuments(t, repo, "Казань"))
+
+	check := assertIndexOK(t, repo)
+	assert.Equal(t, 2, check.Chunks)
+	if check.FTS5 {
+		assert.Equal(t, 2, check.Indexed)
+	}
+}
+
+// TestFTSIndexCheckAndRebuild проверяет обнаружение расхождений индекса и их исправление перестроением
+func TestFTSIndexCheckAndRebuild(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rag.db")
+	repo := newTestRepository(t, path)
+	requireFTS5(t, repo)
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "office", Title: "Офис", Content: "Главный офис находится в Москве."}))
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Запись индекса без фрагмента и фрагмент, добавленный в обход триггеров
+	_, err = db.Exec("INSERT INTO chunks_fts(rowid, content) VALUES (1000, 'призрак')")
+	require.NoError(t, err)
+	_, err = db.Exec("DROP TRIGGER chunks_fts_insert")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO chunks (id, document_id, content) VALUES ('office_chunk_1', 'office', 'Склад в Туле.')")
+	require.NoError(t, err)
+
+	check, err := repo.CheckIndex()
+	require.NoError(t, err)
+	assert.False(t, check.OK)
+	assert.Equal(t, []int64{1000}, check.Stale)
+	assert.Len(t, check.Missing, 1)
+	assert.NotEmpty(t, check.Integrity)
+	assert.Empty(t, searchDocuments(t, repo, "Туле"))
+
+	require.NoError(t, repo.RebuildIndex())
+	assertIndexOK(t, repo)
+	assert.Equal(t, []string{"office"}, searchDocuments(t, repo, "Туле"))
+	assert.Empty(t, searchDocuments(t, repo, "призрак"))
+
+	// При следующем открытии недостающий триггер восстанавливается
+	require.NoError(t, repo.Close())
+	repo = newTestRepository(t, path)
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "stock", Title: "Склад", Content: "Второй склад в Рязани."}))
+	assert.Equal(t, []string{"stock"}, searchDocuments(t, repo, "Рязани"))
+	assertIndexOK(t, repo)
+}
+
+// TestFTSIndexTokenlessChunks проверяет, что фрагменты без индексируемых слов не считаются отсутствующими в индексе
+func TestFTSIndexTokenlessChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rag.db")
+	repo := newTestRepository(t, path)
+	requireFTS5(t, repo)
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "office", Title: "Офис", Content: "Главный офис находится в Москве."}))
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("INSERT INTO chunks (id, document_id, content) VALUES ('office_chunk_1', 'office', '— … !!! ***')")
+	require.NoError(t, err)
+
+	check := assertIndexOK(t, repo)
+	assert.Equal(t, 2, check.Chunks)
+	assert.Equal(t, 1, check.Indexed)
+}
+
+// TestFTSIndexLegacyTriggers проверяет исправление базы с прежними триггерами, оставлявшими устаревшие записи
+func TestFTSIndexLegacyTriggers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rag.db")
+	repo := newTestRepository(t, path)
+	requireFTS5(t, repo)
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	for _, stmt := range []string{
+		"DROP TRIGGER chunks_fts_delete",
+		`CREATE TRIGGER chunks_fts_delete AFTER DELETE ON chunks BEGIN
+			DELETE FROM chunks_fts WHERE rowid = old.rowid;
+		END`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "office", Title: "Офис", Content: "Главный офис находится в Москве."}))
+	require.NoError(t, repo.DeleteDocument("office"))
+	check, err := repo.CheckIndex()
+	require.NoError(t, err)
+	assert.False(t, check.OK, "прежний триггер удаления оставляет запись в индексе")
+
+	// Открытие базы заменяет триггеры и перестраивает индекс
+	require.NoError(t, repo.Close())
+	repo = newTestRepository(t, path)
+	assertIndexOK(t, repo)
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "support", Title: "Поддержка", Content: "Поддержка работает круглосуточно."}))
+	require.NoError(t, repo.DeleteDocument("support"))
+	assert.Empty(t, searchDocuments(t, repo, "круглосуточно"))
+	assertIndexOK(t, repo)
+}
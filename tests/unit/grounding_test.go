@@ -0,0 +1,138 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ai"
+)
+
+// groundingChunks фрагменты, на которые должны опираться ответы в тестах обоснованности
+var groundingChunks = []domain.Chunk{
+	{ID: "company_chunk_0", DocumentID: "company", Content: "Наша компания была основана в 2020 году. Мы специализируемся на разработке программного обеспечения."},
+	{ID: "contacts_chunk_0", DocumentID: "contacts", Content: "Главный офис находится в Москве, улица Тверская, 1."},
+}
+
+// withGrounding включает проверку обоснованности ответов
+func withGrounding(mode, policy string) func(*ai.Config) {
+	return func(config *ai.Config) {
+		config.Grounding.Mode = mode
+		config.Grounding.Policy = policy
+		config.Grounding.Threshold = 0.75
+	}
+}
+
+// TestSplitClaims проверяет разбиение ответа на утверждения
+func TestSplitClaims(t *testing.T) {
+	claims := ai.SplitClaims("Компания основана в 2020 году. Офис в Москве!\n\n- Разработка ПО\n2) Анализ данных\n…")
+	assert.Equal(t, []string{
+		"Компания основана в 2020 году.",
+		"Офис в Москве!",
+		"Разработка ПО",
+		"Анализ данных",
+	}, claims)
+
+	assert.Empty(t, ai.SplitClaims("  \n- \n"))
+}
+
+// TestGroundingLexical проверяет пометку неподтвержденных утверждений в режиме lexical
+func TestGroundingLexical(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{
+		"Компания была основана в 2020 году. Главный офис находится в Москве. У компании 5000 сотрудников в Берлине.",
+	}, &requests)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL, withGrounding(ai.GroundingModeLexical, ai.GroundingPolicyAnnotate)))
+	result, err := client.Generate(context.Background(), ai.GenerateRequest{Query: "Расскажи о компании", Chunks: groundingChunks})
+	require.NoError(t, err)
+
+	report := result.Grounding
+	require.NotNil(t, report)
+	require.Len(t, report.Claims, 3)
+	assert.Equal(t, "company_chunk_0", report.Claims[0].ChunkID)
+	assert.Equal(t, "contacts_chunk_0", report.Claims[1].ChunkID)
+	assert.Equal(t, []string{"У компании 5000 сотрудников в Берлине."}, report.Unsupported())
+	assert.InDelta(t, 2.0/3, report.Score, 0.001)
+
+	assert.Contains(t, result.Text, "Не подтверждено найденными документами")
+	assert.Contains(t, result.Text, "- У компании 5000 сотрудников в Берлине.")
+	assert.Equal(t, report.Original+"\n\n", result.Text[:len(report.Original)+2])
+	assert.False(t, report.Refused)
+}
+
+// TestGroundingRefuse проверяет отказ при ответе, не подтвержденном документами, и проверку ответа из кэша
+func TestGroundingRefuse(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Компания производит электромобили в Берлине."}, &requests)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL, withGrounding(ai.GroundingModeLexical, ai.GroundingPolicyRefuse)))
+	req := ai.GenerateRequest{Query: "Чем занимается компания?", Chunks: groundingChunks}
+
+	for i := 0; i < 2; i++ {
+		result, err := client.Generate(context.Background(), req)
+		require.NoError(t, err)
+		assert.Equal(t, ai.GroundingRefusal, result.Text)
+		require.NotNil(t, result.Grounding)
+		assert.True(t, result.Grounding.Refused)
+		assert.Equal(t, "Компания производит электромобили в Берлине.", result.Grounding.Original)
+	}
+	assert.Len(t, requests, 1, "Повторный запрос обслуживается из кэша, но тоже проверяется")
+}
+
+// TestGroundingZeroThreshold проверяет, что порог 0 принимает ответ без подтвержденных утверждений
+func TestGroundingZeroThreshold(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Компания производит электромобили в Берлине."}, &requests)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL, withGrounding(ai.GroundingModeLexical, ai.GroundingPolicyRefuse),
+		func(config *ai.Config) { config.Grounding.Threshold = 0 }))
+	result, err := client.Generate(context.Background(), ai.GenerateRequest{Query: "Чем занимается компания?", Chunks: groundingChunks})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Компания производит электромобили в Берлине.", result.Text)
+	require.NotNil(t, result.Grounding)
+	assert.Zero(t, result.Grounding.Score)
+	assert.False(t, result.Grounding.Refused)
+}
+
+// TestGroundingLLM проверяет режим llm, в котором вердикт по утверждениям выносит модель
+func TestGroundingLLM(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{
+		"Офис находится в Москве. Офис открыт круглосуточно.",
+		"```json\n{\"claims\": [{\"index\": 1, \"supported\": true, \"chunk_id\": \"contacts_chunk_0\"}, {\"index\": 2, \"supported\": false}]}\n```",
+	}, &requests)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL, withGrounding(ai.GroundingModeLLM, ai.GroundingPolicyAnnotate)))
+	result, err := client.Generate(context.Background(), ai.GenerateRequest{Query: "Где офис?", Chunks: groundingChunks})
+	require.NoError(t, err)
+
+	require.Len(t, requests, 2, "Проверка выполняется отдельным запросом к модели")
+	require.NotNil(t, result.Grounding)
+	assert.Equal(t, ai.GroundingModeLLM, result.Grounding.Mode)
+	assert.Equal(t, "contacts_chunk_0", result.Grounding.Claims[0].ChunkID)
+	assert.Equal(t, []string{"Офис открыт круглосуточно."}, result.Grounding.Unsupported())
+	assert.InDelta(t, 0.5, result.Grounding.Score, 0.001)
+	assert.Contains(t, result.Text, "- Офис открыт круглосуточно.")
+}
+
+// TestGroundingConfigValidation проверяет отказ от неизвестных режимов и политик
+func TestGroundingConfigValidation(t *testing.T) {
+	verifier, err := ai.NewGroundingVerifier(ai.GroundingModeOff, "", 0, 0)
+	require.NoError(t, err)
+	assert.Nil(t, verifier)
+
+	_, err = ai.NewGroundingVerifier("strict", "", 0.5, 0.6)
+	assert.Error(t, err)
+	_, err = ai.NewGroundingVerifier(ai.GroundingModeLexical, "block", 0.5, 0.6)
+	assert.Error(t, err)
+	_, err = ai.NewGroundingVerifier(ai.GroundingModeLexical, "", 1.5, 0.6)
+	assert.Error(t, err)
+}
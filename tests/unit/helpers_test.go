@@ -0,0 +1,75 @@
+package unit
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"rag-system/src/infrastructure"
+	"rag-system/src/infrastructure/ai"
+	"rag-system/src/infrastructure/logging"
+)
+
+// newTestConfig возвращает конфигурацию клиента с фейковой моделью по адресу baseURL и кэшем в памяти,
+// чтобы тесты не затрагивали общий каталог кэша; options дополняют ее
+func newTestConfig(baseURL string, options ...func(*ai.Config)) ai.Config {
+	config := ai.Config{}
+	config.AI.BaseURL = baseURL
+	config.AI.APIKey = "test-key"
+	config.AI.Model = "test-model"
+	config.AI.TimeoutSecs = 5
+	config.AI.MaxTokens = 100
+	config.Cache.Backend = "memory"
+	for _, option := range options {
+		option(&config)
+	}
+	return config
+}
+
+// withFileCache хранит кэш ответов в файлах каталога dir, общем для клиентов с этой конфигурацией
+func withFileCache(dir string) func(*ai.Config) {
+	return func(config *ai.Config) {
+		config.Cache.Backend = "file"
+		config.Cache.Dir = dir
+	}
+}
+
+// withJSONMode задает способ получения структурированного ответа
+func withJSONMode(mode string) func(*ai.Config) {
+	return func(config *ai.Config) {
+		config.AI.JSONMode = mode
+	}
+}
+
+// newTestClient создает AI клиент с пустым кэшем; кэш очищается и после теста
+func newTestClient(t *testing.T, config ai.Config) *ai.AIClient {
+	client, err := ai.NewAIClientFromConfig(config)
+	require.NoError(t, err)
+	require.NoError(t, client.ClearCache())
+	t.Cleanup(func() { client.ClearCache() })
+	return client
+}
+
+// newTestRepository открывает репозиторий с базой в path (пусто - новая база во временном каталоге теста)
+// и журналом без вывода; options дополняют параметры. Репозиторий закрывается после теста
+func newTestRepository(tb testing.TB, path string, options ...func(*infrastructure.RepositoryOptions)) *infrastructure.SQLiteDocumentRepository {
+	tb.Helper()
+	if path == "" {
+		path = filepath.Join(tb.TempDir(), "rag.db")
+	}
+	opts := infrastructure.RepositoryOptions{Logger: logging.Discard()}
+	for _, option := range options {
+		option(&opts)
+	}
+	repo, err := infrastructure.NewSQLiteDocumentRepositoryWithOptions(path, opts)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// withChunkSize задает размер фрагмента в байтах
+func withChunkSize(size int) func(*infrastructure.RepositoryOptions) {
+	return func(opts *infrastructure.RepositoryOptions) {
+		opts.ChunkSize = size
+	}
+}
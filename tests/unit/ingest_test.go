@@ -0,0 +1,147 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ingest"
+)
+
+// writeCorpus создает n текстовых файлов в каталоге dir
+func writeCorpus(t *testing.T, dir string, n int) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+	for i := 0; i < n; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("doc-%04d.txt", i))
+		if i%2 == 1 {
+			name = filepath.Join(dir, "sub", fmt.Sprintf("doc-%04d.md", i))
+		}
+		require.NoError(t, os.WriteFile(name, []byte(fmt.Sprintf("Документ %d про склад и офис.", i)), 0o644))
+	}
+}
+
+// TestIngestPipeline проверяет загрузку каталога, ошибки отдельных файлов и пропуск неизмененных файлов
+func TestIngestPipeline(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "corpus")
+	writeCorpus(t, corpus, 50)
+	require.NoError(t, os.WriteFile(filepath.Join(corpus, "image.png"), []byte("не текст"), 0o644))
+	broken := filepath.Join(corpus, "broken.txt")
+	require.NoError(t, os.Symlink(filepath.Join(dir, "missing.txt"), broken))
+
+	repo := newTestRepository(t, filepath.Join(dir, "rag.db"))
+	var mu sync.Mutex
+	var reports []ingest.Progress
+	opts := ingest.Options{Workers: 4, QueueSize: 2, BatchSize: 7, StatePath: filepath.Join(dir, "rag.db.ingest"),
+		Progress: func(p ingest.Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, p)
+		}}
+
+	result, err := ingest.New(repo, opts).Run(context.Background(), corpus)
+	require.NoError(t, err)
+	assert.Equal(t, 51, result.Total)
+	assert.Equal(t, 50, result.Done)
+	assert.Equal(t, 1, result.Failed)
+	assert.Len(t, result.Indexed, 50)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, broken, result.Errors[0].ID)
+	require.NotEmpty(t, reports)
+	assert.Equal(t, result.Progress, reports[len(reports)-1], "последний отчет - итог загрузки")
+
+	page, err := repo.ListDocuments(domain.DocumentFilter{}, "", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 50, page.Total)
+	doc, err := repo.GetDocument(filepath.Join(corpus, "sub", "doc-0007.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "Документ 7 про склад и офис.", doc.Content)
+
+	// Повторная загрузка пропускает неизмененные файлы и перезаписывает измененные
+	changed := filepath.Join(corpus, "doc-0000.txt")
+	require.NoError(t, os.WriteFile(changed, []byte("Новый текст"), 0o644))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(changed, later, later))
+
+	result, err = ingest.New(repo, opts).Run(context.Background(), corpus)
+	require.NoError(t, err)
+	assert.Equal(t, 49, result.Skipped)
+	assert.Equal(t, []string{changed}, result.Indexed)
+	assert.Equal(t, 1, result.Failed, "файл с ошибкой повторяется при каждом запуске")
+
+	updated, err := repo.GetDocument(changed)
+	require.NoError(t, err)
+	assert.Equal(t, "Новый текст", updated.Content)
+	chunks, err := repo.GetChunks(changed)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "Новый текст", chunks[0].Content)
+
+	// Удаленный документ загружается снова, хотя файл не изменился
+	deleted := filepath.Join(corpus, "sub", "doc-0007.md")
+	require.NoError(t, repo.DeleteDocument(deleted))
+	result, err = ingest.New(repo, opts).Run(context.Background(), corpus)
+	require.NoError(t, err)
+	assert.Equal(t, 49, result.Skipped)
+	assert.Equal(t, []string{deleted}, result.Indexed)
+	_, err = repo.GetDocument(deleted)
+	assert.NoError(t, err)
+	assertIndexOK(t, repo)
+}
+
+// TestIngestCancelAndResume проверяет остановку загрузки и ее продолжение по журналу состояния
+func TestIngestCancelAndResume(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "corpus")
+	writeCorpus(t, corpus, 1000)
+	repo := newTestRepository(t, filepath.Join(dir, "rag.db"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := ingest.Options{Workers: 2, QueueSize: 1, BatchSize: 5, StatePath: filepath.Join(dir, "state.jsonl"),
+		ProgressInterval: time.Millisecond,
+		Progress: func(p ingest.Progress) {
+			if p.Done > 0 {
+				cancel()
+			}
+		}}
+
+	first, err := ingest.New(repo, opts).Run(ctx, corpus)
+	if first.Done == first.Total {
+		t.Skip("загрузка завершилась раньше отмены")
+	}
+	require.True(t, errors.Is(err, context.Canceled), "ожидается отмена, получено %v", err)
+	assert.Greater(t, first.Done, 0)
+
+	// Все документы, отмеченные проиндексированными, сохранены целиком
+	page, err := repo.ListDocuments(domain.DocumentFilter{}, "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, first.Done, page.Total)
+
+	opts.Progress = nil
+	second, err := ingest.New(repo, opts).Run(context.Background(), corpus)
+	require.NoError(t, err)
+	assert.Equal(t, first.Done, second.Skipped)
+	assert.Equal(t, 1000-first.Done, second.Done)
+
+	page, err = repo.ListDocuments(domain.DocumentFilter{}, "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, page.Total)
+	assertIndexOK(t, repo)
+}
+
+// TestIngestProgressEstimates проверяет расчет скорости и оставшегося времени
+func TestIngestProgressEstimates(t *testing.T) {
+	p := ingest.Progress{Total: 100, Done: 20, Failed: 5, Elapsed: 5 * time.Second}
+	assert.InDelta(t, 5.0, p.Rate(), 1e-9)
+	assert.Equal(t, 15*time.Second, p.ETA())
+	assert.Zero(t, ingest.Progress{Total: 10}.ETA())
+}
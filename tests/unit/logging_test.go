@@ -0,0 +1,147 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/api"
+	"rag-system/src/application"
+	"rag-system/src/config"
+	"rag-system/src/infrastructure/ai"
+	"rag-system/src/infrastructure/logging"
+	"rag-system/tests/mocks"
+)
+
+// logRecords разбирает журнал в формате json на отдельные записи
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+// findRecord возвращает первую запись с сообщением msg
+func findRecord(records []map[string]interface{}, msg string) map[string]interface{} {
+	for _, record := range records {
+		if record["msg"] == msg {
+			return record
+		}
+	}
+	return nil
+}
+
+// TestLoggingOutput проверяет уровень, формат, маскирование секретов и request_id из контекста
+func TestLoggingOutput(t *testing.T) {
+	cfg := config.Default()
+	cfg.Logging.Level = "warn"
+	cfg.Logging.Format = "json"
+	cfg.AI.APIKey = "sk-very-secret"
+
+	var buf bytes.Buffer
+	logs := logging.New(&buf, cfg)
+	logger := logs.Logger()
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "не попадет в журнал")
+	logger.WarnContext(ctx, "ключ", "key", cfg.AI.APIKey)
+
+	records := logRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "WARN", records[0]["level"])
+	assert.Equal(t, "req-1", records[0]["request_id"])
+	assert.Equal(t, "********", records[0]["key"])
+
+	// Новая конфигурация меняет уровень и секреты уже созданного логгера
+	cfg.Logging.Level = "debug"
+	cfg.AI.APIKey = "sk-rotated"
+	logs.Apply(cfg)
+	buf.Reset()
+	logger.Debug("ключ", "key", "sk-rotated")
+	records = logRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "********", records[0]["key"])
+
+	assert.Equal(t, "info", strings.ToLower(logging.ParseLevel("unknown").String()))
+	assert.NotEqual(t, logging.NewRequestID(), logging.NewRequestID())
+}
+
+// TestAIClientLogAttributes проверяет, что метрики запроса записываются атрибутами вместе с request_id
+func TestAIClientLogAttributes(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Ответ"}, &requests)
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL)
+	cfg.Logging.Format = "json"
+	var buf bytes.Buffer
+	client, err := ai.NewAIClientWithLogger(cfg, logging.New(&buf, cfg).Logger())
+	require.NoError(t, err)
+
+	req := ai.GenerateRequest{Query: "Где офис?", Chunks: groundingChunks[1:]}
+	_, err = client.Generate(logging.WithRequestID(context.Background(), "req-ai"), req)
+	require.NoError(t, err)
+	_, err = client.Generate(context.Background(), req)
+	require.NoError(t, err)
+
+	records := logRecords(t, &buf)
+	sent := findRecord(records, "Успешный запрос к AI API")
+	require.NotNil(t, sent)
+	assert.Equal(t, "req-ai", sent["request_id"])
+	assert.Equal(t, "ai", sent["component"])
+	assert.Equal(t, float64(http.StatusOK), sent["status"])
+	assert.Equal(t, float64(0), sent["retries"])
+	assert.Equal(t, false, sent["cache"])
+	assert.Contains(t, sent, "duration")
+
+	cached := findRecord(records, "Ответ получен из кэша")
+	require.NotNil(t, cached)
+	assert.Equal(t, true, cached["cache"])
+	assert.NotEmpty(t, cached["request_id"], "идентификатор создается, если его нет в контексте")
+	assert.NotEqual(t, "req-ai", cached["request_id"])
+}
+
+// TestServerRequestID проверяет передачу X-Request-ID в ответ и в журнал
+func TestServerRequestID(t *testing.T) {
+	cfg := config.Default()
+	cfg.Logging.Format = "json"
+	var buf bytes.Buffer
+	logger := logging.New(&buf, cfg).Logger()
+
+	service := application.NewRAGService(mocks.NewMockDocumentRepository(), newTestClient(t, newTestConfig("http://127.0.0.1:0")))
+	httpServer := httptest.NewServer(api.NewServer(service, cfg.Server, logger, nil).Handler())
+	defer httpServer.Close()
+
+	get := func(id string) string {
+		req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/health", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Request-ID", id)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.Header.Get("X-Request-ID")
+	}
+
+	assert.Equal(t, "client-42", get("client-42"))
+	generated := get("bad id; \"quoted\"")
+	assert.Len(t, generated, 16, "недопустимый идентификатор клиента заменяется новым")
+
+	records := logRecords(t, &buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "client-42", records[0]["request_id"])
+	assert.Equal(t, "/health", records[0]["path"])
+	assert.Equal(t, float64(http.StatusOK), records[0]["status"])
+	assert.Equal(t, generated, records[1]["request_id"])
+}
@@ -0,0 +1,156 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/api"
+	"rag-system/src/application"
+	"rag-system/src/config"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure"
+	"rag-system/src/infrastructure/ai"
+	"rag-system/src/infrastructure/logging"
+	"rag-system/src/infrastructure/metrics"
+)
+
+// TestMetricsTextFormat проверяет вывод счетчиков, гистограмм и вычисляемых измерителей в формате Prometheus
+func TestMetricsTextFormat(t *testing.T) {
+	registry := metrics.NewRegistry()
+	requests := registry.Counter("http_requests_total", "Запросы\nк API", "method", "code")
+	latency := registry.Histogram("latency_seconds", "Длительность", []float64{1, 0.1})
+	registry.GaugeFunc("documents", "Документы", func() (float64, error) { return 42, nil })
+	registry.Counter("idle_total", "Без наблюдений")
+
+	requests.Inc("POST", "200")
+	requests.Add(2, "GET", "200")
+	requests.Inc("GET", `5"0\0`)
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(3)
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.WriteText(&buf))
+	assert.Equal(t, `# HELP http_requests_total Запросы\nк API
+# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} 2
+http_requests_total{method="GET",code="5\"0\\0"} 1
+http_requests_total{method="POST",code="200"} 1
+# HELP latency_seconds Длительность
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.55
+latency_seconds_count 3
+# HELP documents Документы
+# TYPE documents gauge
+documents 42
+# HELP idle_total Без наблюдений
+# TYPE idle_total counter
+idle_total 0
+`, buf.String())
+
+	assert.Equal(t, float64(2), requests.Value("GET", "200"))
+	assert.Equal(t, uint64(3), latency.Count())
+	assert.Panics(t, func() { registry.Counter("documents", "Повтор") })
+	assert.Panics(t, func() { requests.Inc("GET") }, "количество меток должно совпадать")
+}
+
+// TestRAGMetricsLabelsAndIndexStats проверяет метку нестандартного метода и одно чтение статистики за вывод
+func TestRAGMetricsLabelsAndIndexStats(t *testing.T) {
+	ragMetrics := metrics.NewRAG(metrics.NewRegistry())
+	calls := 0
+	ragMetrics.ObserveIndex(func() (domain.IndexStats, error) {
+		calls++
+		return domain.IndexStats{Documents: 2, Chunks: 5, Bytes: 4096}, nil
+	})
+	ragMetrics.HTTPRequest("GET", "/health", 200)
+	ragMetrics.HTTPRequest("BREW", "/health", 405)
+
+	var buf bytes.Buffer
+	require.NoError(t, ragMetrics.Registry().WriteText(&buf))
+	lines := strings.Split(buf.String(), "\n")
+	for _, expected := range []string{
+		`rag_http_requests_total{method="GET",path="/health",code="200"} 1`,
+		`rag_http_requests_total{method="other",path="/health",code="405"} 1`,
+		`rag_index_documents 2`,
+		`rag_index_chunks 5`,
+		`rag_index_size_bytes 4096`,
+	} {
+		assert.Contains(t, lines, expected)
+	}
+	assert.NotContains(t, buf.String(), "BREW")
+	assert.Equal(t, 1, calls)
+}
+
+// TestServerMetricsEndpoint проверяет метрики индексации, поиска, генерации, кэша и HTTP на /metrics
+func TestServerMetricsEndpoint(t *testing.T) {
+	var requests []map[string]interface{}
+	aiServer := newStructuredServer(t, []string{"Главный офис находится в Москве."}, &requests)
+	defer aiServer.Close()
+
+	ragMetrics := metrics.NewRAG(metrics.NewRegistry())
+	cfg := newTestConfig(aiServer.URL)
+	client, err := ai.NewAIClientWithOptions(cfg, ai.ClientOptions{Logger: logging.Discard(), Metrics: ragMetrics})
+	require.NoError(t, err)
+
+	repo := newTestRepository(t, "", func(opts *infrastructure.RepositoryOptions) { opts.Metrics = ragMetrics })
+	ragMetrics.ObserveIndex(repo.Stats)
+
+	service := application.NewRAGService(repo, client)
+	service.SetMetrics(ragMetrics)
+	httpServer := httptest.NewServer(api.NewServer(service, config.Default().Server, logging.Discard(), ragMetrics).Handler())
+	defer httpServer.Close()
+
+	post := func(path string, body interface{}) int {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		resp, err := http.Post(httpServer.URL+path, "application/json", bytes.NewReader(data))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusCreated, post("/documents",
+		domain.Document{ID: "contacts", Title: "Контакты", Content: "Главный офис находится в Москве."}))
+	require.Equal(t, http.StatusOK, post("/search", api.SearchRequest{Query: "офис"}))
+	require.Equal(t, http.StatusOK, post("/search", api.SearchRequest{Query: "офис"}))
+	require.Equal(t, http.StatusBadRequest, post("/search", api.SearchRequest{}))
+	require.NoError(t, service.UpdateDocument(domain.Document{ID: "contacts", Title: "Контакты", Content: "Офис переехал."}))
+
+	resp, err := http.Get(httpServer.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, metrics.ContentType, resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	lines := strings.Split(string(body), "\n")
+
+	for _, expected := range []string{
+		`rag_indexed_documents_total 1`,
+		`rag_indexed_chunks_total 1`,
+		`rag_updated_documents_total 1`,
+		`rag_index_documents 1`,
+		`rag_index_chunks 1`,
+		`rag_search_duration_seconds_count 2`,
+		`rag_generation_duration_seconds_count{source="api"} 1`,
+		`rag_generation_duration_seconds_count{source="cache"} 1`,
+		`rag_generation_retries_bucket{le="0"} 1`,
+		`rag_cache_hits_total{kind="cache"} 1`,
+		`rag_cache_misses_total 1`,
+		`rag_http_requests_total{method="POST",path="/documents",code="201"} 1`,
+		`rag_http_requests_total{method="POST",path="/search",code="200"} 2`,
+		`rag_http_requests_total{method="POST",path="/search",code="400"} 1`,
+	} {
+		assert.Contains(t, lines, expected)
+	}
+	assert.Contains(t, string(body), "# TYPE rag_index_size_bytes gauge")
+	assert.Len(t, requests, 1)
+}
@@ -0,0 +1,185 @@
+package unit
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/infrastructure"
+	"rag-system/src/infrastructure/migrations"
+)
+
+// openLegacyDatabase создает базу из фикстуры со схемой до появления миграций
+func openLegacyDatabase(t *testing.T) (string, *sql.DB) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "schema_legacy.sql"))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(string(fixture))
+	require.NoError(t, err)
+	return path, db
+}
+
+// tableExists проверяет наличие таблицы или индекса в схеме
+func tableExists(t *testing.T, db *sql.DB, kind, name string) bool {
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = ? AND name = ?", kind, name).Scan(&count))
+	return count > 0
+}
+
+// TestMigrateLegacyDatabase проверяет обновление базы, созданной до появления миграций
+func TestMigrateLegacyDatabase(t *testing.T) {
+	path, db := openLegacyDatabase(t)
+	migrator, err := migrations.NewMigrator(migrations.Embedded())
+	require.NoError(t, err)
+
+	// Состояние и dry-run не изменяют базу
+	states, err := migrator.Status(db)
+	require.NoError(t, err)
+	require.NotEmpty(t, states)
+	for i, state := range states {
+		assert.Equal(t, i+1, state.Version, "версии миграций должны идти подряд")
+		assert.False(t, state.Applied)
+	}
+	pending, err := migrator.Pending(db)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(states))
+	assert.False(t, tableExists(t, db, "table", "schema_migrations"))
+
+	// Репозиторий применяет миграции при открытии, данные сохраняются
+	repo := newTestRepository(t, path)
+
+	assert.True(t, tableExists(t, db, "index", "idx_chunks_document_id"))
+	states, err = migrator.Status(db)
+	require.NoError(t, err)
+	for _, state := range states {
+		assert.True(t, state.Applied, "миграция %d должна быть применена", state.Version)
+		assert.False(t, state.AppliedAt.IsZero())
+	}
+
+	doc, err := repo.GetDocument("contacts")
+	require.NoError(t, err)
+	assert.Equal(t, "Главный офис находится в Москве.", doc.Content)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), doc.CreatedAt.UTC())
+	chunks, err := repo.FindRelevantChunks("офис", 5, 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, chunks)
+	assert.Equal(t, "contacts", chunks[0].DocumentID)
+
+	// Повторный запуск ничего не применяет
+	applied, err := migrator.Up(db)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
+// TestMigratorTransactions проверяет откат неудачной миграции и отказ работать с более новой схемой
+func TestMigratorTransactions(t *testing.T) {
+	_, err := migrations.NewMigrator([]migrations.Migration{{Version: 1, Name: "a"}, {Version: 1, Name: "b"}})
+	assert.ErrorContains(t, err, "повторяющаяся версия")
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "rag.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Миграции применяются по версиям независимо от порядка в списке
+	migrator, err := migrations.NewMigrator([]migrations.Migration{
+		{Version: 2, Name: "broken", SQL: "CREATE TABLE partial (id INTEGER); INSERT INTO missing VALUES (1);"},
+		{Version: 1, Name: "notes", SQL: "CREATE TABLE notes (id INTEGER PRIMARY KEY);"},
+	})
+	require.NoError(t, err)
+
+	applied, err := migrator.Up(db)
+	assert.ErrorContains(t, err, "0002_broken")
+	require.Len(t, applied, 1)
+	assert.Equal(t, "notes", applied[0].Name)
+	assert.True(t, tableExists(t, db, "table", "notes"))
+	assert.False(t, tableExists(t, db, "table", "partial"), "неудачная миграция должна откатываться целиком")
+
+	pending, err := migrator.Pending(db)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].Version)
+
+	// База, обновленная более новой версией программы
+	_, err = db.Exec("INSERT INTO schema_migrations (version, name) VALUES (7, 'future')")
+	require.NoError(t, err)
+	_, err = migrator.Up(db)
+	assert.ErrorContains(t, err, "схема новее программы")
+}
+
+// TestMigratorConcurrentUp проверяет, что процессы, одновременно обновляющие одну базу, применяют каждую
+// миграцию ровно один раз
+func TestMigratorConcurrentUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rag.db")
+	migrator, err := migrations.NewMigrator([]migrations.Migration{
+		{Version: 1, Name: "notes", SQL: "CREATE TABLE notes (id INTEGER PRIMARY KEY);"},
+		{Version: 2, Name: "notes_index", SQL: "CREATE INDEX idx_notes ON notes(id);"},
+	})
+	require.NoError(t, err)
+
+	const processes = 4
+	var wg sync.WaitGroup
+	results := make(chan []migrations.Migration, processes)
+	errs := make(chan error, processes)
+	for i := 0; i < processes; i++ {
+		db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+		require.NoError(t, err)
+		defer db.Close()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := migrator.Up(db)
+			results <- applied
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	total := 0
+	for applied := range results {
+		total += len(applied)
+	}
+	assert.Equal(t, 2, total)
+}
+
+// TestOpenSQLiteReadOnly проверяет, что база для status и dry-run открывается только для чтения и не создается
+func TestOpenSQLiteReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rag.db")
+	_, err := infrastructure.OpenSQLite(path, infrastructure.SQLiteOptions{}, true)
+	require.Error(t, err)
+	assert.NoFileExists(t, path, "Проверка состояния не должна создавать базу")
+
+	db, err := infrastructure.OpenSQLite(path, infrastructure.SQLiteOptions{}, false)
+	require.NoError(t, err)
+	var mode string
+	require.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode, "Соединение получает PRAGMA репозитория")
+	migrator, err := migrations.NewMigrator(migrations.Embedded())
+	require.NoError(t, err)
+	_, err = migrator.Up(db)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	readOnly, err := infrastructure.OpenSQLite(path, infrastructure.SQLiteOptions{}, true)
+	require.NoError(t, err)
+	defer readOnly.Close()
+	pending, err := migrator.Pending(readOnly)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	_, err = readOnly.Exec("DELETE FROM schema_migrations")
+	assert.Error(t, err)
+}
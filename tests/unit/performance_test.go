@@ -207,3 +207,62 @@ func TestConcurrentIndexing(t *testing.T) {
 	assert.NoError(t, err)
 	assert.GreaterOrEqual(t, len(allChunks), numDocs, "Все документы должны быть сохранены")
 }
+
+// benchmarkDocument документ ~5KB для сравнения способов индексации
+func benchmarkDocument(i int) domain.Document {
+	return domain.Document{
+		ID:      fmt.Sprintf("bench-doc-%d", i),
+		Title:   fmt.Sprintf("Документ %d", i),
+		Content: strings.Repeat("Содержимое документа для теста производительности. ", 100),
+	}
+}
+
+// BenchmarkIndexing сравнивает способы индексации на одном и том же наборе документов: по одному документу
+// в транзакции (SaveDocument), SaveDocuments и BulkIndexer с разным размером пакета. Каждая итерация
+// индексирует весь набор в новую базу, поэтому время на операцию сравнимо между способами
+func BenchmarkIndexing(b *testing.B) {
+	docs := make([]domain.Document, 200)
+	for i := range docs {
+		docs[i] = benchmarkDocument(i)
+	}
+
+	run := func(name string, index func(repo *infrastructure.SQLiteDocumentRepository) error) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				repo := newTestRepository(b, "")
+				b.StartTimer()
+				if err := index(repo); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(docs)*b.N)/b.Elapsed().Seconds(), "docs/s")
+		})
+	}
+
+	run("SaveDocument", func(repo *infrastructure.SQLiteDocumentRepository) error {
+		for _, doc := range docs {
+			if err := repo.SaveDocument(doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	run("SaveDocuments", func(repo *infrastructure.SQLiteDocumentRepository) error {
+		return repo.SaveDocuments(docs)
+	})
+	for _, batchSize := range []int{10, 100, 1000} {
+		run(fmt.Sprintf("BulkIndexer/batch=%d", batchSize), func(repo *infrastructure.SQLiteDocumentRepository) error {
+			indexer, err := repo.NewBulkIndexer(infrastructure.BulkOptions{BatchSize: batchSize})
+			if err != nil {
+				return err
+			}
+			for _, doc := range docs {
+				if err := indexer.Add(doc); err != nil {
+					return err
+				}
+			}
+			return indexer.Close()
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ai"
+)
+
+// TestRedactorDetectsPII проверяет распознавание и обратимую замену персональных данных
+func TestRedactorDetectsPII(t *testing.T) {
+	redactor, err := ai.NewRedactor(nil)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		text     string
+		kind     string
+		redacted bool
+	}{
+		{"email", "Email: info@company.com.", ai.PIIEmail, true},
+		{"phone_ru", "Телефон: +7 (495) 123-45-67.", ai.PIIPhone, true},
+		{"phone_plain", "Звоните 8 800 555 35 35", ai.PIIPhone, true},
+		{"card_valid", "Карта 4111 1111 1111 1111", ai.PIICard, true},
+		{"card_invalid_luhn", "Заказ 4111 1111 1111 1112", ai.PIICard, false},
+		{"iban_de", "IBAN DE89 3704 0044 0532 0130 00", ai.PIIIBAN, true},
+		{"iban_gb", "IBAN GB82WEST12345698765432", ai.PIIIBAN, true},
+		{"iban_invalid", "Код DE00 3704 0044 0532 0130 00", ai.PIIIBAN, false},
+		{"passport_ru", "Паспорт 45 09 123456", ai.PIIPassport, true},
+		{"passport_intl", "Passport AB1234567", ai.PIIPassport, true},
+		{"year", "Компания основана в 2020 году", ai.PIIPhone, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			session := redactor.NewSession()
+			redacted := session.Redact(tc.text)
+
+			if tc.redacted {
+				assert.NotEqual(t, tc.text, redacted)
+				assert.Equal(t, 1, session.Counts()[tc.kind], "Ожидалась замена типа %s: %q", tc.kind, redacted)
+				assert.Equal(t, tc.text, session.Restore(redacted), "Замена должна быть обратимой")
+			} else {
+				assert.Zero(t, session.Counts()[tc.kind], "Неожиданная замена: %q", redacted)
+			}
+		})
+	}
+}
+
+// TestRedactorPlaceholders проверяет, что одинаковые значения получают один плейсхолдер
+func TestRedactorPlaceholders(t *testing.T) {
+	redactor, err := ai.NewRedactor([]string{"email"})
+	require.NoError(t, err)
+
+	session := redactor.NewSession()
+	redacted := session.Redact("a@example.com, b@example.com, снова a@example.com, тел. +7 (495) 123-45-67")
+	assert.Equal(t, "[EMAIL_1], [EMAIL_2], снова [EMAIL_1], тел. +7 (495) 123-45-67", redacted)
+	assert.Equal(t, map[string]int{"email": 3}, session.Counts())
+	assert.Equal(t, "email=3", session.Summary())
+
+	_, err = ai.NewRedactor([]string{"email", "snils"})
+	assert.Error(t, err)
+}
+
+// TestGenerateRedactsPII проверяет, что персональные данные не уходят во внешний API и восстанавливаются в ответе
+func TestGenerateRedactsPII(t *testing.T) {
+	placeholder := regexp.MustCompile(`\[EMAIL_\d+\]`)
+	var body string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{
+				"content": "Пишите на " + placeholder.FindString(body),
+			}}},
+		})
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL, func(config *ai.Config) {
+		config.Privacy.Redaction.Enabled = true
+	}))
+
+	chunks := []domain.Chunk{{
+		ID:         "contacts_chunk_0",
+		DocumentID: "contacts",
+		Content:    "Главный офис находится в Москве. Телефон: +7 (495) 123-45-67. Email: info@company.com.",
+	}}
+	result, err := client.Generate(context.Background(), ai.GenerateRequest{Query: "Какой email у компании?", Chunks: chunks})
+	require.NoError(t, err)
+
+	assert.NotContains(t, body, "info@company.com")
+	assert.NotContains(t, body, "123-45-67")
+	assert.Equal(t, "Пишите на info@company.com", result.Text)
+	assert.Equal(t, map[string]int{"email": 1, "phone": 1}, result.Metrics.Redactions)
+}
@@ -0,0 +1,225 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/api"
+	"rag-system/src/application"
+	"rag-system/src/config"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ai"
+	"rag-system/src/infrastructure/logging"
+	"rag-system/tests/mocks"
+)
+
+// profilesYAML конфигурация с двумя профилями поверх общей секции ai
+const profilesYAML = `ai:
+  base_url: "https://api.example.com/v1"
+  api_key: "base-key"
+  model: "base-model"
+  temperature: 0.1
+retrieval:
+  limit: 5
+profiles:
+  draft:
+    ai:
+      model: "cheap-model"
+      temperature: 0.7
+    retrieval:
+      limit: 3
+  customer:
+    ai:
+      model: "expensive-model"
+      api_key: "customer-key"
+`
+
+// TestConfigProfiles проверяет, что профиль переопределяет только указанные ключи
+func TestConfigProfiles(t *testing.T) {
+	cfg, err := config.Load(writeConfigFile(t, profilesYAML))
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"customer", "draft"}, cfg.ProfileNames())
+
+	draft, err := cfg.WithProfile("draft")
+	require.NoError(t, err)
+	assert.Equal(t, "draft", draft.Profile)
+	assert.Equal(t, "cheap-model", draft.AI.Model)
+	assert.Equal(t, 0.7, draft.AI.Temperature)
+	assert.Equal(t, 3, draft.Retrieval.Limit)
+	assert.Equal(t, "base-key", draft.AI.APIKey, "не указанные в профиле ключи наследуются")
+
+	customer, err := cfg.WithProfile("customer")
+	require.NoError(t, err)
+	assert.Equal(t, "customer-key", customer.AI.APIKey)
+	assert.Equal(t, 5, customer.Retrieval.Limit)
+	assert.Equal(t, "base-model", cfg.AI.Model, "исходная конфигурация не меняется")
+
+	_, err = cfg.WithProfile("unknown")
+	assert.ErrorContains(t, err, "неизвестный профиль")
+
+	// Флаги командной строки имеют приоритет над профилем
+	require.NoError(t, cfg.Set("retrieval.limit", "10"))
+	draft, err = cfg.WithProfile("draft")
+	require.NoError(t, err)
+	assert.Equal(t, 10, draft.Retrieval.Limit)
+	assert.Equal(t, "cheap-model", draft.AI.Model)
+}
+
+// TestConfigProfilesValidation проверяет ошибки в профилях: неизвестные ключи, значения и ссылки на профили
+func TestConfigProfilesValidation(t *testing.T) {
+	path := writeConfigFile(t, profilesYAML+`  typo:
+    ai:
+      max_token: 10
+`)
+	_, err := config.Load(path)
+	assert.Equal(t, []string{path + `:21: profiles.typo.ai.max_token: неизвестный ключ "max_token" (возможно, max_tokens)`},
+		problemStrings(t, err))
+
+	path = writeConfigFile(t, profilesYAML+`  broken:
+    ai:
+      temperature: 5
+profile: missing
+server:
+  allowed_profiles: ["draft", "other"]
+`)
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		path + `:22: profile: недопустимое значение "missing" (допустимо: broken, customer, draft)`,
+		path + `:24: server.allowed_profiles: недопустимое значение "other" (допустимо: broken, customer, draft)`,
+		path + ":21: profiles.broken.ai.temperature: должно быть в диапазоне [0, 2], текущее значение: 5",
+	}, problemStrings(t, cfg.Validate("profiles")))
+}
+
+// TestConfigProfilesMasked проверяет, что секреты профилей скрыты при выводе и в журнале
+func TestConfigProfilesMasked(t *testing.T) {
+	cfg, err := config.Load(writeConfigFile(t, profilesYAML))
+	require.NoError(t, err)
+
+	out, err := cfg.Masked().YAML()
+	require.NoError(t, err)
+	assert.NotContains(t, out, "customer-key")
+	assert.Contains(t, out, "expensive-model")
+	assert.Contains(t, cfg.Secrets(), "customer-key")
+
+	customer, err := cfg.WithProfile("customer")
+	require.NoError(t, err)
+	assert.Equal(t, "customer-key", customer.AI.APIKey, "маскирование не меняет исходные профили")
+}
+
+// TestAIClientProfilePerRequest проверяет выбор модели профилем запроса
+func TestAIClientProfilePerRequest(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Ответ"}, &requests)
+	defer server.Close()
+
+	cfg, err := config.Load(writeConfigFile(t, profilesYAML))
+	require.NoError(t, err)
+	cfg.AI.BaseURL = server.URL
+	cfg.Cache.Backend = "memory"
+	cfg.Prompts.Dir = ""
+	cfg.Profile = "draft"
+	client := newTestClient(t, cfg)
+
+	req := ai.GenerateRequest{Query: "Где офис?", Chunks: groundingChunks[1:]}
+	_, err = client.Generate(context.Background(), req)
+	require.NoError(t, err)
+
+	req.Profile = "customer"
+	result, err := client.Generate(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, result.Metrics.FromCache, "другой профиль - другая модель и другой ключ кэша")
+
+	req.Profile = "unknown"
+	_, err = client.Generate(context.Background(), req)
+	assert.ErrorContains(t, err, "неизвестный профиль")
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "cheap-model", requests[0]["model"])
+	assert.Equal(t, "expensive-model", requests[1]["model"])
+	assert.ElementsMatch(t, []string{"customer", "draft"}, client.Profiles())
+}
+
+// TestServerProfileAllowlist проверяет, что API разрешает выбирать только профили из server.allowed_profiles
+func TestServerProfileAllowlist(t *testing.T) {
+	var requests []map[string]interface{}
+	aiServer := newStructuredServer(t, []string{"Главный офис находится в Москве."}, &requests)
+	defer aiServer.Close()
+
+	cfg, err := config.Load(writeConfigFile(t, profilesYAML))
+	require.NoError(t, err)
+	cfg.AI.BaseURL = aiServer.URL
+	cfg.Cache.Backend = "memory"
+	cfg.Prompts.Dir = ""
+	cfg.Server.AllowedProfiles = []string{"draft"}
+
+	service := application.NewRAGService(mocks.NewMockDocumentRepository(), newTestClient(t, cfg))
+	service.SetConfig(cfg)
+	require.NoError(t, service.IndexDocument(domain.Document{ID: "contacts", Title: "Контакты", Content: "Главный офис находится в Москве."}))
+	httpServer := httptest.NewServer(api.NewServer(service, cfg.Server, logging.Discard(), nil).Handler())
+	defer httpServer.Close()
+
+	search := func(profile string) int {
+		body, err := json.Marshal(api.SearchRequest{Query: "офис", Profile: profile})
+		require.NoError(t, err)
+		resp, err := http.Post(httpServer.URL+"/search", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, search("draft"))
+	assert.Equal(t, http.StatusForbidden, search("customer"))
+	assert.Equal(t, http.StatusOK, search(""))
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "cheap-model", requests[0]["model"])
+	assert.Equal(t, "base-model", requests[1]["model"])
+}
+
+// limitRecordingRepository запоминает лимит, с которым вызван поиск
+type limitRecordingRepository struct {
+	*mocks.MockDocumentRepository
+	limits []int
+}
+
+func (r *limitRecordingRepository) FindRelevantChunks(query string, limit int, threshold float64) ([]domain.Chunk, error) {
+	r.limits = append(r.limits, limit)
+	return r.MockDocumentRepository.FindRelevantChunks(query, limit, threshold)
+}
+
+// TestSearchUsesProfileRetrieval проверяет, что при limit <= 0 используется retrieval.limit профиля запроса
+func TestSearchUsesProfileRetrieval(t *testing.T) {
+	var requests []map[string]interface{}
+	aiServer := newStructuredServer(t, []string{"Главный офис находится в Москве."}, &requests)
+	defer aiServer.Close()
+
+	cfg, err := config.Load(writeConfigFile(t, profilesYAML))
+	require.NoError(t, err)
+	cfg.AI.BaseURL = aiServer.URL
+	cfg.Cache.Backend = "memory"
+	cfg.Prompts.Dir = ""
+
+	repo := &limitRecordingRepository{MockDocumentRepository: mocks.NewMockDocumentRepository()}
+	service := application.NewRAGService(repo, newTestClient(t, cfg))
+	service.SetConfig(cfg)
+	require.NoError(t, service.IndexDocument(domain.Document{ID: "contacts", Title: "Контакты", Content: "Главный офис находится в Москве."}))
+
+	for _, profile := range []string{"draft", ""} {
+		_, err := service.SearchAndGenerateWithOptions(context.Background(), "офис", 0, 0.1, ai.PromptOptions{Profile: profile})
+		require.NoError(t, err)
+	}
+	_, err = service.SearchAndGenerateStructured(context.Background(), "офис", 0, 0.1, ai.PromptOptions{Profile: "draft"})
+	require.Error(t, err, "Ответ модели не соответствует схеме")
+	assert.Equal(t, []int{3, 5, 3}, repo.limits)
+
+	_, err = service.SearchAndGenerateWithOptions(context.Background(), "офис", 0, 0.1, ai.PromptOptions{Profile: "unknown"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ai"
+)
+
+// TestLoadPromptSet проверяет загрузку именованных шаблонов из config/prompts
+func TestLoadPromptSet(t *testing.T) {
+	set, err := ai.LoadPromptSet("../../config/prompts", "qa", "ru")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"compare", "qa", "summarize"}, set.Names())
+
+	chunks := []domain.Chunk{
+		{ID: "a_chunk_0", DocumentID: "a", Content: "Компания основана в 2020 году."},
+		{ID: "b_chunk_0", DocumentID: "b", Content: "Компания основана в 2021 году."},
+	}
+	data := ai.NewPromptData("Когда основана компания?", chunks, ai.PromptOptions{Language: "en"})
+
+	messages, err := set.Render(data, "")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Contains(t, messages[0].Content, "английском")
+	assert.Equal(t, "user", messages[1].Role)
+	assert.Contains(t, messages[1].Content, "Компания основана в 2020 году.")
+	assert.Contains(t, messages[1].Content, "Когда основана компания?")
+
+	messages, err = set.Render(data, "compare")
+	require.NoError(t, err)
+	assert.Contains(t, messages[len(messages)-1].Content, "[b] Компания основана в 2021 году.")
+
+	_, err = set.Render(data, "unknown")
+	assert.Error(t, err)
+}
+
+// TestPromptHistory проверяет, что история диалога попадает между системным сообщением и вопросом
+func TestPromptHistory(t *testing.T) {
+	set := ai.DefaultPromptSet("ru")
+	history := []ai.Message{
+		{Role: "user", Content: "Где офис?"},
+		{Role: "assistant", Content: "В Москве."},
+	}
+	data := ai.NewPromptData("А адрес?", []domain.Chunk{{Content: "Адрес: улица Тверская, 1."}}, ai.PromptOptions{History: history})
+
+	messages, err := set.Render(data, "")
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Equal(t, history, messages[1:3])
+	assert.Equal(t, "user", messages[3].Role)
+}
+
+// TestInvalidPromptTemplates проверяет, что ошибки шаблонов обнаруживаются при загрузке
+func TestInvalidPromptTemplates(t *testing.T) {
+	cases := map[string]string{
+		"syntax":    `{{define "user"}}{{.Query}{{end}}`,
+		"no_user":   `{{define "system"}}Только системная часть{{end}}`,
+		"bad_field": `{{define "user"}}{{.Unknown}}{{end}}`,
+		"bad_func":  `{{define "user"}}{{len .Query 1}}{{end}}`,
+	}
+
+	for name, text := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "qa.tmpl"), []byte(text), 0644))
+
+			_, err := ai.LoadPromptSet(dir, "qa", "ru")
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := ai.LoadPromptSet("../../config/prompts", "missing", "ru")
+	assert.Error(t, err, "Несуществующий шаблон по умолчанию должен приводить к ошибке")
+}
+
+// TestGenerateWithTemplate проверяет, что клиент отправляет системное сообщение и выбранный шаблон
+func TestGenerateWithTemplate(t *testing.T) {
+	var received struct {
+		Messages []ai.Message `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"choices":[{"message":{"content":"Краткое резюме"}}]}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL, func(config *ai.Config) {
+		config.Prompts.Dir = "../../config/prompts"
+		config.Prompts.Default = "qa"
+		config.Prompts.Language = "ru"
+	}))
+
+	result, err := client.Generate(context.Background(), ai.GenerateRequest{
+		Query:         "О чем документ?",
+		Chunks:        []domain.Chunk{{ID: "d_chunk_0", DocumentID: "d", Content: "Документ о продуктах компании."}},
+		PromptOptions: ai.PromptOptions{Template: "summarize"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Краткое резюме", result.Text)
+
+	require.Len(t, received.Messages, 2)
+	assert.Equal(t, "system", received.Messages[0].Role)
+	assert.Contains(t, received.Messages[1].Content, "Составь краткое резюме")
+}
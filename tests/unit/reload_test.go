@@ -0,0 +1,224 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/application"
+	"rag-system/src/config"
+	"rag-system/src/infrastructure/ai"
+	"rag-system/tests/mocks"
+)
+
+// TestAIClientReload проверяет применение новой модели без пересоздания клиента и сохранение прежней
+// конфигурации, если новая невалидна
+func TestAIClientReload(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Ответ"}, &requests)
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL)
+	client := newTestClient(t, cfg)
+	req := ai.GenerateRequest{Query: "Где офис?", Chunks: groundingChunks[1:]}
+
+	_, err := client.Generate(context.Background(), req)
+	require.NoError(t, err)
+
+	next := cfg
+	next.AI.Model = "new-model"
+	next.AI.Temperature = 0.7
+	require.NoError(t, client.Reload(next))
+	result, err := client.Generate(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, result.Metrics.FromCache, "новая модель - новый ключ кэша")
+
+	invalid := next
+	invalid.AI.Model = ""
+	invalid.AI.MaxTokens = -1
+	err = client.Reload(invalid)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ai.model")
+	assert.Contains(t, err.Error(), "ai.max_tokens")
+
+	result, err = client.Generate(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, result.Metrics.FromCache, "после неудачной перезагрузки действует прежняя конфигурация")
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "test-model", requests[0]["model"])
+	assert.Equal(t, "new-model", requests[1]["model"])
+	assert.Equal(t, 0.7, requests[1]["temperature"])
+}
+
+// TestRAGServiceReloadRetrieval проверяет замену параметров поиска по умолчанию
+func TestRAGServiceReloadRetrieval(t *testing.T) {
+	service := application.NewRAGService(mocks.NewMockDocumentRepository(), nil)
+	assert.Equal(t, config.Default().Retrieval, service.Retrieval())
+
+	cfg := config.Default()
+	cfg.Retrieval.Limit = 9
+	cfg.Retrieval.Threshold = 0.3
+	require.NoError(t, service.Reload(cfg))
+	assert.Equal(t, 9, service.Retrieval().Limit)
+
+	cfg.Retrieval.Limit = 0
+	assert.Error(t, service.Reload(cfg))
+	assert.Equal(t, 9, service.Retrieval().Limit)
+}
+
+// TestReloaderWatchesFile проверяет перезагрузку при изменении файла и отказ от невалидной конфигурации
+func TestReloaderWatchesFile(t *testing.T) {
+	path := writeConfigFile(t, "retrieval:\n  limit: 5\n")
+	initial, err := config.Load(path)
+	require.NoError(t, err)
+
+	applied := make(chan config.Config, 10)
+	load := func() (config.Config, error) {
+		cfg, err := config.Load(path)
+		if err != nil {
+			return cfg, err
+		}
+		return cfg, cfg.Validate("retrieval")
+	}
+	var logs bytes.Buffer
+	reloader := config.NewReloader(path, initial, load, func(cfg config.Config) error {
+		applied <- cfg
+		return nil
+	}, slog.New(slog.NewTextHandler(&logs, nil)))
+	reloader.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	stopped := make(chan struct{})
+	go func() {
+		reloader.Run(ctx, signals)
+		close(stopped)
+	}()
+
+	// Время модификации должно отличаться от исходного
+	rewrite := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		future := time.Now().Add(time.Duration(len(content)) * time.Second)
+		require.NoError(t, os.Chtimes(path, future, future))
+	}
+
+	rewrite("retrieval:\n  limit: 7\nchunking:\n  size: 800\n")
+	select {
+	case cfg := <-applied:
+		assert.Equal(t, 7, cfg.Retrieval.Limit)
+	case <-time.After(2 * time.Second):
+		t.Fatal("конфигурация не перезагружена после изменения файла")
+	}
+	assert.Eventually(t, func() bool { return reloader.Current().Retrieval.Limit == 7 }, time.Second, 10*time.Millisecond)
+
+	// Невалидная конфигурация не применяется
+	require.NoError(t, os.WriteFile(path, []byte("retrieval:\n  limit: 0\n"), 0644))
+	err = reloader.Reload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "retrieval.limit")
+	assert.Equal(t, 7, reloader.Current().Retrieval.Limit)
+
+	// Перезагрузка по сигналу
+	require.NoError(t, os.WriteFile(path, []byte("retrieval:\n  limit: 3\n"), 0644))
+	signals <- os.Interrupt
+	select {
+	case cfg := <-applied:
+		assert.Equal(t, 3, cfg.Retrieval.Limit)
+	case <-time.After(2 * time.Second):
+		t.Fatal("конфигурация не перезагружена по сигналу")
+	}
+
+	cancel()
+	<-stopped
+	assert.Contains(t, logs.String(), "chunking.size")
+}
+
+// TestReloaderWatchesTemplates проверяет перезагрузку при изменении шаблона промпта и отказ от шаблона,
+// который не проходит пробный рендеринг
+func TestReloaderWatchesTemplates(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Ответ"}, &requests)
+	defer server.Close()
+
+	path := writeConfigFile(t, "prompts:\n  dir: prompts\n  default: qa\n")
+	template := filepath.Join(filepath.Dir(path), "prompts", "qa.tmpl")
+	require.NoError(t, os.MkdirAll(filepath.Dir(template), 0755))
+	// Время модификации должно отличаться от исходного
+	writeTemplate := func(content string, age time.Duration) {
+		require.NoError(t, os.WriteFile(template, []byte(content), 0644))
+		modTime := time.Now().Add(age)
+		require.NoError(t, os.Chtimes(template, modTime, modTime))
+	}
+	writeTemplate(`{{define "user"}}Вопрос: {{.Query}}{{end}}`, -time.Hour)
+
+	base := newTestConfig(server.URL)
+	load := func() (config.Config, error) {
+		cfg, err := config.Load(path)
+		cfg.AI, cfg.Cache = base.AI, base.Cache
+		return cfg, err
+	}
+	initial, err := load()
+	require.NoError(t, err)
+	client := newTestClient(t, initial)
+
+	results := make(chan error, 10)
+	reloader := config.NewReloader(path, initial, load, func(cfg config.Config) error {
+		err := client.Reload(cfg)
+		results <- err
+		return err
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	reloader.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Run(ctx, make(chan os.Signal))
+
+	generate := func() string {
+		_, err := client.Generate(context.Background(), ai.GenerateRequest{Query: "Где офис?", Chunks: groundingChunks[1:]})
+		require.NoError(t, err)
+		messages := requests[len(requests)-1]["messages"].([]interface{})
+		return messages[len(messages)-1].(map[string]interface{})["content"].(string)
+	}
+	assert.Equal(t, "Вопрос: Где офис?", generate())
+
+	writeTemplate(`{{define "user"}}Новый вопрос: {{.Query}}{{end}}`, time.Hour)
+	select {
+	case err := <-results:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("конфигурация не перезагружена после изменения шаблона")
+	}
+	assert.Equal(t, "Новый вопрос: Где офис?", generate())
+
+	// Шаблон с ошибкой выполнения отклоняется пробным рендерингом, действует прежний
+	writeTemplate(`{{define "user"}}{{.Missing}}{{end}}`, 2*time.Hour)
+	select {
+	case err := <-results:
+		require.Error(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("конфигурация не перезагружена после изменения шаблона")
+	}
+	assert.Equal(t, "Новый вопрос: Где офис?", generate())
+}
+
+// TestRestartRequired проверяет список изменений, требующих перезапуска
+func TestRestartRequired(t *testing.T) {
+	old := config.Default()
+	next := old
+	next.AI.Model = "other"
+	next.Retrieval.Limit = 10
+	assert.Empty(t, config.RestartRequired(old, next))
+
+	next.Storage.DBPath = "./other.db"
+	next.Cache.Backend = "memory"
+	assert.Equal(t, []string{"storage.db_path", "cache.backend"}, config.RestartRequired(old, next))
+}
@@ -0,0 +1,73 @@
+package unit
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/config"
+)
+
+// TestSecretReferences проверяет разрешение ссылок file:, env: и exec: в поле ai.api_key
+func TestSecretReferences(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "ai_key")
+	require.NoError(t, os.WriteFile(secretFile, []byte("key-from-file\n"), 0600))
+	t.Setenv("TEST_RAG_SECRET", "key-from-env")
+
+	cases := map[string]string{
+		"file:" + secretFile:      "key-from-file",
+		"env:TEST_RAG_SECRET":     "key-from-env",
+		"exec:echo key-from-exec": "key-from-exec",
+		"plain-key":               "plain-key",
+		"https://not-a-reference": "https://not-a-reference",
+	}
+	for ref, expected := range cases {
+		cfg := config.Default()
+		cfg.AI.APIKey = ref
+		require.NoError(t, config.ResolveSecrets(&cfg, config.DefaultSecretProviders()), ref)
+		assert.Equal(t, expected, cfg.AI.APIKey, ref)
+	}
+}
+
+// TestSecretReferenceErrors проверяет, что ошибки разрешения называют поле и не содержат секретов
+func TestSecretReferenceErrors(t *testing.T) {
+	for _, ref := range []string{"file:/nonexistent/ai_key", "env:TEST_RAG_SECRET_MISSING", "exec:false"} {
+		cfg := config.Default()
+		cfg.AI.APIKey = ref
+		err := config.ResolveSecrets(&cfg, config.DefaultSecretProviders())
+		require.Error(t, err, ref)
+		assert.Contains(t, err.Error(), "ai.api_key")
+		assert.Equal(t, ref, cfg.AI.APIKey, "неразрешенная ссылка не заменяется")
+	}
+}
+
+// TestLoadResolvesSecretFromEnvReference проверяет разрешение ссылки, заданной через RAG_AI_API_KEY
+func TestLoadResolvesSecretFromEnvReference(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "ai_key")
+	require.NoError(t, os.WriteFile(secretFile, []byte("sk-mounted"), 0600))
+	t.Setenv("RAG_AI_API_KEY", "file:"+secretFile)
+
+	cfg, err := config.Load(writeConfigFile(t, "ai:\n  model: test\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "sk-mounted", cfg.AI.APIKey)
+
+	out, err := cfg.Masked().YAML()
+	require.NoError(t, err)
+	assert.NotContains(t, out, "sk-mounted")
+}
+
+// TestLogWriterMasksSecrets проверяет, что значения секретов не попадают в журнал
+func TestLogWriterMasksSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.AI.APIKey = "sk-very-secret"
+
+	var buf bytes.Buffer
+	logger := log.New(cfg.LogWriter(&buf), "", 0)
+	logger.Printf("Authorization: Bearer %s", cfg.AI.APIKey)
+
+	assert.Equal(t, "Authorization: Bearer ********\n", buf.String())
+}
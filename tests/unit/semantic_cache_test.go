@@ -0,0 +1,145 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ai"
+)
+
+// TestHashEmbedderParaphrases проверяет, что перефразированные вопросы ближе друг к другу, чем разные вопросы
+func TestHashEmbedderParaphrases(t *testing.T) {
+	embedder := ai.HashEmbedder{}
+	similarity := func(a, b string) float64 {
+		va, vb := embedder.Embed(a), embedder.Embed(b)
+		var dot float64
+		for i := range va {
+			dot += va[i] * vb[i]
+		}
+		return dot
+	}
+
+	base := "Где находится главный офис компании?"
+	assert.InDelta(t, 1.0, similarity(base, "Главный офис компании где находится"), 0.001)
+	assert.Greater(t, similarity(base, "Где находится офис компании"), 0.85)
+	assert.Less(t, similarity(base, "Когда была основана компания?"), 0.5)
+}
+
+// TestSemanticCacheReusesAnswer проверяет переиспользование ответа на перефразированный вопрос
+func TestSemanticCacheReusesAnswer(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Главный офис находится в Москве.", "Компания основана в 2020 году."}, &requests)
+	defer server.Close()
+
+	config := newTestConfig(server.URL)
+	config.SemanticCache.Enabled = true
+	config.SemanticCache.Threshold = 0.85
+	config.SemanticCache.MinChunkOverlap = 0.5
+	client := newTestClient(t, config)
+
+	office := groundingChunks[1]
+	company := groundingChunks[0]
+	ask := func(query string, chunks ...domain.Chunk) *ai.GenerateResult {
+		result, err := client.Generate(context.Background(), ai.GenerateRequest{Query: query, Chunks: chunks})
+		require.NoError(t, err)
+		return result
+	}
+
+	first := ask("Где находится главный офис компании?", office)
+	assert.False(t, first.Metrics.FromCache)
+
+	paraphrase := ask("Где находится офис компании", office, company)
+	assert.Equal(t, "Главный офис находится в Москве.", paraphrase.Text)
+	assert.True(t, paraphrase.Metrics.FromCache)
+	assert.True(t, paraphrase.Metrics.SemanticHit)
+	assert.Equal(t, "Где находится главный офис компании?", paraphrase.Metrics.MatchedQuery)
+	assert.Greater(t, paraphrase.Metrics.Similarity, 0.85)
+	assert.Len(t, requests, 1)
+
+	// Похожий вопрос, но найдены другие фрагменты: ответ не переиспользуется
+	other := ask("Где находится офис компании", company)
+	assert.False(t, other.Metrics.SemanticHit)
+	assert.Len(t, requests, 2)
+
+	// Другой шаблон промпта - другая область кэша
+	result, err := client.Generate(context.Background(), ai.GenerateRequest{
+		Query:         "Где находится офис компании",
+		Chunks:        []domain.Chunk{office},
+		PromptOptions: ai.PromptOptions{Language: "en"},
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Metrics.SemanticHit)
+
+	// Изменение документа сбрасывает семантический кэш
+	_, err = client.InvalidateDocuments("contacts")
+	require.NoError(t, err)
+	assert.False(t, ask("Главный офис компании где находится?", office).Metrics.SemanticHit)
+}
+
+// TestSemanticCacheSkipsRedactedRequests проверяет, что запросы с замаскированными персональными данными
+// не сопоставляются: персональные данные одного запроса не попадают в ответ на другой
+func TestSemanticCacheSkipsRedactedRequests(t *testing.T) {
+	placeholder := regexp.MustCompile(`\[EMAIL_\d+\]`)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		data, _ := io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{
+				"content": "Главный офис ждет письмо от " + placeholder.FindString(string(data)),
+			}}},
+		})
+	}))
+	defer server.Close()
+
+	config := newTestConfig(server.URL)
+	config.Privacy.Redaction.Enabled = true
+	config.SemanticCache.Enabled = true
+	config.SemanticCache.Threshold = 0.6
+	config.SemanticCache.MinChunkOverlap = 0.5
+	client := newTestClient(t, config)
+
+	ask := func(query string) *ai.GenerateResult {
+		result, err := client.Generate(context.Background(), ai.GenerateRequest{Query: query, Chunks: groundingChunks[1:2]})
+		require.NoError(t, err)
+		return result
+	}
+
+	first := ask("Куда в главный офис писать ivan@example.com?")
+	assert.Equal(t, "Главный офис ждет письмо от ivan@example.com", first.Text)
+
+	second := ask("Куда писать в главный офис petr@example.com")
+	assert.False(t, second.Metrics.SemanticHit)
+	assert.Equal(t, "Главный офис ждет письмо от petr@example.com", second.Text)
+	assert.Equal(t, 2, requests)
+
+	// Без персональных данных перефразированный вопрос по-прежнему берется из семантического кэша
+	ask("Куда писать в главный офис?")
+	assert.True(t, ask("Куда в главный офис писать").Metrics.SemanticHit)
+	assert.Equal(t, 3, requests)
+}
+
+// TestSemanticCacheDisabledByDefault проверяет, что без явного включения перефразированный вопрос идет в модель
+func TestSemanticCacheDisabledByDefault(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{"Ответ"}, &requests)
+	defer server.Close()
+
+	config := newTestConfig(server.URL)
+	client := newTestClient(t, config)
+
+	for _, query := range []string{"Где находится главный офис компании?", "Где находится офис компании"} {
+		result, err := client.Generate(context.Background(), ai.GenerateRequest{Query: query, Chunks: groundingChunks[1:]})
+		require.NoError(t, err)
+		assert.False(t, result.Metrics.SemanticHit)
+	}
+	assert.Len(t, requests, 2)
+}
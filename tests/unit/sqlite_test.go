@@ -0,0 +1,154 @@
+package unit
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure"
+	"rag-system/src/infrastructure/logging"
+)
+
+// TestRepositoryUsesWAL проверяет, что база по умолчанию переводится в режим WAL
+func TestRepositoryUsesWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.db")
+	newTestRepository(t, path)
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var mode string
+	require.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+}
+
+// TestRepositoryInMemoryDSN проверяет, что все формы базы в памяти читают записанное: чтение и запись
+// идут через одно соединение
+func TestRepositoryInMemoryDSN(t *testing.T) {
+	for _, dsn := range []string{":memory:", "file::memory:", "file::memory:?cache=private", "file:rag?mode=memory"} {
+		t.Run(dsn, func(t *testing.T) {
+			repo := newTestRepository(t, dsn)
+			require.NoError(t, repo.SaveDocument(domain.Document{ID: "contacts", Title: "Контакты", Content: "Главный офис находится в Москве."}))
+
+			doc, err := repo.GetDocument("contacts")
+			require.NoError(t, err)
+			assert.Equal(t, "Контакты", doc.Title)
+			chunks, err := repo.FindRelevantChunks("офис", 5, 0)
+			require.NoError(t, err)
+			assert.NotEmpty(t, chunks)
+		})
+	}
+}
+
+// TestRepositoryRejectsInvalidSQLiteOptions проверяет, что недопустимые значения не попадают в PRAGMA
+func TestRepositoryRejectsInvalidSQLiteOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.db")
+	for name, opts := range map[string]infrastructure.SQLiteOptions{
+		"journal_mode": {JournalMode: "wal; DROP TABLE documents"},
+		"synchronous":  {Synchronous: "always"},
+		"busy_timeout": {BusyTimeout: -time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := infrastructure.NewSQLiteDocumentRepositoryWithOptions(path,
+				infrastructure.RepositoryOptions{SQLite: opts, Logger: logging.Discard()})
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestConcurrentReadWrite нагружает базу одновременной записью из двух репозиториев (как из двух
+// процессов) и чтением: ни одна операция не должна завершиться ошибкой блокировки
+func TestConcurrentReadWrite(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Пропускаем нагрузочный тест в коротком режиме")
+	}
+
+	path := filepath.Join(t.TempDir(), "stress.db")
+	first := newTestRepository(t, path)
+	second := newTestRepository(t, path)
+
+	const writers, docsPerWriter, readers = 4, 25, 4
+	errs := make(chan error, writers*(docsPerWriter+1)+readers)
+	stop := make(chan struct{})
+
+	var readersWG sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func(repo *infrastructure.SQLiteDocumentRepository) {
+			defer readersWG.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := repo.FindRelevantChunks("документ", 10, 0); err != nil {
+					errs <- fmt.Errorf("поиск: %w", err)
+					return
+				}
+				if _, err := repo.ListDocuments(domain.DocumentFilter{}, "", 20); err != nil {
+					errs <- fmt.Errorf("список: %w", err)
+					return
+				}
+			}
+		}([]*infrastructure.SQLiteDocumentRepository{first, second}[r%2])
+	}
+
+	var writersWG sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writersWG.Add(1)
+		go func(w int, repo *infrastructure.SQLiteDocumentRepository) {
+			defer writersWG.Done()
+			if w%2 == 0 {
+				for i := 0; i < docsPerWriter; i++ {
+					err := repo.SaveDocument(domain.Document{
+						ID:      fmt.Sprintf("w%d-doc-%d", w, i),
+						Title:   fmt.Sprintf("Документ %d", i),
+						Content: fmt.Sprintf("Документ %d писателя %d для нагрузочного теста.", i, w),
+					})
+					if err != nil {
+						errs <- fmt.Errorf("запись: %w", err)
+					}
+				}
+				return
+			}
+			indexer, err := repo.NewBulkIndexer(infrastructure.BulkOptions{BatchSize: 5})
+			if err != nil {
+				errs <- fmt.Errorf("пакетная запись: %w", err)
+				return
+			}
+			for i := 0; i < docsPerWriter; i++ {
+				err := indexer.Add(domain.Document{
+					ID:      fmt.Sprintf("w%d-doc-%d", w, i),
+					Title:   fmt.Sprintf("Документ %d", i),
+					Content: fmt.Sprintf("Документ %d пакета %d для нагрузочного теста.", i, w),
+				})
+				if err != nil {
+					errs <- fmt.Errorf("пакетная запись: %w", err)
+				}
+			}
+			if err := indexer.Close(); err != nil {
+				errs <- fmt.Errorf("пакетная запись: %w", err)
+			}
+		}(w, []*infrastructure.SQLiteDocumentRepository{first, second}[w/2])
+	}
+
+	writersWG.Wait()
+	close(stop)
+	readersWG.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	stats, err := first.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, writers*docsPerWriter, stats.Documents)
+}
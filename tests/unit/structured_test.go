@@ -0,0 +1,177 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/application"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ai"
+	"rag-system/tests/mocks"
+)
+
+// TestAnswerSchemaValidation проверяет проверку ответа по схеме, объявленной в Go
+func TestAnswerSchemaValidation(t *testing.T) {
+	valid := "```json\n{\"answer\": \"В 2020 году\", \"confidence\": 0.9, \"sources\": [\"doc1\"], \"followups\": []}\n```"
+	data, err := ai.AnswerSchema.Validate(valid)
+	require.NoError(t, err)
+
+	var answer domain.StructuredAnswer
+	require.NoError(t, json.Unmarshal(data, &answer))
+	assert.Equal(t, "В 2020 году", answer.Answer)
+
+	_, err = ai.AnswerSchema.Validate(`{"answer": "", "confidence": 1.5, "sources": "doc1", "extra": true}`)
+	require.Error(t, err)
+	vErr, ok := err.(*ai.ValidationError)
+	require.True(t, ok)
+	assert.Len(t, vErr.Problems, 4, "Должны быть перечислены все проблемы сразу: %v", vErr.Problems)
+
+	_, err = ai.AnswerSchema.Validate("не JSON")
+	assert.Error(t, err)
+
+	schema := ai.AnswerSchema.JSONSchema()
+	assert.Equal(t, []string{"answer", "confidence", "sources"}, schema["required"])
+}
+
+// newStructuredServer создает фейковую модель, которая возвращает ответы по очереди и сохраняет запросы
+func newStructuredServer(t *testing.T, answers []string, requests *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		*requests = append(*requests, payload)
+
+		answer := answers[min(len(*requests), len(answers))-1]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": answer}}},
+		})
+	}))
+}
+
+// TestGenerateStructuredRetriesWithFeedback проверяет повтор с передачей ошибки проверки модели
+func TestGenerateStructuredRetriesWithFeedback(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{
+		`Компания основана в 2020 году.`,
+		`{"answer": "Компания основана в 2020 году.", "confidence": 0.8, "sources": ["company"]}`,
+	}, &requests)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL, withJSONMode(ai.JSONModeObject), func(config *ai.Config) {
+		config.AI.StructuredRetries = 2
+	}))
+
+	var answer domain.StructuredAnswer
+	result, err := client.GenerateStructured(context.Background(), ai.GenerateRequest{
+		Query:  "Когда основана компания?",
+		Chunks: []domain.Chunk{{ID: "company_chunk_0", DocumentID: "company", Content: "Компания основана в 2020 году."}},
+	}, ai.AnswerSchema, &answer)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Компания основана в 2020 году.", answer.Answer)
+	assert.Equal(t, []string{"company"}, answer.Sources)
+	assert.Equal(t, 1, result.Metrics.SchemaRetries)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, map[string]interface{}{"type": "json_object"}, requests[0]["response_format"])
+
+	messages := requests[1]["messages"].([]interface{})
+	feedback := messages[len(messages)-1].(map[string]interface{})
+	assert.Equal(t, "user", feedback["role"])
+	assert.Contains(t, feedback["content"], "невалидный JSON объект")
+}
+
+// TestGenerateStructuredGivesUp проверяет ошибку после исчерпания повторов; 0 отключает повторы
+func TestGenerateStructuredGivesUp(t *testing.T) {
+	for _, retries := range []int{0, 1} {
+		var requests []map[string]interface{}
+		server := newStructuredServer(t, []string{`{"answer": "нет уверенности"}`}, &requests)
+
+		client := newTestClient(t, newTestConfig(server.URL, withJSONMode(ai.JSONModeInstructions), func(config *ai.Config) {
+			config.AI.StructuredRetries = retries
+		}))
+
+		var answer domain.StructuredAnswer
+		_, err := client.GenerateStructured(context.Background(), ai.GenerateRequest{
+			Query:  "Вопрос",
+			Chunks: []domain.Chunk{{ID: "c", DocumentID: "d", Content: "Контекст"}},
+		}, ai.AnswerSchema, &answer)
+		server.Close()
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "confidence")
+		assert.Len(t, requests, retries+1)
+		assert.Nil(t, requests[0]["response_format"], "В режиме instructions response_format не отправляется")
+	}
+}
+
+// TestGenerateStructuredWithRedaction проверяет, что ответ проверяется в замаскированном виде,
+// а ошибка проверки маскируется перед отправкой модели
+func TestGenerateStructuredWithRedaction(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{
+		`{"answer": "Пишите на [EMAIL_1]", "confidence": 0.9, "sources": ["contacts"], "support@company.com": true}`,
+		`{"answer": "Пишите на [EMAIL_1]", "confidence": 0.9, "sources": ["contacts"]}`,
+	}, &requests)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL, func(config *ai.Config) {
+		config.AI.StructuredRetries = 1
+		config.Privacy.Redaction.Enabled = true
+	}))
+
+	var answer domain.StructuredAnswer
+	result, err := client.GenerateStructured(context.Background(), ai.GenerateRequest{
+		Query:  "Какой email у компании?",
+		Chunks: []domain.Chunk{{ID: "contacts_chunk_0", DocumentID: "contacts", Content: "Email: info@company.com."}},
+	}, ai.AnswerSchema, &answer)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Пишите на info@company.com", answer.Answer)
+	assert.Contains(t, result.Text, "info@company.com")
+
+	require.Len(t, requests, 2)
+	data, err := json.Marshal(requests)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "info@company.com")
+
+	messages := requests[1]["messages"].([]interface{})
+	feedback := messages[len(messages)-1].(map[string]interface{})
+	assert.NotContains(t, feedback["content"], "support@company.com", "Ошибка проверки не должна раскрывать персональные данные")
+}
+
+// TestSearchAndGenerateStructured проверяет, что RAGService возвращает типизированный ответ
+func TestSearchAndGenerateStructured(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newStructuredServer(t, []string{
+		`{"answer": "В Москве", "confidence": 0.95, "sources": ["contacts"], "followups": ["Какой адрес?"]}`,
+	}, &requests)
+	defer server.Close()
+
+	client := newTestClient(t, newTestConfig(server.URL, withJSONMode(ai.JSONModeSchema)))
+
+	repo := mocks.NewMockDocumentRepository()
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "contacts", Title: "Контакты", Content: "Главный офис находится в Москве."}))
+	service := application.NewRAGService(repo, client)
+
+	answer, err := service.SearchAndGenerateStructured(context.Background(), "офис", 5, 0.1, ai.PromptOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &domain.StructuredAnswer{
+		Answer:     "В Москве",
+		Confidence: 0.95,
+		Sources:    []string{"contacts"},
+		Followups:  []string{"Какой адрес?"},
+	}, answer)
+
+	format := requests[0]["response_format"].(map[string]interface{})
+	assert.Equal(t, "json_schema", format["type"])
+
+	empty, err := service.SearchAndGenerateStructured(context.Background(), "несуществующее", 5, 0.1, ai.PromptOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, empty.Confidence)
+	assert.Len(t, requests, 1, "Без найденных фрагментов модель не вызывается")
+}
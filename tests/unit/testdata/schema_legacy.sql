@@ -0,0 +1,24 @@
+-- База, созданная до появления миграций: схема initSchema без таблицы schema_migrations
+CREATE TABLE documents (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE chunks (
+	id TEXT PRIMARY KEY,
+	document_id TEXT NOT NULL,
+	content TEXT NOT NULL,
+	FOREIGN KEY(document_id) REFERENCES documents(id)
+);
+
+CREATE INDEX idx_chunks_content ON chunks(content);
+
+INSERT INTO documents (id, title, content, created_at) VALUES
+	('contacts', 'Контакты', 'Главный офис находится в Москве.', '2024-03-01 10:00:00'),
+	('faq', 'Вопросы', 'Поддержка работает круглосуточно.', '2024-03-02 11:30:00');
+
+INSERT INTO chunks (id, document_id, content) VALUES
+	('contacts_chunk_0', 'contacts', 'Главный офис находится в Москве.'),
+	('faq_chunk_0', 'faq', 'Поддержка работает круглосуточно.');
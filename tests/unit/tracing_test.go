@@ -0,0 +1,243 @@
+package unit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rag-system/src/api"
+	"rag-system/src/application"
+	"rag-system/src/config"
+	"rag-system/src/domain"
+	"rag-system/src/infrastructure/ai"
+	"rag-system/src/infrastructure/logging"
+	"rag-system/src/infrastructure/tracing"
+)
+
+// exportedSpan span в формате FileExporter
+type exportedSpan struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	Service      string                 `json:"service"`
+	Attributes   map[string]interface{} `json:"attributes"`
+	Status       string                 `json:"status"`
+}
+
+// readSpans разбирает вывод FileExporter и возвращает span по имени
+func readSpans(t *testing.T, data []byte) map[string]exportedSpan {
+	spans := make(map[string]exportedSpan)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var span exportedSpan
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span), scanner.Text())
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+// TestTraceparent проверяет разбор и формирование заголовка traceparent
+func TestTraceparent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := tracing.ParseTraceparent(header)
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, header, sc.Traceparent())
+
+	sc, ok = tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.True(t, ok)
+	assert.False(t, sc.Sampled)
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, ok := tracing.ParseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+// TestTracerSampling проверяет выбор трасс: новые трассы по доле, входящие - по флагу sampled
+func TestTracerSampling(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(&buf, "test"), tracing.TracerOptions{SampleRatio: 0})
+
+	_, span := tracer.Start(context.Background(), "root")
+	assert.Nil(t, span, "при sample_ratio 0 новые трассы не записываются")
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span = tracer.Start(tracing.Extract(context.Background(), header), "continued")
+	require.NotNil(t, span, "входящая трасса с флагом sampled продолжается")
+	span.End()
+
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span = tracer.Start(tracing.Extract(context.Background(), header), "skipped")
+	assert.Nil(t, span)
+
+	// Без активного span компоненты не создают span
+	_, span = tracing.Start(context.Background(), "orphan")
+	assert.Nil(t, span)
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	spans := readSpans(t, buf.Bytes())
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "00f067aa0ba902b7", spans["continued"].ParentSpanID)
+}
+
+// TestServerTracingPropagation проверяет трассу запроса к API: продолжение входящего traceparent,
+// вложенность span поиска, кэша и попыток запроса к AI API и передачу контекста в AI API
+func TestServerTracingPropagation(t *testing.T) {
+	var mu sync.Mutex
+	var upstream []string
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		upstream = append(upstream, r.Header.Get("traceparent"))
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": "Главный офис находится в Москве."}}},
+		})
+	}))
+	defer aiServer.Close()
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	tracer, err := tracing.FromConfig(config.TracingConfig{
+		Enabled: true, Exporter: "file", Path: path, ServiceName: "rag-test", SampleRatio: 1,
+	}, logging.Discard())
+	require.NoError(t, err)
+
+	cfg := newTestConfig(aiServer.URL)
+	client, err := ai.NewAIClientWithOptions(cfg, ai.ClientOptions{Logger: logging.Discard()})
+	require.NoError(t, err)
+	repo := newTestRepository(t, "")
+	require.NoError(t, repo.SaveDocument(domain.Document{ID: "contacts", Title: "Контакты", Content: "Главный офис находится в Москве."}))
+
+	service := application.NewRAGService(repo, client)
+	service.SetTracer(tracer)
+	server := api.NewServer(service, config.Default().Server, logging.Discard(), nil)
+	server.SetTracer(tracer)
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	body, err := json.Marshal(api.SearchRequest{Query: "офис"})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, httpServer.URL+"/search", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	spans := readSpans(t, data)
+
+	for _, name := range []string{"POST /search", "rag.SearchAndGenerate", "repository.FindRelevantChunks",
+		"ai.Generate", "ai.prompt", "cache.lookup", "ai.attempt", "cache.store"} {
+		require.Contains(t, spans, name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[name].TraceID, name)
+		assert.Equal(t, "rag-test", spans[name].Service, name)
+	}
+
+	root := spans["POST /search"]
+	assert.Equal(t, "00f067aa0ba902b7", root.ParentSpanID)
+	assert.Equal(t, "server", root.Kind)
+	assert.Equal(t, float64(200), root.Attributes["http.status_code"])
+
+	rag := spans["rag.SearchAndGenerate"]
+	assert.Equal(t, root.SpanID, rag.ParentSpanID)
+	assert.Equal(t, float64(1), rag.Attributes["chunks.count"])
+	assert.Equal(t, rag.SpanID, spans["repository.FindRelevantChunks"].ParentSpanID)
+	assert.Equal(t, float64(1), spans["repository.FindRelevantChunks"].Attributes["chunks.count"])
+
+	generate := spans["ai.Generate"]
+	assert.Equal(t, rag.SpanID, generate.ParentSpanID)
+	assert.Equal(t, "test-model", generate.Attributes["ai.model"])
+	assert.Equal(t, false, generate.Attributes["cache.hit"])
+	assert.Equal(t, generate.SpanID, spans["ai.prompt"].ParentSpanID)
+	assert.Greater(t, spans["ai.prompt"].Attributes["prompt.size"], float64(0))
+	assert.Equal(t, false, spans["cache.lookup"].Attributes["cache.hit"])
+
+	attempt := spans["ai.attempt"]
+	assert.Equal(t, generate.SpanID, attempt.ParentSpanID)
+	assert.Equal(t, "client", attempt.Kind)
+	assert.Equal(t, float64(0), attempt.Attributes["retry"])
+	assert.Equal(t, float64(200), attempt.Attributes["http.status_code"])
+
+	// AI API получает контекст трассы с идентификатором span попытки
+	require.Len(t, upstream, 1)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+attempt.SpanID+"-01", upstream[0])
+}
+
+// TestOTLPExporter проверяет формат OTLP/HTTP JSON, отправляемый коллектору
+func TestOTLPExporter(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []map[string]interface{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	tracer := tracing.NewTracer(tracing.NewOTLPExporter(collector.URL+"/", "rag-test"), tracing.TracerOptions{SampleRatio: 1})
+	ctx, root := tracer.Start(context.Background(), "POST /search", tracing.String("http.method", "POST"))
+	root.SetKind(tracing.KindServer)
+	_, child := tracing.Start(ctx, "ai.attempt", tracing.Int("retry", 2), tracing.Float64("score", 0.5), tracing.Bool("cache.hit", false))
+	child.RecordError(errors.New("HTTP 503"))
+	child.End()
+	root.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	require.Len(t, payloads, 1)
+	resourceSpans := payloads[0]["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	resource := resourceSpans["resource"].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"key": "service.name", "value": map[string]interface{}{"stringValue": "rag-test"},
+	}}, resource["attributes"])
+
+	spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	require.Len(t, spans, 2)
+	attempt, server := spans[0].(map[string]interface{}), spans[1].(map[string]interface{})
+
+	assert.Equal(t, "ai.attempt", attempt["name"])
+	assert.Equal(t, float64(1), attempt["kind"])
+	assert.Equal(t, server["spanId"], attempt["parentSpanId"])
+	assert.Equal(t, server["traceId"], attempt["traceId"])
+	assert.Equal(t, map[string]interface{}{"code": float64(2), "message": "HTTP 503"}, attempt["status"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "retry", "value": map[string]interface{}{"intValue": "2"}},
+		map[string]interface{}{"key": "score", "value": map[string]interface{}{"doubleValue": 0.5}},
+		map[string]interface{}{"key": "cache.hit", "value": map[string]interface{}{"boolValue": false}},
+	}, attempt["attributes"])
+
+	assert.Equal(t, "POST /search", server["name"])
+	assert.Equal(t, float64(2), server["kind"])
+	assert.NotContains(t, server, "parentSpanId")
+	assert.IsType(t, "", server["startTimeUnixNano"])
+}